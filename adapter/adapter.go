@@ -2,26 +2,78 @@ package adapter
 
 import (
 	"fmt"
+	"io"
+	"mime"
+	"net/http"
 	"os"
+	"path/filepath"
 	"strings"
+	"time"
 )
 
+// TypeFile is the FileAttributes type of a file
+const TypeFile = "file"
+
+// TypeDir is the FileAttributes type of a directory
+const TypeDir = "dir"
+
+// VisibilityPublic marks a file or directory as public
+const VisibilityPublic = "public"
+
+// VisibilityPrivate marks a file or directory as private
+const VisibilityPrivate = "private"
+
 // Adapter ...
 type Adapter interface {
 	Write(path string, contents []byte) error
 	Update(path string, contents []byte) error
+	Put(path string, contents []byte) error
 	Read(path string) ([]byte, error)
+	WriteStream(path string, contents io.Reader) error
+	ReadStream(path string) (io.ReadCloser, error)
 	Rename(path string, newPath string) error
 	Copy(path string, newPath string) error
 	Delete(path string) error
 	CreateDir(dir string) error
 	DeleteDir(dir string) error
 	SetVisibility(path string, visibility string) error
+	Has(path string) (bool, error)
+	FileExists(path string) (bool, error)
+	DirectoryExists(dir string) (bool, error)
+	Stat(path string) (FileAttributes, error)
+	ListContents(dir string, deep bool) (DirectoryListing, error)
+}
+
+// FileAttributes describes a file or directory
+type FileAttributes struct {
+	Path         string
+	Type         string
+	Size         int64
+	LastModified time.Time
+	MimeType     string
+	Visibility   string
+}
+
+// IsDir reports whether the attributes describe a directory
+func (f FileAttributes) IsDir() bool {
+	return f.Type == TypeDir
+}
+
+// IsFile reports whether the attributes describe a file
+func (f FileAttributes) IsFile() bool {
+	return f.Type == TypeFile
 }
 
 // BaseAdapter ...
 type BaseAdapter struct {
-	pathPrefix *string
+	pathPrefix    *string
+	pathSeparator string
+}
+
+// SetPathSeparator sets the separator between the prefix and a path, it defaults to os.PathSeparator.
+// Adapters for remote stores set it to "/", call it before SetPathPrefix
+func (a *BaseAdapter) SetPathSeparator(separator string) {
+	a.pathSeparator = separator
 }
 
 // SetPathPrefix sets the path prefix
@@ -30,11 +82,33 @@ func (a *BaseAdapter) SetPathPrefix(prefix string) {
 		a.pathPrefix = nil
 	}
 
-	p := fmt.Sprintf("%s%s", prefix, string(os.PathSeparator))
+	p := fmt.Sprintf("%s%s", prefix, a.separator())
 	a.pathPrefix = &p
 }
 
 // ApplyPathPrefix applies the path prefix
 func (a *BaseAdapter) ApplyPathPrefix(path string) string {
-	return fmt.Sprintf("%s%s", *a.pathPrefix, strings.TrimPrefix(path, string(os.PathSeparator)))
+	return fmt.Sprintf("%s%s", *a.pathPrefix, strings.TrimPrefix(path, a.separator()))
+}
+
+// separator returns the path separator
+func (a *BaseAdapter) separator() string {
+	if a.pathSeparator == "" {
+		return string(os.PathSeparator)
+	}
+
+	return a.pathSeparator
+}
+
+// detectMimeType guesses the mime type by extension, falling back to sniffing the contents
+func detectMimeType(path string, contents []byte) string {
+	if t := mime.TypeByExtension(filepath.Ext(path)); t != "" {
+		return t
+	}
+
+	if len(contents) == 0 {
+		return ""
+	}
+
+	return http.DetectContentType(contents)
 }
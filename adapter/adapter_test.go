@@ -39,3 +39,15 @@ func TestBaseAdapter_ApplyPathPrefix(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestBaseAdapter_SetPathSeparator(t *testing.T) {
+	a := &BaseAdapter{}
+	a.SetPathSeparator("|")
+	a.SetPathPrefix("data")
+	p := a.ApplyPathPrefix("|sub")
+
+	if p != "data|sub" {
+		t.Logf("unexpected path: %s", p)
+		t.Fail()
+	}
+}
@@ -0,0 +1,186 @@
+package adapter
+
+import (
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+)
+
+// sniffSize is how much of a file is read to guess its mime type, http.DetectContentType looks at no more
+const sniffSize = 512
+
+// archive is the read-only core of the archive adapters, it indexes the entries when the archive is opened.
+// Every method that changes something fails with ErrReadOnly
+type archive struct {
+	readOnlyMethods
+	entries map[string]*archiveEntry
+	open    func(entry *archiveEntry) (io.ReadCloser, error)
+}
+
+// archiveEntry is a file or directory in an archive.
+// Directories that are only implied by the paths of their contents have no file info,
+// head holds the first bytes of a file when they were read while indexing
+type archiveEntry struct {
+	info  os.FileInfo
+	index int
+	head  []byte
+}
+
+// newArchive creates an empty index, open reads the contents of a file entry
+func newArchive(open func(entry *archiveEntry) (io.ReadCloser, error)) archive {
+	return archive{
+		entries: map[string]*archiveEntry{},
+		open:    open,
+	}
+}
+
+// add indexes an entry and the directories it is in, a later entry with the same name replaces the earlier one.
+// It returns the indexed entry, or nil when the name is the root
+func (a *archive) add(name string, info os.FileInfo, index int) *archiveEntry {
+	p := normalizePath(name)
+	if p == "" {
+		return nil
+	}
+
+	entry := &archiveEntry{info: info, index: index}
+	a.entries[p] = entry
+
+	for dir := path.Dir(p); dir != "."; dir = path.Dir(dir) {
+		if _, ok := a.entries[dir]; !ok {
+			a.entries[dir] = &archiveEntry{}
+		}
+	}
+
+	return entry
+}
+
+// Read a file
+func (a *archive) Read(path string) ([]byte, error) {
+	r, err := a.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, wrapError("read", path, err)
+	}
+
+	return contents, nil
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *archive) ReadStream(path string) (io.ReadCloser, error) {
+	entry, ok := a.entries[normalizePath(path)]
+	if !ok || entry.isDir() {
+		return nil, wrapError("read", path, ErrFileNotFound)
+	}
+
+	r, err := a.open(entry)
+	if err != nil {
+		return nil, wrapError("read", path, err)
+	}
+
+	return r, nil
+}
+
+// Has checks if a file or directory exists
+func (a *archive) Has(path string) (bool, error) {
+	_, ok := a.entries[normalizePath(path)]
+
+	return ok, nil
+}
+
+// FileExists checks if a file exists
+func (a *archive) FileExists(path string) (bool, error) {
+	entry, ok := a.entries[normalizePath(path)]
+
+	return ok && !entry.isDir(), nil
+}
+
+// DirectoryExists checks if a directory exists
+func (a *archive) DirectoryExists(dir string) (bool, error) {
+	entry, ok := a.entries[normalizePath(dir)]
+
+	return ok && entry.isDir(), nil
+}
+
+// Stat returns the attributes of a file or directory, the visibility follows the permissions stored in the archive
+func (a *archive) Stat(path string) (FileAttributes, error) {
+	p := normalizePath(path)
+
+	entry, ok := a.entries[p]
+	if !ok {
+		return FileAttributes{}, wrapError("stat", path, ErrFileNotFound)
+	}
+
+	return a.attributes(p, entry), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories
+func (a *archive) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	p := normalizePath(dir)
+
+	if entry, ok := a.entries[p]; p != "" && (!ok || !entry.isDir()) {
+		return nil, wrapDirError("list", dir, ErrDirectoryNotFound)
+	}
+
+	prefix := p + "/"
+	if p == "" {
+		prefix = ""
+	}
+
+	var contents []FileAttributes
+
+	for child, entry := range a.entries {
+		if !strings.HasPrefix(child, prefix) {
+			continue
+		}
+
+		if !deep && strings.Contains(strings.TrimPrefix(child, prefix), "/") {
+			continue
+		}
+
+		contents = append(contents, a.attributes(child, entry))
+	}
+
+	sort.Slice(contents, func(i, j int) bool {
+		return contents[i].Path < contents[j].Path
+	})
+
+	return newSliceListing(contents), nil
+}
+
+// attributes describes the entry stored at p, the contents are only read when the extension doesn't tell the mime type
+// and they weren't read while indexing
+func (a *archive) attributes(p string, entry *archiveEntry) FileAttributes {
+	if entry.info == nil {
+		return FileAttributes{Path: p, Type: TypeDir, Visibility: VisibilityPublic}
+	}
+
+	return infoAttributes(p, entry.info, func() []byte {
+		if entry.head != nil {
+			return entry.head
+		}
+
+		r, err := a.open(entry)
+		if err != nil {
+			return nil
+		}
+
+		defer r.Close()
+
+		head, _ := io.ReadAll(io.LimitReader(r, sniffSize))
+
+		return head
+	})
+}
+
+// isDir reports whether the entry is a directory
+func (e *archiveEntry) isDir() bool {
+	return e.info == nil || e.info.IsDir()
+}
@@ -0,0 +1,309 @@
+package azureblob
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/runtime"
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/bloberror"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/blockblob"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/edwin-luijten/go_flysystem/internal/object"
+)
+
+var (
+	errContainerVisibility = errors.New("visibility is set on the container")
+	errCopyFailed          = errors.New("copy did not succeed")
+)
+
+// azureCopyPollInterval is how often the status of a pending copy is checked
+const azureCopyPollInterval = 100 * time.Millisecond
+
+// AzureBlobConfig configures an Azure Blob Storage adapter
+type AzureBlobConfig struct {
+	// Prefix is prepended to every blob name, it allows several adapters to share a container
+	Prefix string
+	// BlockSize is the size of the blocks of an upload, smaller files are uploaded in a single request.
+	// Blocks are at least 1 MiB, which is also the default
+	BlockSize int64
+}
+
+// AzureBlob stores files as block blobs in an Azure Blob Storage container.
+// Directories are emulated the same way as in S3, with marker blobs whose name ends with a slash.
+// Azure only controls access per container: every file has the visibility of the container,
+// a public container allows anonymous reads of its blobs
+type AzureBlob struct {
+	*object.Adapter
+}
+
+// NewAzureBlob creates a new instance of AzureBlob, the client decides the account, container and credentials.
+// The container must exist
+func NewAzureBlob(client *container.Client, config AzureBlobConfig) (adapter.Adapter, error) {
+	store := &azureBlobStore{
+		client:    client,
+		blockSize: config.BlockSize,
+	}
+
+	visibility, err := store.Visibility(context.Background(), "")
+	if err != nil {
+		return nil, object.NewError(store, "connect", client.URL(), adapter.ErrDirectoryNotFound, err)
+	}
+
+	return &AzureBlob{object.New(store, adapter.NormalizePath(config.Prefix), visibility)}, nil
+}
+
+// azureBlobStore is the object store of the AzureBlob adapter
+type azureBlobStore struct {
+	client    *container.Client
+	blockSize int64
+}
+
+// Head returns the attributes of a blob
+func (s *azureBlobStore) Head(ctx context.Context, key string) (object.Object, error) {
+	props, err := s.client.NewBlobClient(key).GetProperties(ctx, nil)
+	if err != nil {
+		return object.Object{}, err
+	}
+
+	return object.Object{
+		Key:          key,
+		Size:         azureValue(props.ContentLength),
+		LastModified: azureValue(props.LastModified),
+		MimeType:     azureValue(props.ContentType),
+	}, nil
+}
+
+// Get opens a blob for reading
+func (s *azureBlobStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.NewBlobClient(key).DownloadStream(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Body, nil
+}
+
+// Put stores a block blob, contents larger than the block size are staged in blocks.
+// The visibility is left to the container
+func (s *azureBlobStore) Put(ctx context.Context, key string, contents io.Reader, mimeType string, visibility string, exclusive bool) error {
+	options := &blockblob.UploadStreamOptions{BlockSize: s.blockSize}
+
+	if mimeType != "" {
+		options.HTTPHeaders = &blob.HTTPHeaders{BlobContentType: to.Ptr(mimeType)}
+	}
+
+	if exclusive {
+		options.AccessConditions = &blob.AccessConditions{
+			ModifiedAccessConditions: &blob.ModifiedAccessConditions{IfNoneMatch: to.Ptr(azcore.ETagAny)},
+		}
+	}
+
+	_, err := s.client.NewBlockBlobClient(key).UploadStream(ctx, contents, options)
+
+	return err
+}
+
+// Copy copies a blob on the server, copies within an account usually complete at once but may be pending
+func (s *azureBlobStore) Copy(ctx context.Context, source string, destination string, visibility string) error {
+	client := s.client.NewBlobClient(destination)
+
+	out, err := client.StartCopyFromURL(ctx, s.client.NewBlobClient(source).URL(), nil)
+	if err != nil {
+		return err
+	}
+
+	status := azureValue(out.CopyStatus)
+
+	for status == blob.CopyStatusTypePending {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(azureCopyPollInterval):
+		}
+
+		props, err := client.GetProperties(ctx, nil)
+		if err != nil {
+			return err
+		}
+
+		status = azureValue(props.CopyStatus)
+	}
+
+	if status != blob.CopyStatusTypeSuccess {
+		return errCopyFailed
+	}
+
+	return nil
+}
+
+// Delete deletes blobs and their snapshots one at a time
+func (s *azureBlobStore) Delete(ctx context.Context, keys []string) error {
+	options := &blob.DeleteOptions{DeleteSnapshots: to.Ptr(blob.DeleteSnapshotsOptionTypeInclude)}
+
+	for _, key := range keys {
+		_, err := s.client.NewBlobClient(key).Delete(ctx, options)
+		if err != nil && s.Kind(err) != adapter.ErrFileNotFound {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List pages through the blobs whose name starts with prefix
+func (s *azureBlobStore) List(ctx context.Context, prefix string, delimited bool, limit int) object.Pages {
+	var namePrefix *string
+	if prefix != "" {
+		namePrefix = to.Ptr(prefix)
+	}
+
+	var maxResults *int32
+	if limit > 0 {
+		maxResults = to.Ptr(int32(limit))
+	}
+
+	if delimited {
+		return &azureBlobPages{
+			ctx: ctx,
+			hierarchy: s.client.NewListBlobsHierarchyPager("/", &container.ListBlobsHierarchyOptions{
+				Prefix:     namePrefix,
+				MaxResults: maxResults,
+			}),
+		}
+	}
+
+	return &azureBlobPages{
+		ctx: ctx,
+		flat: s.client.NewListBlobsFlatPager(&container.ListBlobsFlatOptions{
+			Prefix:     namePrefix,
+			MaxResults: maxResults,
+		}),
+	}
+}
+
+// Visibility returns the visibility of the container, it applies to every blob in it
+func (s *azureBlobStore) Visibility(ctx context.Context, key string) (string, error) {
+	props, err := s.client.GetProperties(ctx, nil)
+	if err != nil {
+		return "", err
+	}
+
+	if props.BlobPublicAccess == nil {
+		return adapter.VisibilityPrivate, nil
+	}
+
+	return adapter.VisibilityPublic, nil
+}
+
+// SetVisibility only accepts the visibility of the container, a single blob can't differ from it
+func (s *azureBlobStore) SetVisibility(ctx context.Context, key string, visibility string) error {
+	current, err := s.Visibility(ctx, key)
+	if err != nil {
+		return err
+	}
+
+	if visibility != current {
+		return errContainerVisibility
+	}
+
+	return nil
+}
+
+// Kind maps the status and error codes of Azure to the error kinds
+func (s *azureBlobStore) Kind(err error) error {
+	var respErr *azcore.ResponseError
+	if !errors.As(err, &respErr) {
+		return nil
+	}
+
+	switch {
+	case respErr.StatusCode == http.StatusNotFound:
+		return adapter.ErrFileNotFound
+	case bloberror.HasCode(err, bloberror.BlobAlreadyExists, bloberror.ConditionNotMet):
+		return adapter.ErrFileExists
+	case respErr.StatusCode == http.StatusUnauthorized, respErr.StatusCode == http.StatusForbidden:
+		return adapter.ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// azureBlobPages pages through a flat or a hierarchical listing of blobs
+type azureBlobPages struct {
+	ctx       context.Context
+	flat      *runtime.Pager[container.ListBlobsFlatResponse]
+	hierarchy *runtime.Pager[container.ListBlobsHierarchyResponse]
+}
+
+// More reports whether there is another page
+func (p *azureBlobPages) More() bool {
+	if p.hierarchy != nil {
+		return p.hierarchy.More()
+	}
+
+	return p.flat.More()
+}
+
+// Next fetches the next page
+func (p *azureBlobPages) Next() (object.Page, error) {
+	var page object.Page
+
+	if p.hierarchy != nil {
+		out, err := p.hierarchy.NextPage(p.ctx)
+		if err != nil || out.Segment == nil {
+			return page, err
+		}
+
+		for _, prefix := range out.Segment.BlobPrefixes {
+			page.Prefixes = append(page.Prefixes, azureValue(prefix.Name))
+		}
+
+		page.Objects = azureObjects(out.Segment.BlobItems)
+
+		return page, nil
+	}
+
+	out, err := p.flat.NextPage(p.ctx)
+	if err != nil || out.Segment == nil {
+		return page, err
+	}
+
+	page.Objects = azureObjects(out.Segment.BlobItems)
+
+	return page, nil
+}
+
+// azureObjects describes the listed blobs
+func azureObjects(items []*container.BlobItem) []object.Object {
+	objects := make([]object.Object, 0, len(items))
+
+	for _, item := range items {
+		o := object.Object{Key: azureValue(item.Name)}
+
+		if item.Properties != nil {
+			o.Size = azureValue(item.Properties.ContentLength)
+			o.LastModified = azureValue(item.Properties.LastModified)
+		}
+
+		objects = append(objects, o)
+	}
+
+	return objects
+}
+
+// azureValue dereferences an optional field of a response
+func azureValue[T any](p *T) T {
+	var value T
+	if p != nil {
+		value = *p
+	}
+
+	return value
+}
@@ -0,0 +1,283 @@
+package azureblob
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/Azure/azure-sdk-for-go/sdk/azcore/to"
+	"github.com/Azure/azure-sdk-for-go/sdk/storage/azblob/container"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+)
+
+// azuriteAccount and azuriteKey are the well known development credentials of Azurite
+const (
+	azuriteAccount = "devstoreaccount1"
+	azuriteKey     = "Eby8vdM02xNOcqFlqUwJPLlmEtlCDXJ1OUzFT50uSRZ6IFsuFq2UVErCz4I6tq/K1SZFPTOtr/KBHBeksoGMGw=="
+)
+
+var azuriteContainers atomic.Int64
+
+// newAzureBlob creates an adapter on a new container in Azurite, which is expected at AZURITE_BLOB_ENDPOINT
+// (for example http://127.0.0.1:10000/devstoreaccount1)
+func newAzureBlob(t *testing.T, access *container.PublicAccessType, config AzureBlobConfig) adapter.Adapter {
+	endpoint := os.Getenv("AZURITE_BLOB_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("AZURITE_BLOB_ENDPOINT is not set")
+	}
+
+	credential, err := container.NewSharedKeyCredential(azuriteAccount, azuriteKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	name := fmt.Sprintf("flysystem-%d-%d", time.Now().UnixNano(), azuriteContainers.Add(1))
+
+	client, err := container.NewClientWithSharedKeyCredential(strings.TrimSuffix(endpoint, "/")+"/"+name, credential, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = client.Create(context.Background(), &container.CreateOptions{Access: access})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		client.Delete(context.Background(), nil)
+	})
+
+	fs, err := NewAzureBlob(client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestAzureBlob_Write(t *testing.T) {
+	fs := newAzureBlob(t, nil, AzureBlobConfig{})
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != adapter.VisibilityPrivate || !strings.HasPrefix(attributes.MimeType, "text/plain") {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("missing.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Put("sub/test.txt", []byte("hello put"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestAzureBlob_Stream(t *testing.T) {
+	fs := newAzureBlob(t, nil, AzureBlobConfig{BlockSize: 1024 * 1024})
+
+	contents := bytes.Repeat([]byte("0123456789"), 300*1024)
+
+	err := fs.WriteStream("stream.bin", bytes.NewReader(contents))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("stream.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	read, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(read, contents) {
+		t.Log("stream contents don't match")
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream.bin", bytes.NewReader(contents))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestAzureBlob_Visibility(t *testing.T) {
+	fs := newAzureBlob(t, to.Ptr(container.PublicAccessTypeBlob), AzureBlobConfig{})
+
+	err := fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attributes, err := fs.Stat("test.txt")
+	if err != nil || attributes.Visibility != adapter.VisibilityPublic {
+		t.Logf("expected the container's visibility, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("test.txt", adapter.VisibilityPublic)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("test.txt", adapter.VisibilityPrivate)
+	if !errors.Is(err, errContainerVisibility) {
+		t.Logf("expected errContainerVisibility, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestAzureBlob_Copy(t *testing.T) {
+	fs := newAzureBlob(t, nil, AzureBlobConfig{Prefix: "root"})
+
+	for _, p := range []string{"test file.txt", "dir/a.txt", "dir/sub/b.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := fs.Copy("test file.txt", "sub/copy.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/copy.txt")
+	if err != nil || string(contents) != "test file.txt" {
+		t.Logf("unexpected contents of the copy: %q %v", contents, err)
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "copy.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Rename("dir", "moved")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err = fs.Read("moved/sub/b.txt")
+	if err != nil || string(contents) != "dir/sub/b.txt" {
+		t.Logf("expected the directory contents to move, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir"); ok {
+		t.Log("expected dir to be gone")
+		t.Fail()
+	}
+}
+
+func TestAzureBlob_Directories(t *testing.T) {
+	fs := newAzureBlob(t, nil, AzureBlobConfig{})
+
+	err := fs.CreateDir("dir/empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("dir/empty")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir"}},
+		{"dir", false, []string{"dir/b.txt", "dir/empty", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "dir/b.txt", "dir/empty", "dir/sub", "dir/sub/c.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := adapter.Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err = fs.ListContents("missing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("dir/sub/c.txt"); ok {
+		t.Log("expected dir to be deleted")
+		t.Fail()
+	}
+}
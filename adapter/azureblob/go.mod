@@ -0,0 +1,21 @@
+module github.com/edwin-luijten/go_flysystem/adapter/azureblob
+
+go 1.24.0
+
+require (
+	github.com/Azure/azure-sdk-for-go/sdk/azcore v1.20.0
+	github.com/Azure/azure-sdk-for-go/sdk/storage/azblob v1.6.4
+	github.com/edwin-luijten/go_flysystem v0.1.0
+)
+
+require (
+	github.com/Azure/azure-sdk-for-go/sdk/internal v1.11.2 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	golang.org/x/crypto v0.45.0 // indirect
+	golang.org/x/net v0.47.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
+	golang.org/x/text v0.31.0 // indirect
+)
+
+// The adapter is developed against the core next to it, users of the module get the required version
+replace github.com/edwin-luijten/go_flysystem => ../..
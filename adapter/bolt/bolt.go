@@ -0,0 +1,702 @@
+package bolt
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	bolt "go.etcd.io/bbolt"
+)
+
+var (
+	errUnknownVisibility = errors.New("unknown visibility")
+	errMoveIntoItself    = errors.New("can't move a directory into itself")
+)
+
+// boltOpenTimeout is how long NewBolt waits for another process to release the database
+const boltOpenTimeout = time.Second
+
+// Buckets of the Bolt adapter, the metadata of every file and directory and the contents of the files
+var (
+	boltMetaBucket     = []byte("meta")
+	boltContentsBucket = []byte("contents")
+)
+
+// Bolt stores files in a bbolt database, keyed by their path.
+// Directories are either explicit entries created by CreateDir or implied by the paths of the files in them.
+// Keys are sorted, so the contents of a directory are found with a prefix scan.
+// Every operation runs in a single transaction
+type Bolt struct {
+	db *bolt.DB
+}
+
+// boltMeta is the metadata of a file or directory
+type boltMeta struct {
+	Dir          bool      `json:"dir,omitempty"`
+	Size         int64     `json:"size"`
+	Visibility   string    `json:"visibility"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// NewBolt opens or creates a bbolt database, call Close to release it.
+// A database can only be opened by one process at a time
+func NewBolt(path string) (adapter.Adapter, error) {
+	db, err := bolt.Open(path, adapter.FilePrivate, &bolt.Options{Timeout: boltOpenTimeout})
+	if err != nil {
+		return nil, &adapter.Error{Op: "open", Path: path, Kind: adapter.ErrUnableToCreateRoot, Err: err}
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{boltMetaBucket, boltContentsBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+
+		return nil, &adapter.Error{Op: "open", Path: path, Kind: adapter.ErrUnableToCreateRoot, Err: err}
+	}
+
+	return &Bolt{db: db}, nil
+}
+
+// Close closes the database
+func (a *Bolt) Close() error {
+	return a.db.Close()
+}
+
+// Write a new file, it fails if the file already exists
+func (a *Bolt) Write(path string, contents []byte) error {
+	p := adapter.NormalizePath(path)
+
+	return adapter.WrapError("write", path, a.db.Update(func(tx *bolt.Tx) error {
+		kind, err := boltKind(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind != "" {
+			return adapter.WrapError("write", path, adapter.ErrFileExists)
+		}
+
+		return boltPut(tx, "write", p, contents, adapter.VisibilityPublic)
+	}))
+}
+
+// Update a file, it fails if the file does not exist
+func (a *Bolt) Update(path string, contents []byte) error {
+	p := adapter.NormalizePath(path)
+
+	return adapter.WrapError("update", path, a.db.Update(func(tx *bolt.Tx) error {
+		meta, err := boltGet(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if meta == nil || meta.Dir {
+			return adapter.WrapError("update", path, adapter.ErrFileNotFound)
+		}
+
+		return boltPut(tx, "update", p, contents, meta.Visibility)
+	}))
+}
+
+// Put writes a file, creating or overwriting it, an existing file keeps its visibility
+func (a *Bolt) Put(path string, contents []byte) error {
+	p := adapter.NormalizePath(path)
+
+	return adapter.WrapError("put", path, a.db.Update(func(tx *bolt.Tx) error {
+		kind, err := boltKind(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind == adapter.TypeDir {
+			return adapter.WrapError("put", path, adapter.ErrFileExists)
+		}
+
+		visibility := adapter.VisibilityPublic
+
+		if kind == adapter.TypeFile {
+			meta, err := boltGet(tx, p)
+			if err != nil {
+				return err
+			}
+
+			visibility = meta.Visibility
+		}
+
+		return boltPut(tx, "put", p, contents, visibility)
+	}))
+}
+
+// Read a file
+func (a *Bolt) Read(path string) ([]byte, error) {
+	p := adapter.NormalizePath(path)
+
+	var contents []byte
+
+	err := a.db.View(func(tx *bolt.Tx) error {
+		meta, err := boltGet(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if meta == nil || meta.Dir {
+			return adapter.WrapError("read", path, adapter.ErrFileNotFound)
+		}
+
+		// The value is only valid during the transaction
+		contents = copyBytes(tx.Bucket(boltContentsBucket).Get([]byte(p)))
+
+		return nil
+	})
+	if err != nil {
+		return nil, adapter.WrapError("read", path, err)
+	}
+
+	return contents, nil
+}
+
+// WriteStream writes a new file from a stream, the stream is read into memory first
+func (a *Bolt) WriteStream(path string, contents io.Reader) error {
+	b, err := io.ReadAll(contents)
+	if err != nil {
+		return adapter.WrapError("write", path, err)
+	}
+
+	return a.Write(path, b)
+}
+
+// ReadStream opens a file for reading, the file is read into memory at once
+func (a *Bolt) ReadStream(path string) (io.ReadCloser, error) {
+	contents, err := a.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// Rename a file or directory, a directory is moved with everything in it.
+// An existing file at the destination is overwritten, an existing directory is not
+func (a *Bolt) Rename(path string, newPath string) error {
+	p := adapter.NormalizePath(path)
+	destination := adapter.NormalizePath(newPath)
+
+	return adapter.WrapError("rename", path, a.db.Update(func(tx *bolt.Tx) error {
+		kind, err := boltKind(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind == "" {
+			return adapter.WrapError("rename", path, adapter.ErrFileNotFound)
+		}
+
+		if p == destination {
+			return nil
+		}
+
+		if kind == adapter.TypeDir && strings.HasPrefix(destination+"/", p+"/") {
+			return adapter.WrapError("rename", newPath, errMoveIntoItself)
+		}
+
+		existing, err := boltKind(tx, destination)
+		if err != nil {
+			return err
+		}
+
+		if existing == adapter.TypeDir {
+			return adapter.WrapError("rename", newPath, adapter.ErrFileExists)
+		}
+
+		err = boltCheckParents(tx, "rename", destination)
+		if err != nil {
+			return err
+		}
+
+		keys := [][]byte{[]byte(p)}
+
+		if kind == adapter.TypeDir {
+			keys = append(keys, boltChildren(tx, p)...)
+		}
+
+		for _, key := range keys {
+			err = boltMove(tx, key, []byte(destination+strings.TrimPrefix(string(key), p)))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+// Copy a file, the copy keeps the visibility of the file
+func (a *Bolt) Copy(path string, newPath string) error {
+	p := adapter.NormalizePath(path)
+	destination := adapter.NormalizePath(newPath)
+
+	return adapter.WrapError("copy", path, a.db.Update(func(tx *bolt.Tx) error {
+		meta, err := boltGet(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if meta == nil || meta.Dir {
+			return adapter.WrapError("copy", path, adapter.ErrFileNotFound)
+		}
+
+		kind, err := boltKind(tx, destination)
+		if err != nil {
+			return err
+		}
+
+		if kind == adapter.TypeDir {
+			return adapter.WrapError("copy", newPath, adapter.ErrFileExists)
+		}
+
+		contents := copyBytes(tx.Bucket(boltContentsBucket).Get([]byte(p)))
+
+		return boltPut(tx, "copy", destination, contents, meta.Visibility)
+	}))
+}
+
+// Delete a file
+func (a *Bolt) Delete(path string) error {
+	p := adapter.NormalizePath(path)
+
+	return adapter.WrapError("delete", path, a.db.Update(func(tx *bolt.Tx) error {
+		meta, err := boltGet(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if meta == nil || meta.Dir {
+			return adapter.WrapError("delete", path, adapter.ErrFileNotFound)
+		}
+
+		return boltDelete(tx, []byte(p))
+	}))
+}
+
+// CreateDir creates a directory entry
+func (a *Bolt) CreateDir(dir string) error {
+	p := adapter.NormalizePath(dir)
+
+	return adapter.WrapDirError("mkdir", dir, a.db.Update(func(tx *bolt.Tx) error {
+		kind, err := boltKind(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind != "" {
+			return adapter.WrapDirError("mkdir", dir, adapter.ErrFileExists)
+		}
+
+		err = boltCheckParents(tx, "mkdir", p)
+		if err != nil {
+			return err
+		}
+
+		return boltSetMeta(tx, []byte(p), &boltMeta{Dir: true, Visibility: adapter.VisibilityPublic, LastModified: time.Now()})
+	}))
+}
+
+// DeleteDir deletes a directory and its contents with a prefix scan, a missing directory is ignored
+func (a *Bolt) DeleteDir(dir string) error {
+	p := adapter.NormalizePath(dir)
+
+	return adapter.WrapDirError("rmdir", dir, a.db.Update(func(tx *bolt.Tx) error {
+		kind, err := boltKind(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind == adapter.TypeFile {
+			return adapter.WrapDirError("rmdir", dir, adapter.ErrDirectoryNotFound)
+		}
+
+		keys := boltChildren(tx, p)
+		if p != "" {
+			keys = append(keys, []byte(p))
+		}
+
+		for _, key := range keys {
+			err = boltDelete(tx, key)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}))
+}
+
+// SetVisibility sets a file or directory to public or private.
+// A directory that is only implied by its contents gets an entry to keep its visibility
+func (a *Bolt) SetVisibility(path string, visibility string) error {
+	if visibility != adapter.VisibilityPublic && visibility != adapter.VisibilityPrivate {
+		return adapter.WrapError("chmod", path, errUnknownVisibility)
+	}
+
+	p := adapter.NormalizePath(path)
+
+	return adapter.WrapError("chmod", path, a.db.Update(func(tx *bolt.Tx) error {
+		meta, err := boltGet(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if meta == nil {
+			kind, err := boltKind(tx, p)
+			if err != nil {
+				return err
+			}
+
+			if kind == "" || p == "" {
+				return adapter.WrapError("chmod", path, adapter.ErrFileNotFound)
+			}
+
+			meta = &boltMeta{Dir: true, LastModified: time.Now()}
+		}
+
+		meta.Visibility = visibility
+
+		return boltSetMeta(tx, []byte(p), meta)
+	}))
+}
+
+// Has checks if a file or directory exists
+func (a *Bolt) Has(path string) (bool, error) {
+	kind, err := a.kind(path)
+
+	return kind != "", adapter.WrapError("stat", path, err)
+}
+
+// FileExists checks if a file exists
+func (a *Bolt) FileExists(path string) (bool, error) {
+	kind, err := a.kind(path)
+
+	return kind == adapter.TypeFile, adapter.WrapError("stat", path, err)
+}
+
+// DirectoryExists checks if a directory entry or a file in the directory exists
+func (a *Bolt) DirectoryExists(dir string) (bool, error) {
+	kind, err := a.kind(dir)
+
+	return kind == adapter.TypeDir, adapter.WrapDirError("stat", dir, err)
+}
+
+// Stat returns the attributes of a file or directory.
+// The contents are only read when the mime type can't be told by the extension
+func (a *Bolt) Stat(path string) (adapter.FileAttributes, error) {
+	p := adapter.NormalizePath(path)
+
+	var attributes adapter.FileAttributes
+
+	err := a.db.View(func(tx *bolt.Tx) error {
+		meta, err := boltGet(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if meta == nil {
+			if p != "" && !boltHasChildren(tx, p) {
+				return adapter.WrapError("stat", path, adapter.ErrFileNotFound)
+			}
+
+			meta = &boltMeta{Dir: true, Visibility: adapter.VisibilityPublic}
+		}
+
+		attributes = meta.attributes(p)
+
+		if attributes.IsFile() && attributes.MimeType == "" {
+			attributes.MimeType = adapter.DetectMimeType(p, tx.Bucket(boltContentsBucket).Get([]byte(p)))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return adapter.FileAttributes{}, adapter.WrapError("stat", path, err)
+	}
+
+	return attributes, nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// The listing is a snapshot taken when it is created, mime types are guessed from the extension only
+func (a *Bolt) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	p := adapter.NormalizePath(dir)
+
+	prefix := p + "/"
+	if p == "" {
+		prefix = ""
+	}
+
+	var contents []adapter.FileAttributes
+
+	err := a.db.View(func(tx *bolt.Tx) error {
+		kind, err := boltKind(tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind != adapter.TypeDir {
+			return adapter.WrapDirError("list", dir, adapter.ErrDirectoryNotFound)
+		}
+
+		listed := map[string]bool{}
+
+		c := tx.Bucket(boltMetaBucket).Cursor()
+
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			child := string(k)
+
+			// A directory implied by the entry may already have an entry of its own, which is listed first
+			for parent := path.Dir(child); parent != "." && parent != p && strings.HasPrefix(parent, prefix); parent = path.Dir(parent) {
+				if listed[parent] || (!deep && strings.Contains(strings.TrimPrefix(parent, prefix), "/")) {
+					continue
+				}
+
+				listed[parent] = true
+				contents = append(contents, adapter.FileAttributes{Path: parent, Type: adapter.TypeDir, Visibility: adapter.VisibilityPublic})
+			}
+
+			if listed[child] || (!deep && strings.Contains(strings.TrimPrefix(child, prefix), "/")) {
+				continue
+			}
+
+			meta, err := boltDecode(v)
+			if err != nil {
+				return err
+			}
+
+			listed[child] = true
+			contents = append(contents, meta.attributes(child))
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, adapter.WrapDirError("list", dir, err)
+	}
+
+	sort.Slice(contents, func(i, j int) bool {
+		return contents[i].Path < contents[j].Path
+	})
+
+	return adapter.NewSliceListing(contents), nil
+}
+
+// kind returns TypeFile or TypeDir for an existing path, or an empty string if it does not exist
+func (a *Bolt) kind(path string) (string, error) {
+	var kind string
+
+	err := a.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		kind, err = boltKind(tx, adapter.NormalizePath(path))
+
+		return err
+	})
+
+	return kind, err
+}
+
+// boltKind returns TypeFile or TypeDir for an existing path, or an empty string if it does not exist
+func boltKind(tx *bolt.Tx, p string) (string, error) {
+	if p == "" {
+		return adapter.TypeDir, nil
+	}
+
+	meta, err := boltGet(tx, p)
+	if err != nil {
+		return "", err
+	}
+
+	switch {
+	case meta != nil && meta.Dir:
+		return adapter.TypeDir, nil
+	case meta != nil:
+		return adapter.TypeFile, nil
+	case boltHasChildren(tx, p):
+		return adapter.TypeDir, nil
+	}
+
+	return "", nil
+}
+
+// boltGet returns the metadata stored for p, or nil if there is none
+func boltGet(tx *bolt.Tx, p string) (*boltMeta, error) {
+	v := tx.Bucket(boltMetaBucket).Get([]byte(p))
+	if v == nil {
+		return nil, nil
+	}
+
+	return boltDecode(v)
+}
+
+// boltDecode decodes metadata
+func boltDecode(v []byte) (*boltMeta, error) {
+	meta := &boltMeta{}
+
+	err := json.Unmarshal(v, meta)
+	if err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// boltSetMeta stores the metadata of key
+func boltSetMeta(tx *bolt.Tx, key []byte, meta *boltMeta) error {
+	v, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(boltMetaBucket).Put(key, v)
+}
+
+// boltPut stores a file, it fails if a parent of p is a file
+func boltPut(tx *bolt.Tx, op string, p string, contents []byte, visibility string) error {
+	err := boltCheckParents(tx, op, p)
+	if err != nil {
+		return err
+	}
+
+	key := []byte(p)
+
+	err = tx.Bucket(boltContentsBucket).Put(key, copyBytes(contents))
+	if err != nil {
+		return err
+	}
+
+	return boltSetMeta(tx, key, &boltMeta{Size: int64(len(contents)), Visibility: visibility, LastModified: time.Now()})
+}
+
+// boltMove moves the metadata and contents stored at from to to, replacing what was there
+func boltMove(tx *bolt.Tx, from []byte, to []byte) error {
+	for _, name := range [][]byte{boltMetaBucket, boltContentsBucket} {
+		b := tx.Bucket(name)
+
+		v := b.Get(from)
+		if v == nil {
+			// Directories and directories implied by their contents have nothing to move
+			if err := b.Delete(to); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := b.Put(to, copyBytes(v)); err != nil {
+			return err
+		}
+
+		if err := b.Delete(from); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// boltDelete deletes the metadata and contents of key
+func boltDelete(tx *bolt.Tx, key []byte) error {
+	for _, name := range [][]byte{boltMetaBucket, boltContentsBucket} {
+		if err := tx.Bucket(name).Delete(key); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// boltHasChildren reports whether there are entries in the directory p
+func boltHasChildren(tx *bolt.Tx, p string) bool {
+	prefix := []byte(p + "/")
+
+	k, _ := tx.Bucket(boltMetaBucket).Cursor().Seek(prefix)
+
+	return k != nil && bytes.HasPrefix(k, prefix)
+}
+
+// boltChildren returns the keys of the entries in the directory p, or of every entry for the root
+func boltChildren(tx *bolt.Tx, p string) [][]byte {
+	prefix := []byte(p + "/")
+	if p == "" {
+		prefix = nil
+	}
+
+	var keys [][]byte
+
+	c := tx.Bucket(boltMetaBucket).Cursor()
+
+	for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+		keys = append(keys, copyBytes(k))
+	}
+
+	return keys
+}
+
+// boltCheckParents fails when one of the parents of p is a file
+func boltCheckParents(tx *bolt.Tx, op string, p string) error {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		meta, err := boltGet(tx, dir)
+		if err != nil {
+			return err
+		}
+
+		if meta != nil && !meta.Dir {
+			return adapter.WrapDirError(op, p, adapter.ErrDirectoryNotFound)
+		}
+
+		if meta != nil {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// attributes describes the entry stored at p
+func (m *boltMeta) attributes(p string) adapter.FileAttributes {
+	attributes := adapter.FileAttributes{
+		Path:         p,
+		Type:         adapter.TypeFile,
+		LastModified: m.LastModified,
+		Visibility:   m.Visibility,
+	}
+
+	if m.Dir {
+		attributes.Type = adapter.TypeDir
+
+		return attributes
+	}
+
+	attributes.Size = m.Size
+	attributes.MimeType = adapter.DetectMimeType(p, nil)
+
+	return attributes
+}
+
+// copyBytes returns a copy of b, bbolt's slices are only valid during the transaction
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+
+	return c
+}
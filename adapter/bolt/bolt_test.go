@@ -0,0 +1,326 @@
+package bolt
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+)
+
+func newBolt(t *testing.T) adapter.Adapter {
+	fs, err := NewBolt(filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		fs.(*Bolt).Close()
+	})
+
+	return fs
+}
+
+func TestBolt_Write(t *testing.T) {
+	fs := newBolt(t)
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != adapter.VisibilityPublic || !strings.HasPrefix(attributes.MimeType, "text/plain") || attributes.LastModified.IsZero() {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt/nested.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("missing.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("sub/test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("sub/test.txt", []byte("hello update"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 12 || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("expected Update to keep the visibility, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Put("sub", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists for a directory, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.WriteStream("empty", strings.NewReader(""))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err = fs.Read("empty")
+	if err != nil || contents == nil || len(contents) != 0 {
+		t.Logf("expected an empty file, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	_, err = fs.ReadStream("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Delete("sub/test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Delete("sub/test.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestBolt_Reopen(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "test.db")
+
+	fs, err := NewBolt(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fs.(*Bolt).Close()
+
+	fs, err = NewBolt(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.(*Bolt).Close()
+
+	contents, err := fs.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the file to be stored, got %q %v", contents, err)
+		t.Fail()
+	}
+}
+
+func TestBolt_Rename(t *testing.T) {
+	fs := newBolt(t)
+
+	if err := fs.CreateDir("dir/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"dir/a.txt", "dir/sub/b.txt", "file.txt", "dir.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := fs.Copy("file.txt", "copy/file.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("copy/file.txt")
+	if err != nil || string(contents) != "file.txt" {
+		t.Logf("unexpected contents of the copy: %q %v", contents, err)
+		t.Fail()
+	}
+
+	err = fs.Rename("dir", "moved/dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"moved/dir/a.txt", "moved/dir/sub/b.txt"} {
+		contents, err := fs.Read(p)
+		if err != nil || string(contents) != strings.TrimPrefix(p, "moved/") {
+			t.Logf("expected %s to be moved, got %q %v", p, contents, err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.DirectoryExists("moved/dir/empty"); !ok {
+		t.Log("expected the empty directory to be moved")
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("dir"); ok {
+		t.Log("expected dir to be gone")
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("dir.txt"); !ok {
+		t.Log("expected dir.txt to be left alone")
+		t.Fail()
+	}
+
+	err = fs.Rename("moved", "moved/inside")
+	if !errors.Is(err, errMoveIntoItself) {
+		t.Logf("expected errMoveIntoItself, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Rename("missing.txt", "other.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "other.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestBolt_Directories(t *testing.T) {
+	fs := newBolt(t)
+
+	err := fs.CreateDir("dir/empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("dir/empty")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "dir%/d.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir", "dir%"}},
+		{"dir", false, []string{"dir/b.txt", "dir/empty", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "dir%", "dir%/d.txt", "dir/b.txt", "dir/empty", "dir/sub", "dir/sub/c.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := adapter.Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err = fs.ListContents("a.txt", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("dir/sub", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("dir/sub")
+	if err != nil || !attributes.IsDir() || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("expected the implicit directory to keep its visibility, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"dir", "dir/sub/c.txt", "dir/empty"} {
+		if ok, _ := fs.Has(p); ok {
+			t.Logf("expected %s to be deleted", p)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.FileExists("dir%/d.txt"); !ok {
+		t.Log("expected dir% to be left alone")
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Logf("expected deleting a missing directory to succeed, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("a.txt"); ok {
+		t.Log("expected everything to be deleted")
+		t.Fail()
+	}
+}
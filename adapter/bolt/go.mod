@@ -0,0 +1,17 @@
+module github.com/edwin-luijten/go_flysystem/adapter/bolt
+
+go 1.24.0
+
+require (
+	github.com/edwin-luijten/go_flysystem v0.1.0
+	go.etcd.io/bbolt v1.4.3
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b // indirect
+	golang.org/x/sys v0.29.0 // indirect
+)
+
+// The adapter is developed against the core next to it, users of the module get the required version
+replace github.com/edwin-luijten/go_flysystem => ../..
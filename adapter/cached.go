@@ -0,0 +1,324 @@
+package adapter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"path"
+	"sync"
+)
+
+// CacheStore keeps the values cached by Cached, the keys are opaque strings.
+// Stores decide how long values are kept, Get reports expired values as missing
+type CacheStore interface {
+	// Get returns the value stored under key, ok is false when there is none
+	Get(key string) (value []byte, ok bool, err error)
+	// Set stores value under key
+	Set(key string, value []byte) error
+	// Delete removes the values stored under keys
+	Delete(keys ...string) error
+	// DeletePrefix removes every value whose key starts with prefix
+	DeletePrefix(prefix string) error
+}
+
+// The kinds of values cached for a path
+const (
+	cacheRead      = "read"
+	cacheStat      = "stat"
+	cacheHas       = "has"
+	cacheFile      = "file"
+	cacheDir       = "dir"
+	cacheList      = "list"
+	cacheListDeep  = "deep"
+	cacheSeparator = "/:"
+)
+
+var cacheKinds = []string{cacheRead, cacheStat, cacheHas, cacheFile, cacheDir, cacheList, cacheListDeep}
+
+// Cached keeps the contents, attributes, existence checks and listings read from another adapter in a CacheStore.
+// Every change through Cached invalidates what it affects: the path itself, everything under it,
+// and the attributes, existence checks and listings of the directories it is in.
+// Changes made to the inner adapter directly are only noticed when the cached values expire.
+// A cache that fails to answer is treated as a miss, when invalidating fails the change returns the error.
+// A value read while a change was invalidated is not cached, it may be older than the change
+type Cached struct {
+	inner      Adapter
+	store      CacheStore
+	lock       sync.RWMutex
+	generation uint64
+}
+
+// NewCached creates a new instance of Cached
+func NewCached(inner Adapter, store CacheStore) Adapter {
+	return &Cached{inner: inner, store: store}
+}
+
+// Write a new file
+func (a *Cached) Write(path string, contents []byte) error {
+	return a.invalidate(a.inner.Write(path, contents), path)
+}
+
+// Update a file
+func (a *Cached) Update(path string, contents []byte) error {
+	return a.invalidate(a.inner.Update(path, contents), path)
+}
+
+// Put creates or overwrites a file
+func (a *Cached) Put(path string, contents []byte) error {
+	return a.invalidate(a.inner.Put(path, contents), path)
+}
+
+// Read a file, the contents are cached
+func (a *Cached) Read(path string) ([]byte, error) {
+	key := cacheKey(path, cacheRead)
+
+	if contents, ok := a.get(key); ok {
+		return contents, nil
+	}
+
+	generation := a.currentGeneration()
+
+	contents, err := a.inner.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	a.set(key, contents, generation)
+
+	return contents, nil
+}
+
+// WriteStream writes a new file from a stream
+func (a *Cached) WriteStream(path string, contents io.Reader) error {
+	return a.invalidate(a.inner.WriteStream(path, contents), path)
+}
+
+// ReadStream opens a file for reading, the caller must close it.
+// Contents cached by Read are served from the cache, streams themselves are not cached
+func (a *Cached) ReadStream(path string) (io.ReadCloser, error) {
+	if contents, ok := a.get(cacheKey(path, cacheRead)); ok {
+		return io.NopCloser(bytes.NewReader(contents)), nil
+	}
+
+	return a.inner.ReadStream(path)
+}
+
+// Rename a file or directory
+func (a *Cached) Rename(path string, newPath string) error {
+	return a.invalidate(a.inner.Rename(path, newPath), path, newPath)
+}
+
+// Copy a file
+func (a *Cached) Copy(path string, newPath string) error {
+	return a.invalidate(a.inner.Copy(path, newPath), newPath)
+}
+
+// Delete a file
+func (a *Cached) Delete(path string) error {
+	return a.invalidate(a.inner.Delete(path), path)
+}
+
+// CreateDir creates a directory
+func (a *Cached) CreateDir(dir string) error {
+	return a.invalidate(a.inner.CreateDir(dir), dir)
+}
+
+// DeleteDir deletes a directory
+func (a *Cached) DeleteDir(dir string) error {
+	return a.invalidate(a.inner.DeleteDir(dir), dir)
+}
+
+// SetVisibility sets a file or directory to public or private
+func (a *Cached) SetVisibility(path string, visibility string) error {
+	return a.invalidate(a.inner.SetVisibility(path, visibility), path)
+}
+
+// Has checks if a file or directory exists, the answer is cached
+func (a *Cached) Has(path string) (bool, error) {
+	return a.exists(path, cacheHas, a.inner.Has)
+}
+
+// FileExists checks if a file exists, the answer is cached
+func (a *Cached) FileExists(path string) (bool, error) {
+	return a.exists(path, cacheFile, a.inner.FileExists)
+}
+
+// DirectoryExists checks if a directory exists, the answer is cached
+func (a *Cached) DirectoryExists(dir string) (bool, error) {
+	return a.exists(dir, cacheDir, a.inner.DirectoryExists)
+}
+
+// Stat returns the attributes of a file or directory, the attributes are cached
+func (a *Cached) Stat(path string) (FileAttributes, error) {
+	key := cacheKey(path, cacheStat)
+
+	var attributes FileAttributes
+
+	if v, ok := a.get(key); ok && json.Unmarshal(v, &attributes) == nil {
+		return attributes, nil
+	}
+
+	generation := a.currentGeneration()
+
+	attributes, err := a.inner.Stat(path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+
+	if v, err := json.Marshal(attributes); err == nil {
+		a.set(key, v, generation)
+	}
+
+	return attributes, nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// The whole listing is read and cached at once
+func (a *Cached) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	key := cacheKey(dir, cacheList)
+	if deep {
+		key = cacheKey(dir, cacheListDeep)
+	}
+
+	var contents []FileAttributes
+
+	if v, ok := a.get(key); ok && json.Unmarshal(v, &contents) == nil {
+		return newSliceListing(contents), nil
+	}
+
+	generation := a.currentGeneration()
+
+	listing, err := a.inner.ListContents(dir, deep)
+	if err != nil {
+		return nil, err
+	}
+
+	contents, err = Collect(listing)
+	if err != nil {
+		return nil, err
+	}
+
+	if v, err := json.Marshal(contents); err == nil {
+		a.set(key, v, generation)
+	}
+
+	return newSliceListing(contents), nil
+}
+
+// Unwrap returns the inner adapter
+func (a *Cached) Unwrap() Adapter {
+	return a.inner
+}
+
+// exists answers an existence check from the cache or asks the inner adapter
+func (a *Cached) exists(path string, kind string, check func(path string) (bool, error)) (bool, error) {
+	key := cacheKey(path, kind)
+
+	if v, ok := a.get(key); ok && len(v) == 1 {
+		return v[0] == '1', nil
+	}
+
+	generation := a.currentGeneration()
+
+	ok, err := check(path)
+	if err != nil {
+		return false, err
+	}
+
+	v := []byte{'0'}
+	if ok {
+		v[0] = '1'
+	}
+
+	a.set(key, v, generation)
+
+	return ok, nil
+}
+
+// invalidate removes the cached values of the changed paths, everything under them and the directories they are in.
+// It runs even when the change failed since it may have been applied partially
+func (a *Cached) invalidate(err error, paths ...string) error {
+	a.lock.Lock()
+	a.generation++
+	a.lock.Unlock()
+
+	var invalidateErr error
+
+	for _, p := range paths {
+		p = normalizePath(p)
+
+		prefix := p + "/"
+		if p == "" {
+			prefix = ""
+		}
+
+		if err := a.store.DeletePrefix(prefix); err != nil && invalidateErr == nil {
+			invalidateErr = err
+		}
+
+		for dir := p; dir != ""; {
+			dir = path.Dir(dir)
+			if dir == "." {
+				dir = ""
+			}
+
+			keys := make([]string, len(cacheKinds))
+			for i, kind := range cacheKinds {
+				keys[i] = cacheKey(dir, kind)
+			}
+
+			if err := a.store.Delete(keys...); err != nil && invalidateErr == nil {
+				invalidateErr = err
+			}
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if invalidateErr != nil {
+		return wrapError("invalidate", paths[0], invalidateErr)
+	}
+
+	return nil
+}
+
+// get returns a cached value, a failing store is a miss
+func (a *Cached) get(key string) ([]byte, bool) {
+	v, ok, err := a.store.Get(key)
+	if err != nil {
+		return nil, false
+	}
+
+	return v, ok
+}
+
+// currentGeneration returns the number of invalidations so far, taken before reading a value to cache
+func (a *Cached) currentGeneration() uint64 {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	return a.generation
+}
+
+// set caches a value unless something was invalidated since generation, the value may be older than that change.
+// Failing to cache it is ignored since the value is read again
+func (a *Cached) set(key string, v []byte, generation uint64) {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	if a.generation != generation {
+		return
+	}
+
+	_ = a.store.Set(key, v)
+}
+
+// cacheKey returns the key of the value of a kind cached for path, like "dir/file.txt/:read".
+// The keys of a path and everything under it start with the path and a slash
+func cacheKey(p string, kind string) string {
+	return normalizePath(p) + cacheSeparator + kind
+}
@@ -0,0 +1,341 @@
+package adapter
+
+import (
+	"errors"
+	"io"
+	"strings"
+	"sync/atomic"
+	"testing"
+)
+
+// countingAdapter counts the reads that reach the adapter it wraps
+type countingAdapter struct {
+	Adapter
+	reads int64
+}
+
+func (a *countingAdapter) count() int64 {
+	return atomic.LoadInt64(&a.reads)
+}
+
+func (a *countingAdapter) Read(path string) ([]byte, error) {
+	atomic.AddInt64(&a.reads, 1)
+
+	return a.Adapter.Read(path)
+}
+
+func (a *countingAdapter) Has(path string) (bool, error) {
+	atomic.AddInt64(&a.reads, 1)
+
+	return a.Adapter.Has(path)
+}
+
+func (a *countingAdapter) DirectoryExists(dir string) (bool, error) {
+	atomic.AddInt64(&a.reads, 1)
+
+	return a.Adapter.DirectoryExists(dir)
+}
+
+func (a *countingAdapter) Stat(path string) (FileAttributes, error) {
+	atomic.AddInt64(&a.reads, 1)
+
+	return a.Adapter.Stat(path)
+}
+
+func (a *countingAdapter) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	atomic.AddInt64(&a.reads, 1)
+
+	return a.Adapter.ListContents(dir, deep)
+}
+
+func newCached(t *testing.T, store CacheStore) (Adapter, *countingAdapter) {
+	inner := &countingAdapter{Adapter: NewMemory()}
+
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if err := inner.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return NewCached(inner, store), inner
+}
+
+func listPaths(t *testing.T, a Adapter, dir string, deep bool) string {
+	listing, err := a.ListContents(dir, deep)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := Collect(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, attributes := range contents {
+		paths = append(paths, attributes.Path)
+	}
+
+	return strings.Join(paths, ",")
+}
+
+func TestCached_Read(t *testing.T) {
+	fs, inner := newCached(t, NewLRUStore(0, 0))
+
+	for i := 0; i < 3; i++ {
+		contents, err := fs.Read("dir/b.txt")
+		if err != nil || string(contents) != "dir/b.txt" {
+			t.Logf("unexpected contents: %q %v", contents, err)
+			t.Fail()
+		}
+
+		if ok, _ := fs.Has("a.txt"); !ok {
+			t.Log("expected a.txt to exist")
+			t.Fail()
+		}
+
+		if ok, _ := fs.DirectoryExists("missing"); ok {
+			t.Log("expected missing to not exist")
+			t.Fail()
+		}
+
+		attributes, err := fs.Stat("a.txt")
+		if err != nil || attributes.Size != 5 || attributes.MimeType == "" {
+			t.Logf("unexpected attributes: %+v %v", attributes, err)
+			t.Fail()
+		}
+
+		if paths := listPaths(t, fs, "dir", false); paths != "dir/b.txt,dir/sub" {
+			t.Logf("unexpected listing: %s", paths)
+			t.Fail()
+		}
+	}
+
+	if inner.count() != 5 {
+		t.Logf("expected every read to reach the inner adapter once, got %d reads", inner.count())
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, _ := io.ReadAll(r)
+	r.Close()
+
+	if string(contents) != "dir/b.txt" || inner.count() != 5 {
+		t.Logf("expected the stream to be served from the cache, got %q after %d reads", contents, inner.count())
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestCached_Invalidate(t *testing.T) {
+	fs, _ := newCached(t, NewLRUStore(0, 0))
+
+	warm := func() {
+		fs.Read("dir/sub/c.txt")
+		fs.Has("dir/new.txt")
+		fs.DirectoryExists("moved")
+		listPaths(t, fs, "", true)
+		listPaths(t, fs, "dir", false)
+	}
+
+	warm()
+
+	if err := fs.Write("dir/new.txt", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := fs.Has("dir/new.txt"); !ok {
+		t.Log("expected Write to invalidate the existence check")
+		t.Fail()
+	}
+
+	if paths := listPaths(t, fs, "dir", false); paths != "dir/b.txt,dir/new.txt,dir/sub" {
+		t.Logf("expected Write to invalidate the listing of the parent, got %s", paths)
+		t.Fail()
+	}
+
+	if paths := listPaths(t, fs, "", true); !strings.Contains(paths, "dir/new.txt") {
+		t.Logf("expected Write to invalidate the deep listing of the root, got %s", paths)
+		t.Fail()
+	}
+
+	warm()
+
+	if err := fs.Put("dir/sub/c.txt", []byte("changed")); err != nil {
+		t.Fatal(err)
+	}
+
+	if contents, _ := fs.Read("dir/sub/c.txt"); string(contents) != "changed" {
+		t.Logf("expected Put to invalidate the contents, got %q", contents)
+		t.Fail()
+	}
+
+	warm()
+
+	if err := fs.Rename("dir", "moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Read("dir/sub/c.txt"); !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected Rename to invalidate everything under the directory, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("moved"); !ok {
+		t.Log("expected Rename to invalidate the destination")
+		t.Fail()
+	}
+
+	if contents, _ := fs.Read("moved/sub/c.txt"); string(contents) != "changed" {
+		t.Logf("unexpected contents after Rename: %q", contents)
+		t.Fail()
+	}
+
+	if err := fs.SetVisibility("moved/sub/c.txt", VisibilityPrivate); err != nil {
+		t.Fatal(err)
+	}
+
+	if attributes, _ := fs.Stat("moved/sub/c.txt"); attributes.Visibility != VisibilityPrivate {
+		t.Logf("expected SetVisibility to invalidate the attributes, got %+v", attributes)
+		t.Fail()
+	}
+
+	if err := fs.Copy("a.txt", "moved/sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if contents, _ := fs.Read("moved/sub/c.txt"); string(contents) != "a.txt" {
+		t.Logf("expected Copy to invalidate the destination, got %q", contents)
+		t.Fail()
+	}
+
+	if err := fs.DeleteDir("moved"); err != nil {
+		t.Fatal(err)
+	}
+
+	if paths := listPaths(t, fs, "", true); paths != "a.txt" {
+		t.Logf("expected DeleteDir to invalidate the listings, got %s", paths)
+		t.Fail()
+	}
+
+	if err := fs.Delete("a.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := fs.Has("a.txt"); ok {
+		t.Log("expected Delete to invalidate the existence check")
+		t.Fail()
+	}
+}
+
+func TestCached_AdapterStore(t *testing.T) {
+	disk := NewMemory()
+	fs, inner := newCached(t, NewAdapterStore(disk, "cache", 0))
+
+	for i := 0; i < 2; i++ {
+		contents, err := fs.Read("dir/sub/c.txt")
+		if err != nil || string(contents) != "dir/sub/c.txt" {
+			t.Logf("unexpected contents: %q %v", contents, err)
+			t.Fail()
+		}
+
+		if paths := listPaths(t, fs, "", false); paths != "a.txt,dir" {
+			t.Logf("unexpected listing: %s", paths)
+			t.Fail()
+		}
+	}
+
+	if inner.count() != 2 {
+		t.Logf("expected the values to be cached, got %d reads", inner.count())
+		t.Fail()
+	}
+
+	if paths := listPaths(t, disk, "cache", true); !strings.Contains(paths, "cache/ddir/dsub/dc.txt/f%3Aread") {
+		t.Logf("expected the contents to be stored on the adapter, got %s", paths)
+		t.Fail()
+	}
+
+	if err := fs.Delete("dir/sub/c.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fs.Read("dir/sub/c.txt"); !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound after Delete, got %v", err)
+		t.Fail()
+	}
+
+	listing, err := disk.ListContents("cache", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := Collect(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, attributes := range contents {
+		if attributes.IsFile() {
+			t.Logf("expected the cached values to be deleted, got %s", attributes.Path)
+			t.Fail()
+		}
+	}
+}
+
+// slowReader reads a file and waits to return it until it is released
+type slowReader struct {
+	Adapter
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (a *slowReader) Read(path string) ([]byte, error) {
+	contents, err := a.Adapter.Read(path)
+
+	close(a.read)
+	<-a.release
+
+	return contents, err
+}
+
+func TestCached_ReadDuringWrite(t *testing.T) {
+	inner := &slowReader{Adapter: NewMemory(), read: make(chan struct{}), release: make(chan struct{})}
+	store := NewLRUStore(0, 0)
+	fs := NewCached(inner, store)
+
+	if err := inner.Write("a.txt", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		fs.Read("a.txt")
+	}()
+
+	// The read got the old contents before the change, it must not cache them after it
+	<-inner.read
+
+	if err := fs.Put("a.txt", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	close(inner.release)
+	<-done
+
+	if v, ok, _ := store.Get(cacheKey("a.txt", cacheRead)); ok {
+		t.Logf("expected the old contents not to be cached, got %q", v)
+		t.Fail()
+	}
+}
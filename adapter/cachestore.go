@@ -0,0 +1,319 @@
+package adapter
+
+import (
+	"container/list"
+	"encoding/binary"
+	"errors"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LRUStore is an in-memory CacheStore that evicts the least recently used values once it holds more than its size,
+// it is safe for concurrent use
+type LRUStore struct {
+	lock     sync.Mutex
+	maxBytes int64
+	ttl      time.Duration
+	size     int64
+	entries  map[string]*list.Element
+	order    *list.List
+	now      func() time.Time
+}
+
+// lruEntry is a value in an LRUStore
+type lruEntry struct {
+	key     string
+	value   []byte
+	expires time.Time
+}
+
+// NewLRUStore creates a new instance of LRUStore that holds up to maxBytes of keys and values,
+// values expire after ttl. A maxBytes or ttl of 0 means no limit
+func NewLRUStore(maxBytes int64, ttl time.Duration) *LRUStore {
+	return &LRUStore{
+		maxBytes: maxBytes,
+		ttl:      ttl,
+		entries:  map[string]*list.Element{},
+		order:    list.New(),
+		now:      time.Now,
+	}
+}
+
+// Get returns the value stored under key
+func (s *LRUStore) Get(key string) ([]byte, bool, error) {
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	e, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+
+	entry := e.Value.(*lruEntry)
+
+	if !entry.expires.IsZero() && !s.now().Before(entry.expires) {
+		s.remove(e)
+
+		return nil, false, nil
+	}
+
+	s.order.MoveToFront(e)
+
+	return copyBytes(entry.value), true, nil
+}
+
+// Set stores value under key, a value larger than the store is not kept
+func (s *LRUStore) Set(key string, value []byte) error {
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	if e, ok := s.entries[key]; ok {
+		s.remove(e)
+	}
+
+	size := int64(len(key) + len(value))
+	if s.maxBytes > 0 && size > s.maxBytes {
+		return nil
+	}
+
+	entry := &lruEntry{key: key, value: copyBytes(value)}
+	if s.ttl > 0 {
+		entry.expires = s.now().Add(s.ttl)
+	}
+
+	s.entries[key] = s.order.PushFront(entry)
+	s.size += size
+
+	for s.maxBytes > 0 && s.size > s.maxBytes {
+		s.remove(s.order.Back())
+	}
+
+	return nil
+}
+
+// Delete removes the values stored under keys
+func (s *LRUStore) Delete(keys ...string) error {
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	for _, key := range keys {
+		if e, ok := s.entries[key]; ok {
+			s.remove(e)
+		}
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every value whose key starts with prefix
+func (s *LRUStore) DeletePrefix(prefix string) error {
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	for key, e := range s.entries {
+		if strings.HasPrefix(key, prefix) {
+			s.remove(e)
+		}
+	}
+
+	return nil
+}
+
+// Len returns the number of values in the store
+func (s *LRUStore) Len() int {
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	return len(s.entries)
+}
+
+// remove drops an entry
+func (s *LRUStore) remove(e *list.Element) {
+	entry := s.order.Remove(e).(*lruEntry)
+
+	delete(s.entries, entry.key)
+	s.size -= int64(len(entry.key) + len(entry.value))
+}
+
+// AdapterStore is a CacheStore that keeps every value in a file of another adapter, such as a local disk
+// caching a remote store. Keys are split at their slashes, every segment but the last is a directory,
+// so deleting the keys under a path deletes a single directory
+type AdapterStore struct {
+	adapter Adapter
+	dir     string
+	ttl     time.Duration
+	now     func() time.Time
+}
+
+// NewAdapterStore creates a new instance of AdapterStore that keeps its files in dir of a,
+// values expire after ttl. A ttl of 0 never expires
+func NewAdapterStore(a Adapter, dir string, ttl time.Duration) *AdapterStore {
+	return &AdapterStore{
+		adapter: a,
+		dir:     normalizePath(dir),
+		ttl:     ttl,
+		now:     time.Now,
+	}
+}
+
+// Get returns the value stored under key
+func (s *AdapterStore) Get(key string) ([]byte, bool, error) {
+	v, err := s.adapter.Read(s.path(key))
+	if errors.Is(err, ErrFileNotFound) {
+		return nil, false, nil
+	}
+
+	if err != nil {
+		return nil, false, err
+	}
+
+	// Every file starts with the time it expires at in nanoseconds, 0 never expires
+	if len(v) < 8 {
+		return nil, false, nil
+	}
+
+	expires := int64(binary.BigEndian.Uint64(v))
+	if expires != 0 && s.now().UnixNano() >= expires {
+		return nil, false, s.Delete(key)
+	}
+
+	return v[8:], true, nil
+}
+
+// Set stores value under key
+func (s *AdapterStore) Set(key string, value []byte) error {
+	var expires int64
+	if s.ttl > 0 {
+		expires = s.now().Add(s.ttl).UnixNano()
+	}
+
+	v := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(v, uint64(expires))
+	copy(v[8:], value)
+
+	p := s.path(key)
+
+	err := s.adapter.Put(p, v)
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		return err
+	}
+
+	// Not every adapter creates the missing parent directories of a file
+	err = s.createParents(p)
+	if err != nil {
+		return err
+	}
+
+	return s.adapter.Put(p, v)
+}
+
+// Delete removes the values stored under keys
+func (s *AdapterStore) Delete(keys ...string) error {
+	for _, key := range keys {
+		err := s.adapter.Delete(s.path(key))
+		if err != nil && !errors.Is(err, ErrFileNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DeletePrefix removes every value whose key starts with prefix.
+// A prefix that ends with a slash deletes its directory, otherwise the directory the prefix ends in is listed
+func (s *AdapterStore) DeletePrefix(prefix string) error {
+	segments := strings.Split(prefix, "/")
+	last := len(segments) - 1
+	dir := s.dirPath(segments[:last])
+
+	if last > 0 && segments[last] == "" {
+		err := s.adapter.DeleteDir(dir)
+		if err != nil && !errors.Is(err, ErrDirectoryNotFound) {
+			return err
+		}
+
+		return nil
+	}
+
+	listing, err := s.adapter.ListContents(dir, false)
+	if errors.Is(err, ErrDirectoryNotFound) {
+		return nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	contents, err := Collect(listing)
+	if err != nil {
+		return err
+	}
+
+	// Escaping is done per character, so the names of the keys with the prefix start with the escaped prefix
+	escaped := url.QueryEscape(segments[last])
+
+	for _, attributes := range contents {
+		// Skip the marker of the name
+		if !strings.HasPrefix(path.Base(attributes.Path)[1:], escaped) {
+			continue
+		}
+
+		if attributes.IsDir() {
+			err = s.adapter.DeleteDir(attributes.Path)
+		} else {
+			err = s.adapter.Delete(attributes.Path)
+		}
+
+		if err != nil && !errors.Is(err, ErrFileNotFound) && !errors.Is(err, ErrDirectoryNotFound) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// path returns the path of the file that holds the value of key
+func (s *AdapterStore) path(key string) string {
+	segments := strings.Split(key, "/")
+	last := len(segments) - 1
+
+	return path.Join(s.dirPath(segments[:last]), "f"+url.QueryEscape(segments[last]))
+}
+
+// dirPath returns the directory that holds the keys starting with segments, each followed by a slash.
+// Directories and files are marked so a key can't collide with the directory of a longer key
+func (s *AdapterStore) dirPath(segments []string) string {
+	p := s.dir
+
+	for _, segment := range segments {
+		p = path.Join(p, "d"+url.QueryEscape(segment))
+	}
+
+	return p
+}
+
+// createParents creates the missing directories of the file at p
+func (s *AdapterStore) createParents(p string) error {
+	var dirs []string
+
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		dirs = append(dirs, dir)
+	}
+
+	for i := len(dirs) - 1; i >= 0; i-- {
+		err := s.adapter.CreateDir(dirs[i])
+		if err != nil && !errors.Is(err, ErrFileExists) {
+			return err
+		}
+	}
+
+	return nil
+}
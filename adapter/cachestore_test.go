@@ -0,0 +1,177 @@
+package adapter
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+// unlistable fails to list, deleting the keys under a path must not need a listing
+type unlistable struct {
+	Adapter
+}
+
+func (a unlistable) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	return nil, errors.New("listing the cache")
+}
+
+func testCacheStore(t *testing.T, store CacheStore, advance func(d time.Duration)) {
+	if err := store.Set("a/:read", []byte("a")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Set("a/b/:read", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.Set("ab/:read", []byte("ab")); err != nil {
+		t.Fatal(err)
+	}
+
+	v, ok, err := store.Get("a/:read")
+	if err != nil || !ok || string(v) != "a" {
+		t.Logf("unexpected value: %q %v %v", v, ok, err)
+		t.Fail()
+	}
+
+	_, ok, err = store.Get("missing")
+	if err != nil || ok {
+		t.Logf("expected a miss, got %v %v", ok, err)
+		t.Fail()
+	}
+
+	if err := store.DeletePrefix("a/"); err != nil {
+		t.Fatal(err)
+	}
+
+	for key, expected := range map[string]bool{"a/:read": false, "a/b/:read": false, "ab/:read": true} {
+		if _, ok, _ := store.Get(key); ok != expected {
+			t.Logf("expected %s to be cached: %v", key, expected)
+			t.Fail()
+		}
+	}
+
+	if err := store.Delete("ab/:read", "missing"); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok, _ := store.Get("ab/:read"); ok {
+		t.Log("expected ab/:read to be deleted")
+		t.Fail()
+	}
+
+	if err := store.Set("ttl", []byte("ttl")); err != nil {
+		t.Fatal(err)
+	}
+
+	advance(time.Minute - time.Second)
+
+	if _, ok, _ := store.Get("ttl"); !ok {
+		t.Log("expected the value to be cached until it expires")
+		t.Fail()
+	}
+
+	advance(time.Second)
+
+	if _, ok, _ := store.Get("ttl"); ok {
+		t.Log("expected the value to expire")
+		t.Fail()
+	}
+}
+
+func TestLRUStore(t *testing.T) {
+	now := time.Now()
+
+	store := NewLRUStore(0, time.Minute)
+	store.now = func() time.Time {
+		return now
+	}
+
+	testCacheStore(t, store, func(d time.Duration) {
+		now = now.Add(d)
+	})
+
+	if store.Len() != 0 {
+		t.Logf("expected the expired value to be removed, got %d values", store.Len())
+		t.Fail()
+	}
+}
+
+func TestLRUStore_Evict(t *testing.T) {
+	store := NewLRUStore(33, 0)
+
+	for _, key := range []string{"key1", "key2", "key3"} {
+		if err := store.Set(key, []byte("0123456")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// key1 was used last, so key2 is evicted
+	store.Get("key1")
+	store.Set("key4", []byte("0123456"))
+
+	for key, expected := range map[string]bool{"key1": true, "key2": false, "key3": true, "key4": true} {
+		if _, ok, _ := store.Get(key); ok != expected {
+			t.Logf("expected %s to be cached: %v", key, expected)
+			t.Fail()
+		}
+	}
+
+	store.Set("large", make([]byte, 100))
+
+	if _, ok, _ := store.Get("large"); ok || store.Len() != 3 {
+		t.Logf("expected a value larger than the store to be skipped, got %d values", store.Len())
+		t.Fail()
+	}
+}
+
+func TestAdapterStore(t *testing.T) {
+	now := time.Now()
+
+	store := NewAdapterStore(NewMemory(), "cache", time.Minute)
+	store.now = func() time.Time {
+		return now
+	}
+
+	testCacheStore(t, store, func(d time.Duration) {
+		now = now.Add(d)
+	})
+
+	empty := NewAdapterStore(NewMemory(), "cache", 0)
+
+	if err := empty.DeletePrefix(""); err != nil {
+		t.Logf("expected a missing directory to be empty, got %v", err)
+		t.Fail()
+	}
+
+	// Local only creates the directory a file is in, not its parents
+	local, err := NewLocal(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	store = NewAdapterStore(local, "cache", time.Minute)
+	store.now = func() time.Time {
+		return now
+	}
+
+	testCacheStore(t, store, func(d time.Duration) {
+		now = now.Add(d)
+	})
+
+	store = NewAdapterStore(unlistable{NewMemory()}, "cache", 0)
+
+	if err := store.Set("a/b/:read", []byte("b")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := store.DeletePrefix("a/"); err != nil {
+		t.Logf("expected the directory of the prefix to be deleted without listing, got %v", err)
+		t.Fail()
+	}
+
+	if _, ok, _ := store.Get("a/b/:read"); ok {
+		t.Log("expected a/b/:read to be deleted")
+		t.Fail()
+	}
+}
@@ -0,0 +1,365 @@
+package adapter
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+)
+
+var errUnknownCompression = errors.New("unknown compression")
+
+// Compression is the codec used to compress the contents of a file
+type Compression byte
+
+const (
+	// CompressionGzip compresses with gzip
+	CompressionGzip Compression = 1
+
+	// CompressionZstd compresses with Zstandard
+	CompressionZstd Compression = 2
+)
+
+// compressedMagic starts every compressed file, the first byte is never valid UTF-8 so text files don't start with it
+const compressedMagic = "\xffFLZ"
+
+// compressedSizeUnknown is the size in the header of a streamed file, its size isn't known until it is written
+const compressedSizeUnknown = -1
+
+// compressedHeaderSize is the size of the header: the magic, the codec, the uncompressed size
+// and a checksum of them, so a file that only happens to start with the magic is not taken for a compressed one
+const compressedHeaderSize = len(compressedMagic) + 1 + 8 + 4
+
+// Compressed compresses the contents of files before they reach another adapter and decompresses them when they are read.
+// Every file starts with a header naming its codec and uncompressed size, files without one are read as they are.
+// This way files written with another codec, or before compression was enabled, keep reading correctly.
+// Stat reads the header to report the uncompressed size. Listings report the stored size, reading every header
+// would take a request per file. Streamed files are written without knowing their size, Stat reports their stored size
+type Compressed struct {
+	inner Adapter
+	codec Compression
+}
+
+// NewCompressed creates a new instance of Compressed that compresses new files with codec
+func NewCompressed(inner Adapter, codec Compression) (Adapter, error) {
+	if codec != CompressionGzip && codec != CompressionZstd {
+		return nil, errUnknownCompression
+	}
+
+	return &Compressed{inner: inner, codec: codec}, nil
+}
+
+// Write a new file
+func (a *Compressed) Write(path string, contents []byte) error {
+	compressed, err := a.compress(contents)
+	if err != nil {
+		return wrapError("write", path, err)
+	}
+
+	return a.inner.Write(path, compressed)
+}
+
+// Update a file
+func (a *Compressed) Update(path string, contents []byte) error {
+	compressed, err := a.compress(contents)
+	if err != nil {
+		return wrapError("update", path, err)
+	}
+
+	return a.inner.Update(path, compressed)
+}
+
+// Put creates or overwrites a file
+func (a *Compressed) Put(path string, contents []byte) error {
+	compressed, err := a.compress(contents)
+	if err != nil {
+		return wrapError("put", path, err)
+	}
+
+	return a.inner.Put(path, compressed)
+}
+
+// Read a file
+func (a *Compressed) Read(path string) ([]byte, error) {
+	r, err := a.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, wrapError("read", path, err)
+	}
+
+	return contents, nil
+}
+
+// WriteStream writes a new file from a stream, it is compressed while the inner adapter writes it.
+// The uncompressed size isn't known up front, so the header leaves it out
+func (a *Compressed) WriteStream(path string, contents io.Reader) error {
+	r, w := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		w.CloseWithError(a.compressStream(w, contents))
+	}()
+
+	err := a.inner.WriteStream(path, r)
+
+	// Unblock the compression if the inner adapter stopped reading early
+	r.Close()
+	<-done
+
+	return err
+}
+
+// ReadStream opens a file for reading, the caller must close it. The contents are decompressed while they are read
+func (a *Compressed) ReadStream(path string) (io.ReadCloser, error) {
+	r, err := a.inner.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	codec, _, body, err := readCompressedHeader(r)
+	if err != nil {
+		r.Close()
+
+		return nil, wrapError("read", path, err)
+	}
+
+	// Files without a header are read as they are
+	if codec == 0 {
+		return &compressedReader{Reader: body, stream: r}, nil
+	}
+
+	d, err := codec.reader(body)
+	if err != nil {
+		r.Close()
+
+		return nil, wrapError("read", path, err)
+	}
+
+	return &compressedReader{Reader: d, decoder: d, stream: r}, nil
+}
+
+// Rename a file or directory
+func (a *Compressed) Rename(path string, newPath string) error {
+	return a.inner.Rename(path, newPath)
+}
+
+// Copy a file
+func (a *Compressed) Copy(path string, newPath string) error {
+	return a.inner.Copy(path, newPath)
+}
+
+// Delete a file
+func (a *Compressed) Delete(path string) error {
+	return a.inner.Delete(path)
+}
+
+// CreateDir creates a directory
+func (a *Compressed) CreateDir(dir string) error {
+	return a.inner.CreateDir(dir)
+}
+
+// DeleteDir deletes a directory
+func (a *Compressed) DeleteDir(dir string) error {
+	return a.inner.DeleteDir(dir)
+}
+
+// SetVisibility sets a file or directory to public or private
+func (a *Compressed) SetVisibility(path string, visibility string) error {
+	return a.inner.SetVisibility(path, visibility)
+}
+
+// Has checks if a file or directory exists
+func (a *Compressed) Has(path string) (bool, error) {
+	return a.inner.Has(path)
+}
+
+// FileExists checks if a file exists
+func (a *Compressed) FileExists(path string) (bool, error) {
+	return a.inner.FileExists(path)
+}
+
+// DirectoryExists checks if a directory exists
+func (a *Compressed) DirectoryExists(dir string) (bool, error) {
+	return a.inner.DirectoryExists(dir)
+}
+
+// Stat returns the attributes of a file or directory, the size is the uncompressed size
+func (a *Compressed) Stat(path string) (FileAttributes, error) {
+	attributes, err := a.inner.Stat(path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+
+	return a.attributes(attributes)
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// The sizes are the stored sizes
+func (a *Compressed) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	return a.inner.ListContents(dir, deep)
+}
+
+// Unwrap returns the inner adapter
+func (a *Compressed) Unwrap() Adapter {
+	return a.inner
+}
+
+// attributes replaces the size of a compressed file by its uncompressed size, when the header has it
+func (a *Compressed) attributes(attributes FileAttributes) (FileAttributes, error) {
+	if attributes.IsDir() {
+		return attributes, nil
+	}
+
+	r, err := a.inner.ReadStream(attributes.Path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+
+	defer r.Close()
+
+	codec, size, _, err := readCompressedHeader(r)
+	if err != nil {
+		return FileAttributes{}, wrapError("stat", attributes.Path, err)
+	}
+
+	if codec != 0 && size != compressedSizeUnknown {
+		attributes.Size = size
+	}
+
+	return attributes, nil
+}
+
+// compress compresses contents that are in memory and prepends the header
+func (a *Compressed) compress(contents []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(a.header(int64(len(contents))))
+
+	w, err := a.codec.writer(buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(contents); err != nil {
+		w.Close()
+
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// compressStream writes the header and the compressed contents to w
+func (a *Compressed) compressStream(w io.Writer, contents io.Reader) error {
+	if _, err := w.Write(a.header(compressedSizeUnknown)); err != nil {
+		return err
+	}
+
+	cw, err := a.codec.writer(w)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(cw, contents); err != nil {
+		cw.Close()
+
+		return err
+	}
+
+	return cw.Close()
+}
+
+// header returns the header of a file of size uncompressed bytes
+func (a *Compressed) header(size int64) []byte {
+	h := make([]byte, compressedHeaderSize)
+	n := copy(h, compressedMagic)
+	h[n] = byte(a.codec)
+	binary.BigEndian.PutUint64(h[n+1:], uint64(size))
+	binary.BigEndian.PutUint32(h[n+9:], crc32.ChecksumIEEE(h[:n+9]))
+
+	return h
+}
+
+// readCompressedHeader reads the header of a file. The codec is 0 for a file without a valid header,
+// body then returns the whole file
+func readCompressedHeader(r io.Reader) (Compression, int64, io.Reader, error) {
+	h := make([]byte, compressedHeaderSize)
+
+	n, err := io.ReadFull(r, h)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return 0, 0, nil, err
+	}
+
+	checksum := compressedHeaderSize - 4
+
+	if n < compressedHeaderSize || string(h[:len(compressedMagic)]) != compressedMagic ||
+		binary.BigEndian.Uint32(h[checksum:]) != crc32.ChecksumIEEE(h[:checksum]) {
+		return 0, 0, io.MultiReader(bytes.NewReader(h[:n]), r), nil
+	}
+
+	codec := Compression(h[len(compressedMagic)])
+	if codec != CompressionGzip && codec != CompressionZstd {
+		return 0, 0, nil, errUnknownCompression
+	}
+
+	return codec, int64(binary.BigEndian.Uint64(h[len(compressedMagic)+1:])), r, nil
+}
+
+// writer returns a writer that compresses to w, Close flushes it without closing w
+func (c Compression) writer(w io.Writer) (io.WriteCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewWriter(w), nil
+	case CompressionZstd:
+		return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1))
+	}
+
+	return nil, errUnknownCompression
+}
+
+// reader returns a reader that decompresses r, Close releases it without closing r
+func (c Compression) reader(r io.Reader) (io.ReadCloser, error) {
+	switch c {
+	case CompressionGzip:
+		return gzip.NewReader(r)
+	case CompressionZstd:
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+
+		return d.IOReadCloser(), nil
+	}
+
+	return nil, errUnknownCompression
+}
+
+// compressedReader reads a file through its decoder, if it has one
+type compressedReader struct {
+	io.Reader
+	decoder io.Closer
+	stream  io.Closer
+}
+
+// Close releases the decoder and closes the stream
+func (r *compressedReader) Close() error {
+	if r.decoder != nil {
+		r.decoder.Close()
+	}
+
+	return r.stream.Close()
+}
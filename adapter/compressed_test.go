@@ -0,0 +1,260 @@
+package adapter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"time"
+)
+
+func TestCompressed_Write(t *testing.T) {
+	logs := bytes.Repeat([]byte(`{"level":"info","message":"request served"}`+"\n"), 2000)
+
+	for _, codec := range []Compression{CompressionGzip, CompressionZstd} {
+		inner := NewMemory()
+
+		fs, err := NewCompressed(inner, codec)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files := map[string][]byte{
+			"empty":         {},
+			"small.txt":     []byte("hello world"),
+			"logs/app.json": logs,
+		}
+
+		for p, contents := range files {
+			if err := fs.Write(p, contents); err != nil {
+				t.Fatal(err)
+			}
+
+			read, err := fs.Read(p)
+			if err != nil || !bytes.Equal(read, contents) {
+				t.Logf("codec %d: expected %s to be decompressed, got %d bytes %v", codec, p, len(read), err)
+				t.Fail()
+			}
+
+			attributes, err := fs.Stat(p)
+			if err != nil || attributes.Size != int64(len(contents)) {
+				t.Logf("codec %d: expected the size of %s to be %d, got %+v %v", codec, p, len(contents), attributes, err)
+				t.Fail()
+			}
+		}
+
+		stored, _ := inner.Stat("logs/app.json")
+		if stored.Size*10 > int64(len(logs)) {
+			t.Logf("codec %d: expected the logs to be compressed, got %d bytes", codec, stored.Size)
+			t.Fail()
+		}
+
+		err = fs.Update("small.txt", []byte("hello update"))
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		contents, err := fs.Read("small.txt")
+		if err != nil || string(contents) != "hello update" {
+			t.Logf("unexpected contents after Update: %q %v", contents, err)
+			t.Fail()
+		}
+
+		err = fs.Write("small.txt", []byte("hello"))
+		if !errors.Is(err, ErrFileExists) {
+			t.Logf("expected ErrFileExists, got %v", err)
+			t.Fail()
+		}
+
+		listing, err := fs.ListContents("", true)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listed, err := Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// Listings don't read the headers, they report the stored size
+		for _, attributes := range listed {
+			if attributes.Path == "logs/app.json" && attributes.Size != stored.Size {
+				t.Logf("expected the listed size to be %d, got %d", stored.Size, attributes.Size)
+				t.Fail()
+			}
+		}
+	}
+
+	_, err := NewCompressed(NewMemory(), 0)
+	if !errors.Is(err, errUnknownCompression) {
+		t.Logf("expected errUnknownCompression, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestCompressed_Stream(t *testing.T) {
+	inner := NewMemory()
+
+	fs, err := NewCompressed(inner, CompressionZstd)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents := bytes.Repeat([]byte("0123456789"), 10000)
+
+	err = fs.WriteStream("stream.txt", iotest.OneByteReader(bytes.NewReader(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The size of a streamed file isn't in its header, Stat reports the stored size
+	stored, err := inner.Stat("stream.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attributes, err := fs.Stat("stream.txt")
+	if err != nil || attributes.Size != stored.Size || stored.Size >= int64(len(contents)) {
+		t.Logf("expected the compressed size %d, got %+v %v", stored.Size, attributes, err)
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("stream.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := io.ReadAll(r)
+	r.Close()
+
+	if err != nil || !bytes.Equal(read, contents) {
+		t.Logf("expected the stream to be decompressed, got %d bytes %v", len(read), err)
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream.txt", strings.NewReader("hello"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestCompressed_Mixed(t *testing.T) {
+	inner := NewMemory()
+
+	// Files written before compression was enabled, one shorter than a header and one that starts like a header
+	magic := compressedMagic + "\x01 is what this file happens to start with"
+
+	for p, contents := range map[string]string{"plain.txt": "plain", "ab": "a", "magic.bin": magic} {
+		if err := inner.Write(p, []byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	gz, _ := NewCompressed(inner, CompressionGzip)
+	if err := gz.Write("old.txt", []byte("gzip")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, _ := NewCompressed(inner, CompressionZstd)
+	if err := fs.Write("new.txt", []byte("zstd")); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, expected := range map[string]string{"plain.txt": "plain", "ab": "a", "magic.bin": magic, "old.txt": "gzip", "new.txt": "zstd"} {
+		contents, err := fs.Read(p)
+		if err != nil || string(contents) != expected {
+			t.Logf("expected %s to contain %q, got %q %v", p, expected, contents, err)
+			t.Fail()
+		}
+
+		attributes, err := fs.Stat(p)
+		if err != nil || attributes.Size != int64(len(expected)) {
+			t.Logf("expected the size of %s to be %d, got %+v %v", p, len(expected), attributes, err)
+			t.Fail()
+		}
+	}
+
+	unknown := &Compressed{inner: inner, codec: 9}
+
+	if err := inner.Put("unknown", unknown.header(0)); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := fs.Read("unknown")
+	if !errors.Is(err, errUnknownCompression) {
+		t.Logf("expected errUnknownCompression, got %v", err)
+		t.Fail()
+	}
+}
+
+// streamStartAdapter reports when a WriteStream received its first byte
+type streamStartAdapter struct {
+	Adapter
+	started chan struct{}
+}
+
+func (a *streamStartAdapter) WriteStream(path string, contents io.Reader) error {
+	b := make([]byte, 1)
+
+	if _, err := io.ReadFull(contents, b); err != nil {
+		return err
+	}
+
+	close(a.started)
+
+	return a.Adapter.WriteStream(path, io.MultiReader(bytes.NewReader(b), contents))
+}
+
+func TestCompressed_StreamThrough(t *testing.T) {
+	inner := &streamStartAdapter{Adapter: NewMemory(), started: make(chan struct{})}
+
+	fs, err := NewCompressed(inner, CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, w := io.Pipe()
+	errs := make(chan error, 1)
+
+	go func() {
+		errs <- fs.WriteStream("stream.txt", r)
+	}()
+
+	// The inner adapter gets the header before any contents were written
+	select {
+	case <-inner.started:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the stream to reach the inner adapter before the contents ended")
+	}
+
+	w.Write([]byte("hello"))
+	w.Close()
+
+	if err := <-errs; err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := fs.Read("stream.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("unexpected contents: %q %v", contents, err)
+		t.Fail()
+	}
+
+	// A failing stream fails the write
+	fs, err = NewCompressed(NewMemory(), CompressionGzip)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	broken := errors.New("broken")
+
+	err = fs.WriteStream("broken.txt", io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(broken)))
+	if !errors.Is(err, broken) {
+		t.Logf("expected the read error, got %v", err)
+		t.Fail()
+	}
+}
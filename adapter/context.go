@@ -0,0 +1,263 @@
+package adapter
+
+import (
+	"context"
+	"io"
+)
+
+// ContextAdapter is an Adapter whose operations can be cancelled or given a deadline
+type ContextAdapter interface {
+	Adapter
+	WriteContext(ctx context.Context, path string, contents []byte) error
+	UpdateContext(ctx context.Context, path string, contents []byte) error
+	PutContext(ctx context.Context, path string, contents []byte) error
+	ReadContext(ctx context.Context, path string) ([]byte, error)
+	WriteStreamContext(ctx context.Context, path string, contents io.Reader) error
+	ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error)
+	RenameContext(ctx context.Context, path string, newPath string) error
+	CopyContext(ctx context.Context, path string, newPath string) error
+	DeleteContext(ctx context.Context, path string) error
+	CreateDirContext(ctx context.Context, dir string) error
+	DeleteDirContext(ctx context.Context, dir string) error
+	SetVisibilityContext(ctx context.Context, path string, visibility string) error
+	HasContext(ctx context.Context, path string) (bool, error)
+	FileExistsContext(ctx context.Context, path string) (bool, error)
+	DirectoryExistsContext(ctx context.Context, dir string) (bool, error)
+	StatContext(ctx context.Context, path string) (FileAttributes, error)
+	ListContentsContext(ctx context.Context, dir string, deep bool) (DirectoryListing, error)
+}
+
+// WithContext returns the context aware variant of an adapter.
+// Adapters that don't implement ContextAdapter themselves have the context checked
+// before every operation and while streaming or listing
+func WithContext(a Adapter) ContextAdapter {
+	if c, ok := a.(ContextAdapter); ok {
+		return c
+	}
+
+	return &contextAdapter{Adapter: a}
+}
+
+// contextAdapter adds context support to an adapter that lacks it
+type contextAdapter struct {
+	Adapter
+}
+
+// Unwrap returns the wrapped adapter
+func (a *contextAdapter) Unwrap() Adapter {
+	return a.Adapter
+}
+
+// WriteContext writes a new file
+func (a *contextAdapter) WriteContext(ctx context.Context, path string, contents []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.Write(path, contents)
+}
+
+// UpdateContext updates a file
+func (a *contextAdapter) UpdateContext(ctx context.Context, path string, contents []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.Update(path, contents)
+}
+
+// PutContext creates or overwrites a file
+func (a *contextAdapter) PutContext(ctx context.Context, path string, contents []byte) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.Put(path, contents)
+}
+
+// ReadContext reads a file
+func (a *contextAdapter) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	return a.Read(path)
+}
+
+// WriteStreamContext writes a new file from a stream
+func (a *contextAdapter) WriteStreamContext(ctx context.Context, path string, contents io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.WriteStream(path, &contextReader{ctx: ctx, r: contents})
+}
+
+// ReadStreamContext opens a file for reading
+func (a *contextAdapter) ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	stream, err := a.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contextReadCloser{contextReader: contextReader{ctx: ctx, r: stream}, c: stream}, nil
+}
+
+// RenameContext renames a file
+func (a *contextAdapter) RenameContext(ctx context.Context, path string, newPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.Rename(path, newPath)
+}
+
+// CopyContext copies a file
+func (a *contextAdapter) CopyContext(ctx context.Context, path string, newPath string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.Copy(path, newPath)
+}
+
+// DeleteContext deletes a file
+func (a *contextAdapter) DeleteContext(ctx context.Context, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.Delete(path)
+}
+
+// CreateDirContext creates a directory
+func (a *contextAdapter) CreateDirContext(ctx context.Context, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.CreateDir(dir)
+}
+
+// DeleteDirContext deletes a directory
+func (a *contextAdapter) DeleteDirContext(ctx context.Context, dir string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.DeleteDir(dir)
+}
+
+// SetVisibilityContext sets a file or directory to public or private
+func (a *contextAdapter) SetVisibilityContext(ctx context.Context, path string, visibility string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	return a.SetVisibility(path, visibility)
+}
+
+// HasContext checks if a file or directory exists
+func (a *contextAdapter) HasContext(ctx context.Context, path string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	return a.Has(path)
+}
+
+// FileExistsContext checks if a file exists
+func (a *contextAdapter) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	return a.FileExists(path)
+}
+
+// DirectoryExistsContext checks if a directory exists
+func (a *contextAdapter) DirectoryExistsContext(ctx context.Context, dir string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, err
+	}
+
+	return a.DirectoryExists(dir)
+}
+
+// StatContext returns the attributes of a file or directory
+func (a *contextAdapter) StatContext(ctx context.Context, path string) (FileAttributes, error) {
+	if err := ctx.Err(); err != nil {
+		return FileAttributes{}, err
+	}
+
+	return a.Stat(path)
+}
+
+// ListContentsContext lists the contents of a directory
+func (a *contextAdapter) ListContentsContext(ctx context.Context, dir string, deep bool) (DirectoryListing, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	listing, err := a.ListContents(dir, deep)
+	if err != nil {
+		return nil, err
+	}
+
+	return &contextListing{DirectoryListing: listing, ctx: ctx}, nil
+}
+
+// contextReader stops reading once its context is done
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+// Read reads from the underlying reader unless the context is done
+func (r *contextReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	return r.r.Read(p)
+}
+
+// contextReadCloser is a contextReader that can be closed
+type contextReadCloser struct {
+	contextReader
+	c io.Closer
+}
+
+// Close closes the underlying reader
+func (r *contextReadCloser) Close() error {
+	return r.c.Close()
+}
+
+// contextListing stops iterating once its context is done
+type contextListing struct {
+	DirectoryListing
+	ctx context.Context
+	err error
+}
+
+// Next advances to the next entry unless the context is done
+func (l *contextListing) Next() bool {
+	if l.err = l.ctx.Err(); l.err != nil {
+		return false
+	}
+
+	return l.DirectoryListing.Next()
+}
+
+// Err returns the error that stopped the iteration
+func (l *contextListing) Err() error {
+	if l.err != nil {
+		return l.err
+	}
+
+	return l.DirectoryListing.Err()
+}
@@ -0,0 +1,114 @@
+package adapter
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestWithContext(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	a := WithContext(fs)
+
+	if WithContext(a) != a {
+		t.Log("expected a context adapter to be returned as is")
+		t.Fail()
+	}
+
+	err = a.WriteContext(context.Background(), "test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	stream, err := a.ReadStreamContext(context.Background(), "test.txt")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	contents, err := io.ReadAll(stream)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	stream.Close()
+
+	if string(contents) != "hello" {
+		t.Log("files does not contain: hello")
+		t.Fail()
+	}
+}
+
+func TestWithContext_Cancelled(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	a := WithContext(fs)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = a.WriteContext(ctx, "test.txt", []byte("hello"))
+	if err != context.Canceled {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	ok, err := fs.Has("test.txt")
+	if err != nil || ok {
+		t.Log("expected test.txt not to be written")
+		t.Fail()
+	}
+
+	err = a.WriteStreamContext(ctx, "test.txt", strings.NewReader("hello"))
+	if err != context.Canceled {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("a.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	listCtx, listCancel := context.WithCancel(context.Background())
+
+	listing, err := a.ListContentsContext(listCtx, "", true)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer listing.Close()
+
+	listCancel()
+
+	if listing.Next() {
+		t.Log("expected the listing to stop")
+		t.Fail()
+	}
+
+	if listing.Err() != context.Canceled {
+		t.Logf("expected context.Canceled, got %v", listing.Err())
+		t.Fail()
+	}
+}
@@ -0,0 +1,632 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"golang.org/x/crypto/chacha20poly1305"
+)
+
+var (
+	errDecrypt       = errors.New("unable to decrypt file")
+	errUnknownCipher = errors.New("unknown cipher")
+	errUnknownKey    = errors.New("unknown master key")
+)
+
+// Cipher is the AEAD used to encrypt the contents of a file
+type Cipher byte
+
+const (
+	// CipherAESGCM encrypts with AES-256-GCM
+	CipherAESGCM Cipher = 1
+
+	// CipherXChaCha20Poly1305 encrypts with XChaCha20-Poly1305
+	CipherXChaCha20Poly1305 Cipher = 2
+)
+
+// encryptedMagic starts every encrypted file
+const encryptedMagic = "FLYE"
+
+// encryptedVersion is the version of the file format
+const encryptedVersion = 1
+
+// encryptedChunkSize is how much plaintext is sealed at a time, it is stored in the header of every file
+const encryptedChunkSize = 64 * 1024
+
+// dataKeySize is the size of the key every file is encrypted with
+const dataKeySize = 32
+
+// KeyProvider protects the data keys of Encrypted with master keys
+type KeyProvider interface {
+	// WrapKey encrypts a data key with the current master key and returns the ID of that key
+	WrapKey(dataKey []byte) (keyID string, wrapped []byte, err error)
+	// UnwrapKey decrypts a data key with the master key keyID
+	UnwrapKey(keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Keyring is a KeyProvider holding 32 byte master keys by ID, data keys are wrapped with AES-256-GCM.
+// To rotate, add a new key and make it the current one: new files use the new key,
+// existing files keep being decrypted with the key named in their header
+type Keyring struct {
+	current string
+	keys    map[string]cipher.AEAD
+}
+
+// NewKeyring creates a new instance of Keyring that wraps data keys with the key named current
+func NewKeyring(current string, keys map[string][]byte) (*Keyring, error) {
+	k := &Keyring{current: current, keys: map[string]cipher.AEAD{}}
+
+	for id, key := range keys {
+		if len(id) > 255 {
+			return nil, fmt.Errorf("master key ID %q is longer than 255 bytes", id)
+		}
+
+		aead, err := newAEAD(CipherAESGCM, key)
+		if err != nil {
+			return nil, fmt.Errorf("master key %q: %w", id, err)
+		}
+
+		k.keys[id] = aead
+	}
+
+	if _, ok := k.keys[current]; !ok {
+		return nil, fmt.Errorf("master key %q: %w", current, errUnknownKey)
+	}
+
+	return k, nil
+}
+
+// WrapKey encrypts a data key with the current master key
+func (k *Keyring) WrapKey(dataKey []byte) (string, []byte, error) {
+	aead := k.keys[k.current]
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", nil, err
+	}
+
+	return k.current, aead.Seal(nonce, nonce, dataKey, []byte(k.current)), nil
+}
+
+// UnwrapKey decrypts a data key with the master key keyID
+func (k *Keyring) UnwrapKey(keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := k.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("master key %q: %w", keyID, errUnknownKey)
+	}
+
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errDecrypt
+	}
+
+	dataKey, err := aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+	if err != nil {
+		return nil, errDecrypt
+	}
+
+	return dataKey, nil
+}
+
+// Encrypted encrypts the contents of files before they reach another adapter and decrypts them when they are read.
+// Every file has its own random data key, wrapped by the KeyProvider and stored with the ID of the master key in the header.
+// Contents are sealed in chunks of 64 KiB so streams are never fully buffered and truncation is detected.
+// Paths, directories and visibility are not encrypted. Stat and ListContents read the header of each file
+// to report the size of the plaintext
+type Encrypted struct {
+	inner  Adapter
+	keys   KeyProvider
+	cipher Cipher
+}
+
+// encryptedHeader describes how a file was encrypted
+type encryptedHeader struct {
+	raw       []byte
+	cipher    Cipher
+	chunkSize int
+	keyID     string
+	wrapped   []byte
+}
+
+// NewEncrypted creates a new instance of Encrypted that encrypts with AES-256-GCM
+func NewEncrypted(inner Adapter, keys KeyProvider) Adapter {
+	return &Encrypted{inner: inner, keys: keys, cipher: CipherAESGCM}
+}
+
+// NewEncryptedWithCipher creates a new instance of Encrypted that encrypts new files with c,
+// files are always decrypted with the cipher named in their header
+func NewEncryptedWithCipher(inner Adapter, keys KeyProvider, c Cipher) (Adapter, error) {
+	if _, err := newAEAD(c, make([]byte, dataKeySize)); err != nil {
+		return nil, err
+	}
+
+	return &Encrypted{inner: inner, keys: keys, cipher: c}, nil
+}
+
+// Write a new file
+func (a *Encrypted) Write(path string, contents []byte) error {
+	ciphertext, err := a.seal(contents)
+	if err != nil {
+		return wrapError("write", path, err)
+	}
+
+	return a.inner.Write(path, ciphertext)
+}
+
+// Update a file
+func (a *Encrypted) Update(path string, contents []byte) error {
+	ciphertext, err := a.seal(contents)
+	if err != nil {
+		return wrapError("update", path, err)
+	}
+
+	return a.inner.Update(path, ciphertext)
+}
+
+// Put creates or overwrites a file
+func (a *Encrypted) Put(path string, contents []byte) error {
+	ciphertext, err := a.seal(contents)
+	if err != nil {
+		return wrapError("put", path, err)
+	}
+
+	return a.inner.Put(path, ciphertext)
+}
+
+// Read a file
+func (a *Encrypted) Read(path string) ([]byte, error) {
+	r, err := a.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, wrapError("read", path, err)
+	}
+
+	return contents, nil
+}
+
+// WriteStream writes a new file from a stream, it is encrypted while it is written
+func (a *Encrypted) WriteStream(path string, contents io.Reader) error {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		w, err := a.encrypt(pw)
+		if err == nil {
+			_, err = io.Copy(w, contents)
+		}
+
+		if err == nil {
+			err = w.Close()
+		}
+
+		pw.CloseWithError(err)
+	}()
+
+	err := a.inner.WriteStream(path, pr)
+
+	// Stop the encryption when the adapter returned without reading everything
+	pr.CloseWithError(io.ErrClosedPipe)
+	<-done
+
+	return err
+}
+
+// ReadStream opens a file for reading, the caller must close it. The contents are decrypted while they are read,
+// a Read fails when the file was tampered with
+func (a *Encrypted) ReadStream(path string) (io.ReadCloser, error) {
+	r, err := a.inner.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := a.decrypt(r)
+	if err != nil {
+		r.Close()
+
+		return nil, wrapError("read", path, err)
+	}
+
+	return d, nil
+}
+
+// Rename a file or directory
+func (a *Encrypted) Rename(path string, newPath string) error {
+	return a.inner.Rename(path, newPath)
+}
+
+// Copy a file
+func (a *Encrypted) Copy(path string, newPath string) error {
+	return a.inner.Copy(path, newPath)
+}
+
+// Delete a file
+func (a *Encrypted) Delete(path string) error {
+	return a.inner.Delete(path)
+}
+
+// CreateDir creates a directory
+func (a *Encrypted) CreateDir(dir string) error {
+	return a.inner.CreateDir(dir)
+}
+
+// DeleteDir deletes a directory
+func (a *Encrypted) DeleteDir(dir string) error {
+	return a.inner.DeleteDir(dir)
+}
+
+// SetVisibility sets a file or directory to public or private
+func (a *Encrypted) SetVisibility(path string, visibility string) error {
+	return a.inner.SetVisibility(path, visibility)
+}
+
+// Has checks if a file or directory exists
+func (a *Encrypted) Has(path string) (bool, error) {
+	return a.inner.Has(path)
+}
+
+// FileExists checks if a file exists
+func (a *Encrypted) FileExists(path string) (bool, error) {
+	return a.inner.FileExists(path)
+}
+
+// DirectoryExists checks if a directory exists
+func (a *Encrypted) DirectoryExists(dir string) (bool, error) {
+	return a.inner.DirectoryExists(dir)
+}
+
+// Stat returns the attributes of a file or directory, the size is the size of the plaintext
+func (a *Encrypted) Stat(path string) (FileAttributes, error) {
+	attributes, err := a.inner.Stat(path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+
+	return a.attributes(attributes)
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// The sizes are the sizes of the plaintext
+func (a *Encrypted) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	listing, err := a.inner.ListContents(dir, deep)
+	if err != nil {
+		return nil, err
+	}
+
+	return newMapListing(listing, a.attributes), nil
+}
+
+// Unwrap returns the inner adapter
+func (a *Encrypted) Unwrap() Adapter {
+	return a.inner
+}
+
+// attributes replaces the size of a file by the size of its plaintext
+func (a *Encrypted) attributes(attributes FileAttributes) (FileAttributes, error) {
+	if attributes.IsDir() {
+		return attributes, nil
+	}
+
+	r, err := a.inner.ReadStream(attributes.Path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+
+	defer r.Close()
+
+	h, err := readEncryptedHeader(r)
+	if err != nil {
+		return FileAttributes{}, wrapError("stat", attributes.Path, err)
+	}
+
+	size, err := h.plaintextSize(attributes.Size)
+	if err != nil {
+		return FileAttributes{}, wrapError("stat", attributes.Path, err)
+	}
+
+	attributes.Size = size
+
+	return attributes, nil
+}
+
+// seal encrypts contents that are in memory
+func (a *Encrypted) seal(contents []byte) ([]byte, error) {
+	var buf bytes.Buffer
+
+	w, err := a.encrypt(&buf)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := w.Write(contents); err != nil {
+		return nil, err
+	}
+
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encrypt writes the header of a new file to w and returns a writer that encrypts the contents to w.
+// Close writes the final chunk, it does not close w
+func (a *Encrypted) encrypt(w io.Writer) (io.WriteCloser, error) {
+	dataKey := make([]byte, dataKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return nil, err
+	}
+
+	keyID, wrapped, err := a.keys.WrapKey(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(keyID) > 255 || len(wrapped) > 65535 {
+		return nil, errors.New("wrapped data key is too large")
+	}
+
+	aead, err := newAEAD(a.cipher, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	h := &encryptedHeader{cipher: a.cipher, chunkSize: encryptedChunkSize, keyID: keyID, wrapped: wrapped}
+	h.raw = h.marshal()
+
+	if _, err := w.Write(h.raw); err != nil {
+		return nil, err
+	}
+
+	return &encryptWriter{w: w, aead: aead, header: h, buf: make([]byte, 0, h.chunkSize)}, nil
+}
+
+// decrypt reads the header from r and returns a reader that decrypts the contents
+func (a *Encrypted) decrypt(r io.ReadCloser) (io.ReadCloser, error) {
+	h, err := readEncryptedHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dataKey, err := a.keys.UnwrapKey(h.keyID, h.wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(h.cipher, dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &decryptReader{r: r, aead: aead, header: h, buf: make([]byte, h.chunkSize+aead.Overhead())}, nil
+}
+
+// marshal encodes the header: the magic, version, cipher, chunk size, key ID and wrapped data key
+func (h *encryptedHeader) marshal() []byte {
+	var chunkSize [4]byte
+	var wrappedSize [2]byte
+
+	binary.BigEndian.PutUint32(chunkSize[:], uint32(h.chunkSize))
+	binary.BigEndian.PutUint16(wrappedSize[:], uint16(len(h.wrapped)))
+
+	b := make([]byte, 0, 13+len(h.keyID)+len(h.wrapped))
+	b = append(b, encryptedMagic...)
+	b = append(b, encryptedVersion, byte(h.cipher))
+	b = append(b, chunkSize[:]...)
+	b = append(b, byte(len(h.keyID)))
+	b = append(b, h.keyID...)
+	b = append(b, wrappedSize[:]...)
+
+	return append(b, h.wrapped...)
+}
+
+// readEncryptedHeader reads the header of a file
+func readEncryptedHeader(r io.Reader) (*encryptedHeader, error) {
+	raw := make([]byte, 11)
+	if _, err := io.ReadFull(r, raw); err != nil {
+		return nil, errDecrypt
+	}
+
+	if string(raw[:4]) != encryptedMagic || raw[4] != encryptedVersion {
+		return nil, errDecrypt
+	}
+
+	h := &encryptedHeader{
+		cipher:    Cipher(raw[5]),
+		chunkSize: int(binary.BigEndian.Uint32(raw[6:10])),
+	}
+
+	if h.chunkSize <= 0 || h.chunkSize > 16*1024*1024 {
+		return nil, errDecrypt
+	}
+
+	keyID := make([]byte, int(raw[10])+2)
+	if _, err := io.ReadFull(r, keyID); err != nil {
+		return nil, errDecrypt
+	}
+
+	h.keyID = string(keyID[:len(keyID)-2])
+
+	wrapped := make([]byte, binary.BigEndian.Uint16(keyID[len(keyID)-2:]))
+	if _, err := io.ReadFull(r, wrapped); err != nil {
+		return nil, errDecrypt
+	}
+
+	h.wrapped = wrapped
+	h.raw = append(append(raw, keyID...), wrapped...)
+
+	return h, nil
+}
+
+// plaintextSize returns the size of the plaintext of a file of size bytes.
+// Every chunk but the last holds chunkSize bytes, the last holds less and may be empty
+func (h *encryptedHeader) plaintextSize(size int64) (int64, error) {
+	overhead := int64(16)
+	sealed := int64(h.chunkSize) + overhead
+
+	body := size - int64(len(h.raw))
+	if body < overhead {
+		return 0, errDecrypt
+	}
+
+	last := body % sealed
+	if last < overhead {
+		return 0, errDecrypt
+	}
+
+	return body/sealed*int64(h.chunkSize) + last - overhead, nil
+}
+
+// chunkNonce returns the nonce of a chunk: its index and whether it is the last one
+func chunkNonce(aead cipher.AEAD, index uint32, last bool) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	binary.BigEndian.PutUint32(nonce[len(nonce)-5:], index)
+
+	if last {
+		nonce[len(nonce)-1] = 1
+	}
+
+	return nonce
+}
+
+// newAEAD creates the AEAD of a cipher
+func newAEAD(c Cipher, key []byte) (cipher.AEAD, error) {
+	switch c {
+	case CipherAESGCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return cipher.NewGCM(block)
+	case CipherXChaCha20Poly1305:
+		return chacha20poly1305.NewX(key)
+	}
+
+	return nil, errUnknownCipher
+}
+
+// encryptWriter seals the contents written to it in chunks, the header is authenticated with every chunk
+type encryptWriter struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	header *encryptedHeader
+	buf    []byte
+	index  uint32
+	sealed []byte
+}
+
+// Write encrypts p, full chunks are written immediately
+func (e *encryptWriter) Write(p []byte) (int, error) {
+	written := 0
+
+	for len(p) > 0 {
+		n := copy(e.buf[len(e.buf):e.header.chunkSize], p)
+		e.buf = e.buf[:len(e.buf)+n]
+		p = p[n:]
+		written += n
+
+		if len(e.buf) == e.header.chunkSize {
+			if err := e.flush(false); err != nil {
+				return written, err
+			}
+		}
+	}
+
+	return written, nil
+}
+
+// Close writes the last chunk, which holds less than a full chunk
+func (e *encryptWriter) Close() error {
+	return e.flush(true)
+}
+
+// flush seals and writes the buffered chunk
+func (e *encryptWriter) flush(last bool) error {
+	if e.index == ^uint32(0) {
+		return errors.New("file is too large to encrypt")
+	}
+
+	e.sealed = e.aead.Seal(e.sealed[:0], chunkNonce(e.aead, e.index, last), e.buf, e.header.raw)
+	e.buf = e.buf[:0]
+	e.index++
+
+	_, err := e.w.Write(e.sealed)
+
+	return err
+}
+
+// decryptReader opens the chunks of a file while it is read
+type decryptReader struct {
+	r      io.ReadCloser
+	aead   cipher.AEAD
+	header *encryptedHeader
+	buf    []byte
+	plain  []byte
+	index  uint32
+	done   bool
+	err    error
+}
+
+// Read decrypts the next chunk when the previous one was read
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.plain) == 0 {
+		if d.err != nil {
+			return 0, d.err
+		}
+
+		if d.done {
+			return 0, io.EOF
+		}
+
+		d.err = d.next()
+	}
+
+	n := copy(p, d.plain)
+	d.plain = d.plain[n:]
+
+	return n, nil
+}
+
+// Close closes the underlying stream
+func (d *decryptReader) Close() error {
+	return d.r.Close()
+}
+
+// next opens the next chunk, a full chunk is never the last one
+func (d *decryptReader) next() error {
+	n, err := io.ReadFull(d.r, d.buf)
+
+	// The stream ended before the last chunk, it was truncated
+	if err == io.EOF {
+		return errDecrypt
+	}
+
+	last := err == io.ErrUnexpectedEOF
+	if err != nil && !last {
+		return err
+	}
+
+	plain, err := d.aead.Open(d.buf[:0], chunkNonce(d.aead, d.index, last), d.buf[:n], d.header.raw)
+	if err != nil {
+		return errDecrypt
+	}
+
+	d.plain = plain
+	d.index++
+	d.done = last
+
+	return nil
+}
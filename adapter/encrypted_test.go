@@ -0,0 +1,246 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func newKeyring(t *testing.T, current string, ids ...string) *Keyring {
+	keys := map[string][]byte{}
+
+	for _, id := range ids {
+		key := make([]byte, 32)
+		copy(key, id)
+		keys[id] = key
+	}
+
+	k, err := NewKeyring(current, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return k
+}
+
+func TestEncrypted_Write(t *testing.T) {
+	for _, c := range []Cipher{CipherAESGCM, CipherXChaCha20Poly1305} {
+		inner := NewMemory()
+
+		fs, err := NewEncryptedWithCipher(inner, newKeyring(t, "2024", "2024"), c)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		large := make([]byte, 3*encryptedChunkSize+100)
+		rand.Read(large)
+
+		files := map[string][]byte{
+			"empty":      {},
+			"small.txt":  []byte("hello world"),
+			"chunk.bin":  large[:encryptedChunkSize],
+			"large.bin":  large,
+			"double.bin": large[:2*encryptedChunkSize],
+		}
+
+		for p, contents := range files {
+			if err := fs.Write(p, contents); err != nil {
+				t.Fatal(err)
+			}
+
+			stored, _ := inner.Read(p)
+			if len(contents) > 0 && bytes.Contains(stored, contents) {
+				t.Logf("cipher %d: expected %s to be encrypted", c, p)
+				t.Fail()
+			}
+
+			read, err := fs.Read(p)
+			if err != nil || !bytes.Equal(read, contents) {
+				t.Logf("cipher %d: expected %s to be decrypted, got %d bytes %v", c, p, len(read), err)
+				t.Fail()
+			}
+
+			attributes, err := fs.Stat(p)
+			if err != nil || attributes.Size != int64(len(contents)) {
+				t.Logf("cipher %d: expected the size of %s to be %d, got %+v %v", c, p, len(contents), attributes, err)
+				t.Fail()
+			}
+		}
+
+		err = fs.Update("small.txt", []byte("hello update"))
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		contents, err := fs.Read("small.txt")
+		if err != nil || string(contents) != "hello update" {
+			t.Logf("unexpected contents after Update: %q %v", contents, err)
+			t.Fail()
+		}
+
+		err = fs.Write("small.txt", []byte("hello"))
+		if !errors.Is(err, ErrFileExists) {
+			t.Logf("expected ErrFileExists, got %v", err)
+			t.Fail()
+		}
+
+		listing, err := fs.ListContents("", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listed, err := Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, attributes := range listed {
+			if attributes.Size != int64(len(files[attributes.Path])) && attributes.Path != "small.txt" {
+				t.Logf("expected the listed size of %s to be %d, got %d", attributes.Path, len(files[attributes.Path]), attributes.Size)
+				t.Fail()
+			}
+		}
+	}
+}
+
+func TestEncrypted_Stream(t *testing.T) {
+	inner := NewMemory()
+	fs := NewEncrypted(inner, newKeyring(t, "a", "a"))
+
+	contents := bytes.Repeat([]byte("0123456789"), encryptedChunkSize/4)
+
+	err := fs.WriteStream("stream.txt", iotest.OneByteReader(bytes.NewReader(contents)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := fs.ReadStream("stream.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	read, err := io.ReadAll(iotest.OneByteReader(r))
+	r.Close()
+
+	if err != nil || !bytes.Equal(read, contents) {
+		t.Logf("expected the stream to be decrypted, got %d bytes %v", len(read), err)
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream.txt", strings.NewReader("hello"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.WriteStream("broken.txt", iotest.ErrReader(errors.New("broken")))
+	if err == nil {
+		t.Log("expected a failing source to fail the write")
+		t.Fail()
+	}
+}
+
+func TestEncrypted_Rotate(t *testing.T) {
+	inner := NewMemory()
+
+	old := NewEncrypted(inner, newKeyring(t, "2023", "2023"))
+	if err := old.Write("old.txt", []byte("old")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := NewEncrypted(inner, newKeyring(t, "2024", "2023", "2024"))
+	if err := fs.Write("new.txt", []byte("new")); err != nil {
+		t.Fatal(err)
+	}
+
+	for p, expected := range map[string]string{"old.txt": "old", "new.txt": "new"} {
+		contents, err := fs.Read(p)
+		if err != nil || string(contents) != expected {
+			t.Logf("expected %s to be decrypted with its own key, got %q %v", p, contents, err)
+			t.Fail()
+		}
+	}
+
+	stored, _ := inner.Read("new.txt")
+	if !bytes.Contains(stored[:32], []byte("2024")) {
+		t.Log("expected the key ID in the header")
+		t.Fail()
+	}
+
+	_, err := old.Read("new.txt")
+	if !errors.Is(err, errUnknownKey) {
+		t.Logf("expected errUnknownKey, got %v", err)
+		t.Fail()
+	}
+
+	_, err = NewKeyring("missing", map[string][]byte{"a": make([]byte, 32)})
+	if !errors.Is(err, errUnknownKey) {
+		t.Logf("expected errUnknownKey, got %v", err)
+		t.Fail()
+	}
+
+	_, err = NewKeyring("a", map[string][]byte{"a": make([]byte, 7)})
+	if err == nil {
+		t.Log("expected an invalid master key to fail")
+		t.Fail()
+	}
+}
+
+func TestEncrypted_Tampered(t *testing.T) {
+	inner := NewMemory()
+	fs := NewEncrypted(inner, newKeyring(t, "a", "a"))
+
+	contents := make([]byte, 2*encryptedChunkSize+10)
+	if err := fs.Write("test.bin", contents); err != nil {
+		t.Fatal(err)
+	}
+
+	stored, _ := inner.Read("test.bin")
+
+	h, err := readEncryptedHeader(bytes.NewReader(stored))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sealed := encryptedChunkSize + 16
+	first := len(h.raw)
+
+	// join copies the parts so stored is never modified
+	join := func(parts ...[]byte) []byte {
+		return bytes.Join(parts, nil)
+	}
+
+	tampered := map[string][]byte{
+		"flipped":       join(stored[:first+5], []byte{stored[first+5] ^ 1}, stored[first+6:]),
+		"truncated":     join(stored[:first+2*sealed]),
+		"cut":           join(stored[:len(stored)-1]),
+		"reordered":     join(stored[:first], stored[first+sealed:first+2*sealed], stored[first:first+sealed], stored[first+2*sealed:]),
+		"header":        join([]byte("FLYX"), stored[4:]),
+		"not encrypted": []byte("hello"),
+		"chunk size":    join(stored[:6], []byte{0, 0, 0, 16}, stored[10:]),
+		"only header":   join(stored[:first]),
+	}
+
+	for name, b := range tampered {
+		if err := inner.Put("tampered.bin", b); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := fs.Read("tampered.bin")
+		if !errors.Is(err, errDecrypt) {
+			t.Logf("%s: expected errDecrypt, got %v", name, err)
+			t.Fail()
+		}
+	}
+
+	contents, err = fs.Read("test.bin")
+	if err != nil || len(contents) != 2*encryptedChunkSize+10 {
+		t.Logf("expected the original to still be readable, got %d bytes %v", len(contents), err)
+		t.Fail()
+	}
+}
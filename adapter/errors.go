@@ -0,0 +1,116 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"syscall"
+)
+
+var (
+	// ErrFileNotFound is returned when a file does not exist
+	ErrFileNotFound = errors.New("file not found")
+
+	// ErrFileExists is returned when a file already exists
+	ErrFileExists = errors.New("file already exists")
+
+	// ErrDirectoryNotFound is returned when a directory does not exist
+	ErrDirectoryNotFound = errors.New("directory not found")
+
+	// ErrPermissionDenied is returned when the adapter is not allowed to access a path
+	ErrPermissionDenied = errors.New("permission denied")
+
+	// ErrUnableToCreateRoot is returned when the root of an adapter can not be created
+	ErrUnableToCreateRoot = errors.New("unable to create root directory")
+
+	// ErrReadOnly is returned when a change is made through a read-only adapter
+	ErrReadOnly = errors.New("read-only filesystem")
+)
+
+// errDeleteRoot is the reason deleting the root directory is refused
+var errDeleteRoot = errors.New("can't delete the root directory")
+
+// Error records a failed operation, the path it failed on and what went wrong.
+// errors.Is matches both the Kind and the underlying error
+type Error struct {
+	Op   string
+	Path string
+	Kind error
+	Err  error
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	switch {
+	case e.Kind == nil:
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Err)
+	case e.Err == nil:
+		return fmt.Sprintf("%s %s: %v", e.Op, e.Path, e.Kind)
+	}
+
+	return fmt.Sprintf("%s %s: %v: %v", e.Op, e.Path, e.Kind, e.Err)
+}
+
+// Unwrap returns the underlying error
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the error is of the target kind
+func (e *Error) Is(target error) bool {
+	return e.Kind != nil && e.Kind == target
+}
+
+// wrapError wraps err for an operation on a file
+func wrapError(op string, path string, err error) error {
+	return newError(op, path, ErrFileNotFound, err)
+}
+
+// wrapDirError wraps err for an operation on a directory
+func wrapDirError(op string, path string, err error) error {
+	return newError(op, path, ErrDirectoryNotFound, err)
+}
+
+// deleteRootError refuses to delete the root directory, every adapter treats it as ErrPermissionDenied
+func deleteRootError(dir string) error {
+	return &Error{Op: "rmdir", Path: dir, Kind: ErrPermissionDenied, Err: errDeleteRoot}
+}
+
+func newError(op string, path string, notFound error, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var e *Error
+	if errors.As(err, &e) {
+		return err
+	}
+
+	var kind error
+
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		kind = notFound
+	case errors.Is(err, syscall.ENOTDIR):
+		kind = ErrDirectoryNotFound
+	case errors.Is(err, os.ErrExist):
+		kind = ErrFileExists
+	case errors.Is(err, os.ErrPermission):
+		kind = ErrPermissionDenied
+	case isKind(err):
+		kind = err
+		err = nil
+	}
+
+	return &Error{Op: op, Path: path, Kind: kind, Err: err}
+}
+
+// isKind reports whether err is one of the sentinel errors
+func isKind(err error) bool {
+	switch err {
+	case ErrFileNotFound, ErrFileExists, ErrDirectoryNotFound, ErrPermissionDenied, ErrUnableToCreateRoot, ErrReadOnly:
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,48 @@
+package adapter
+
+import (
+	"errors"
+	"os"
+	"testing"
+)
+
+func TestError_Is(t *testing.T) {
+	err := wrapError("read", "test.txt", &os.PathError{Op: "open", Path: "test.txt", Err: os.ErrNotExist})
+
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Log("expected ErrFileNotFound")
+		t.Fail()
+	}
+
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Log("expected the underlying error to be unwrapped")
+		t.Fail()
+	}
+
+	if errors.Is(err, ErrDirectoryNotFound) {
+		t.Log("unexpected ErrDirectoryNotFound")
+		t.Fail()
+	}
+
+	var e *Error
+	if !errors.As(err, &e) || e.Op != "read" || e.Path != "test.txt" {
+		t.Logf("unexpected error: %v", err)
+		t.Fail()
+	}
+
+	if wrapError("read", "test.txt", err) != err {
+		t.Log("expected an Error not to be wrapped twice")
+		t.Fail()
+	}
+
+	if wrapError("read", "test.txt", nil) != nil {
+		t.Log("expected nil")
+		t.Fail()
+	}
+
+	err = wrapError("write", "test.txt", ErrFileExists)
+	if !errors.Is(err, ErrFileExists) || err.Error() != "write test.txt: file already exists" {
+		t.Logf("unexpected error: %v", err)
+		t.Fail()
+	}
+}
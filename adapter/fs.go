@@ -0,0 +1,181 @@
+package adapter
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"os"
+)
+
+// FS reads files from an fs.FS such as embed.FS or os.DirFS, it is read-only and every change fails with ErrReadOnly
+type FS struct {
+	readOnlyMethods
+	fsys fs.FS
+}
+
+// NewFromFS creates a new instance of FS
+func NewFromFS(fsys fs.FS) Adapter {
+	return &FS{fsys: fsys}
+}
+
+// Read a file
+func (a *FS) Read(path string) ([]byte, error) {
+	r, err := a.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, wrapError("read", path, err)
+	}
+
+	return contents, nil
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *FS) ReadStream(path string) (io.ReadCloser, error) {
+	f, err := a.fsys.Open(a.name(path))
+	if err != nil {
+		return nil, wrapError("read", path, err)
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return nil, wrapError("read", path, err)
+	}
+
+	if info.IsDir() {
+		f.Close()
+
+		return nil, wrapError("read", path, ErrFileNotFound)
+	}
+
+	return f, nil
+}
+
+// Has checks if a file or directory exists
+func (a *FS) Has(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil, err
+}
+
+// FileExists checks if a file exists
+func (a *FS) FileExists(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil && !info.IsDir(), err
+}
+
+// DirectoryExists checks if a directory exists
+func (a *FS) DirectoryExists(dir string) (bool, error) {
+	info, err := a.stat("stat", dir)
+
+	return info != nil && info.IsDir(), err
+}
+
+// Stat returns the attributes of a file or directory, the visibility follows the permissions reported by the file system
+func (a *FS) Stat(path string) (FileAttributes, error) {
+	info, err := a.stat("stat", path)
+	if err != nil {
+		return FileAttributes{}, err
+	}
+
+	if info == nil {
+		return FileAttributes{}, wrapError("stat", path, ErrFileNotFound)
+	}
+
+	return a.attributes(normalizePath(path), info), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// Directories are read one at a time while iterating
+func (a *FS) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	info, err := a.stat("list", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	if info == nil || !info.IsDir() {
+		return nil, wrapDirError("list", dir, ErrDirectoryNotFound)
+	}
+
+	l, err := newReadDirListing(normalizePath(dir), deep, a.readDir, a.attributes)
+	if err != nil {
+		return nil, wrapDirError("list", dir, err)
+	}
+
+	return l, nil
+}
+
+// name turns a path into a name as accepted by fs.FS, the root is "."
+func (a *FS) name(p string) string {
+	p = normalizePath(p)
+	if p == "" {
+		return "."
+	}
+
+	return p
+}
+
+// readDir reads the entries of a directory
+func (a *FS) readDir(dir string) ([]os.FileInfo, error) {
+	entries, err := fs.ReadDir(a.fsys, a.name(dir))
+	if err != nil {
+		return nil, err
+	}
+
+	infos := make([]os.FileInfo, 0, len(entries))
+
+	for _, entry := range entries {
+		info, err := entry.Info()
+
+		// The entry was removed after the directory was read
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		infos = append(infos, info)
+	}
+
+	return infos, nil
+}
+
+// stat returns the file info of path, or nil if it does not exist
+func (a *FS) stat(op string, path string) (os.FileInfo, error) {
+	info, err := fs.Stat(a.fsys, a.name(path))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, wrapError(op, path, err)
+	}
+
+	return info, nil
+}
+
+// attributes describes a file or directory, the contents are only read when the extension doesn't tell the mime type
+func (a *FS) attributes(p string, info os.FileInfo) FileAttributes {
+	return infoAttributes(p, info, func() []byte {
+		f, err := a.fsys.Open(a.name(p))
+		if err != nil {
+			return nil
+		}
+
+		defer f.Close()
+
+		head, _ := io.ReadAll(io.LimitReader(f, sniffSize))
+
+		return head
+	})
+}
@@ -0,0 +1,168 @@
+package adapter
+
+import (
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func newFS() Adapter {
+	modTime := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	return NewFromFS(fstest.MapFS{
+		"a.txt":         {Data: []byte("hello world"), Mode: 0644, ModTime: modTime},
+		"dir/b":         {Data: []byte("\x89PNG\r\n\x1a\n"), Mode: 0644},
+		"dir/sub/c.txt": {Data: []byte("c"), Mode: 0600},
+		"empty":         {Mode: os.ModeDir | 0755},
+	})
+}
+
+func TestFS_Read(t *testing.T) {
+	fs := newFS()
+
+	contents, err := fs.Read("a.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Logf("files does not contain: hello world, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("/dir/sub/c.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, _ = io.ReadAll(r)
+	r.Close()
+
+	if string(contents) != "c" {
+		t.Logf("unexpected contents: %q", contents)
+		t.Fail()
+	}
+
+	_, err = fs.Read("dir")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound for a directory, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("a.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != VisibilityPublic || !strings.HasPrefix(attributes.MimeType, "text/plain") || attributes.LastModified.Year() != 2024 {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("dir/b")
+	if err != nil || attributes.MimeType != "image/png" {
+		t.Logf("expected the mime type to be sniffed, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("dir/sub/c.txt")
+	if err != nil || attributes.Visibility != VisibilityPrivate {
+		t.Logf("expected a private file, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	_, err = fs.Stat("missing.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("a.txt"); !ok {
+		t.Log("expected a.txt to exist")
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir/sub"); !ok {
+		t.Log("expected dir/sub to exist")
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("missing"); ok {
+		t.Log("expected missing to not exist")
+		t.Fail()
+	}
+}
+
+func TestFS_ListContents(t *testing.T) {
+	fs := newFS()
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir", "empty"}},
+		{"dir", false, []string{"dir/b", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "empty", "dir/b", "dir/sub", "dir/sub/c.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err := fs.ListContents("a.txt", false)
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("missing", false)
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFS_ReadOnly(t *testing.T) {
+	fs := newFS()
+
+	errs := []error{
+		fs.Write("new.txt", []byte("hello")),
+		fs.Update("a.txt", []byte("hello")),
+		fs.Put("a.txt", []byte("hello")),
+		fs.WriteStream("new.txt", strings.NewReader("hello")),
+		fs.Rename("a.txt", "b.txt"),
+		fs.Copy("a.txt", "b.txt"),
+		fs.Delete("a.txt"),
+		fs.CreateDir("new"),
+		fs.DeleteDir("dir"),
+		fs.SetVisibility("a.txt", VisibilityPrivate),
+	}
+
+	for i, err := range errs {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Logf("#%d: expected ErrReadOnly, got %v", i, err)
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,466 @@
+package ftp
+
+import (
+	"bytes"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/secsy/goftp"
+)
+
+// ftpPerms are the permissions of public and private files and directories, the same as Local uses
+var ftpPerms = map[string]map[string]os.FileMode{
+	"file": {adapter.VisibilityPublic: adapter.FilePublic, adapter.VisibilityPrivate: adapter.FilePrivate},
+	"dir":  {adapter.VisibilityPublic: adapter.DirPublic, adapter.VisibilityPrivate: adapter.DirPrivate},
+}
+
+// ftpMinConnections is the smallest pool size, Copy downloads and uploads at the same time
+const ftpMinConnections = 2
+
+// FTP reply codes the adapter tells apart
+const (
+	ftpCommandOkay         = 200
+	ftpNotLoggedIn         = 530
+	ftpFileUnavailable     = 550
+	ftpNeedAccountForStore = 532
+)
+
+// FTPConfig configures an FTP adapter
+type FTPConfig struct {
+	// Address is the host and port of the server
+	Address string
+	// User to log in as
+	User string
+	// Password of the user
+	Password string
+	// Root is the directory on the server the paths are relative to, it is created when missing
+	Root string
+	// TLSConfig enables explicit TLS, the connection fails if the server doesn't support it
+	TLSConfig *tls.Config
+	// Active makes the server connect to the client for transfers instead of the other way around
+	Active bool
+	// ActiveListenAddr is the address listened on for active transfers, it defaults to the address of the control connection
+	ActiveListenAddr string
+	// Connections is the maximum number of pooled connections, it defaults to 5 and is at least 2
+	Connections int
+	// Timeout limits connecting, sending commands and every read and write of a transfer
+	Timeout time.Duration
+}
+
+// FTP stores files on an FTP server.
+// Connections are pooled and opened on demand, mime types are guessed from the extension only
+type FTP struct {
+	root   string
+	client *goftp.Client
+}
+
+// NewFTP creates a new instance of FTP, it connects to the server to create the root directory
+func NewFTP(config FTPConfig) (adapter.Adapter, error) {
+	connections := config.Connections
+	if connections == 0 {
+		connections = 5
+	}
+
+	if connections < ftpMinConnections {
+		connections = ftpMinConnections
+	}
+
+	client, err := goftp.DialConfig(goftp.Config{
+		User:               config.User,
+		Password:           config.Password,
+		ConnectionsPerHost: connections,
+		Timeout:            config.Timeout,
+		TLSConfig:          config.TLSConfig,
+		TLSMode:            goftp.TLSExplicit,
+		ActiveTransfers:    config.Active,
+		ActiveListenAddr:   config.ActiveListenAddr,
+	}, config.Address)
+	if err != nil {
+		return nil, &adapter.Error{Op: "connect", Path: config.Address, Err: err}
+	}
+
+	// Connections are opened on demand, make sure the first one can log in
+	_, err = client.Getwd()
+	if err != nil {
+		client.Close()
+
+		return nil, ftpError("connect", config.Address, nil, err)
+	}
+
+	root := config.Root
+	if root == "" {
+		root = "."
+	}
+
+	a := &FTP{
+		root:   root,
+		client: client,
+	}
+
+	err = a.mkdirAll(root)
+	if err != nil {
+		client.Close()
+
+		return nil, &adapter.Error{Op: "mkdir", Path: root, Kind: adapter.ErrUnableToCreateRoot, Err: err}
+	}
+
+	return a, nil
+}
+
+// Close closes the pooled connections
+func (a *FTP) Close() error {
+	return a.client.Close()
+}
+
+// Write a new file, it fails if the file already exists
+func (a *FTP) Write(path string, contents []byte) error {
+	return a.WriteStream(path, bytes.NewReader(contents))
+}
+
+// Update a file, it fails if the file does not exist
+func (a *FTP) Update(path string, contents []byte) error {
+	ok, err := a.FileExists(path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("update", path, adapter.ErrFileNotFound)
+	}
+
+	return a.store("update", path, bytes.NewReader(contents), false)
+}
+
+// Put writes a file, creating or overwriting it
+func (a *FTP) Put(path string, contents []byte) error {
+	return a.store("put", path, bytes.NewReader(contents), true)
+}
+
+// Read a file
+func (a *FTP) Read(path string) ([]byte, error) {
+	var buf bytes.Buffer
+
+	err := a.client.Retrieve(a.location(path), &buf)
+	if err != nil {
+		return nil, ftpError("read", path, adapter.ErrFileNotFound, err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// WriteStream writes a new file from a stream, it fails if the file already exists
+func (a *FTP) WriteStream(path string, contents io.Reader) error {
+	ok, err := a.Has(path)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return adapter.WrapError("write", path, adapter.ErrFileExists)
+	}
+
+	return a.store("write", path, contents, true)
+}
+
+// ReadStream opens a file for reading, the caller must close it.
+// The file is downloaded while it is read, closing it early aborts the download
+func (a *FTP) ReadStream(path string) (io.ReadCloser, error) {
+	ok, err := a.FileExists(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if !ok {
+		return nil, adapter.WrapError("read", path, adapter.ErrFileNotFound)
+	}
+
+	r, w := io.Pipe()
+
+	go func() {
+		w.CloseWithError(ftpError("read", path, adapter.ErrFileNotFound, a.client.Retrieve(a.location(path), w)))
+	}()
+
+	return r, nil
+}
+
+// Rename a file or directory
+func (a *FTP) Rename(path string, newPath string) error {
+	return ftpError("rename", path, adapter.ErrFileNotFound, a.client.Rename(a.location(path), a.location(newPath)))
+}
+
+// Copy a file, FTP can't copy on the server so the file is downloaded and uploaded again
+func (a *FTP) Copy(path string, newPath string) error {
+	source, err := a.ReadStream(path)
+	if err != nil {
+		return err
+	}
+
+	defer source.Close()
+
+	return a.store("copy", newPath, source, true)
+}
+
+// Delete a file
+func (a *FTP) Delete(path string) error {
+	return ftpError("delete", path, adapter.ErrFileNotFound, a.client.Delete(a.location(path)))
+}
+
+// CreateDir creates a directory
+func (a *FTP) CreateDir(dir string) error {
+	ok, err := a.Has(dir)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return adapter.WrapDirError("mkdir", dir, adapter.ErrFileExists)
+	}
+
+	_, err = a.client.Mkdir(a.location(dir))
+
+	return ftpError("mkdir", dir, adapter.ErrDirectoryNotFound, err)
+}
+
+// DeleteDir deletes a directory and its contents
+func (a *FTP) DeleteDir(dir string) error {
+	info, err := a.stat("rmdir", dir)
+	if err != nil || info == nil {
+		return err
+	}
+
+	return ftpError("rmdir", dir, adapter.ErrDirectoryNotFound, a.removeAll(a.location(dir)))
+}
+
+// SetVisibility sets a file or directory to public or private with SITE CHMOD
+func (a *FTP) SetVisibility(path string, visibility string) error {
+	info, err := a.stat("chmod", path)
+	if err != nil {
+		return err
+	}
+
+	if info == nil {
+		return adapter.WrapError("chmod", path, adapter.ErrFileNotFound)
+	}
+
+	var perm os.FileMode
+
+	if info.IsDir() {
+		perm = ftpPerms["dir"][visibility]
+	} else {
+		perm = ftpPerms["file"][visibility]
+	}
+
+	// Raw connections aren't pooled, but they are the only way to send SITE commands
+	conn, err := a.client.OpenRawConn()
+	if err != nil {
+		return ftpError("chmod", path, adapter.ErrFileNotFound, err)
+	}
+
+	defer conn.Close()
+
+	code, msg, err := conn.SendCommand("SITE CHMOD %o %s", perm, a.location(path))
+	if err != nil {
+		return ftpError("chmod", path, adapter.ErrFileNotFound, err)
+	}
+
+	if code != ftpCommandOkay {
+		return ftpError("chmod", path, adapter.ErrFileNotFound, &ftpReply{code: code, msg: msg})
+	}
+
+	return nil
+}
+
+// Has checks if a file or directory exists
+func (a *FTP) Has(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil, err
+}
+
+// FileExists checks if a file exists
+func (a *FTP) FileExists(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil && !info.IsDir(), err
+}
+
+// DirectoryExists checks if a directory exists
+func (a *FTP) DirectoryExists(dir string) (bool, error) {
+	info, err := a.stat("stat", dir)
+
+	return info != nil && info.IsDir(), err
+}
+
+// Stat returns the attributes of a file or directory.
+// The visibility is only accurate if the server reports permissions
+func (a *FTP) Stat(path string) (adapter.FileAttributes, error) {
+	info, err := a.stat("stat", path)
+	if err != nil {
+		return adapter.FileAttributes{}, err
+	}
+
+	if info == nil {
+		return adapter.FileAttributes{}, adapter.WrapError("stat", path, adapter.ErrFileNotFound)
+	}
+
+	return a.attributes(path, info), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// Directories are read from the server one at a time while iterating
+func (a *FTP) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	l, err := adapter.NewReadDirListing(adapter.NormalizePath(dir), deep, a.readDir, a.attributes)
+	if err != nil {
+		return nil, ftpError("list", dir, adapter.ErrDirectoryNotFound, err)
+	}
+
+	return l, nil
+}
+
+// store uploads contents, create also creates the missing parent directories
+func (a *FTP) store(op string, p string, contents io.Reader, create bool) error {
+	location := a.location(p)
+
+	if create {
+		err := a.mkdirAll(path.Dir(location))
+		if err != nil {
+			return ftpError(op, p, adapter.ErrDirectoryNotFound, err)
+		}
+	}
+
+	return ftpError(op, p, adapter.ErrFileNotFound, a.client.Store(location, contents))
+}
+
+// location returns the path on the server, remote paths always use forward slashes
+func (a *FTP) location(p string) string {
+	return path.Join(a.root, adapter.NormalizePath(p))
+}
+
+// readDir reads the entries of a directory
+func (a *FTP) readDir(dir string) ([]os.FileInfo, error) {
+	entries, err := a.client.ReadDir(a.location(dir))
+	if isFTPCode(err, ftpFileUnavailable) {
+		return nil, os.ErrNotExist
+	}
+
+	return entries, err
+}
+
+// stat returns the file info of path, or nil if it does not exist
+func (a *FTP) stat(op string, path string) (os.FileInfo, error) {
+	info, err := a.client.Stat(a.location(path))
+	if isFTPCode(err, ftpFileUnavailable) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, ftpError(op, path, adapter.ErrFileNotFound, err)
+	}
+
+	return info, nil
+}
+
+func (a *FTP) attributes(p string, info os.FileInfo) adapter.FileAttributes {
+	return adapter.InfoAttributes(adapter.NormalizePath(p), info, func() []byte {
+		return nil
+	})
+}
+
+// mkdirAll creates a directory and its missing parents
+func (a *FTP) mkdirAll(dir string) error {
+	dir = path.Clean(dir)
+	if dir == "." || dir == "/" {
+		return nil
+	}
+
+	info, err := a.client.Stat(dir)
+	if err == nil {
+		if !info.IsDir() {
+			return &os.PathError{Op: "mkdir", Path: dir, Err: syscall.ENOTDIR}
+		}
+
+		return nil
+	}
+
+	if !isFTPCode(err, ftpFileUnavailable) {
+		return err
+	}
+
+	err = a.mkdirAll(path.Dir(dir))
+	if err != nil {
+		return err
+	}
+
+	_, err = a.client.Mkdir(dir)
+
+	return err
+}
+
+// removeAll deletes a directory tree, FTP servers only delete empty directories
+func (a *FTP) removeAll(dir string) error {
+	entries, err := a.client.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		location := path.Join(dir, entry.Name())
+
+		if entry.IsDir() {
+			err = a.removeAll(location)
+		} else {
+			err = a.client.Delete(location)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return a.client.Rmdir(dir)
+}
+
+// ftpReply is an unexpected reply from the server
+type ftpReply struct {
+	code int
+	msg  string
+}
+
+// Error returns the reply
+func (r *ftpReply) Error() string {
+	return fmt.Sprintf("unexpected reply: %d %s", r.code, r.msg)
+}
+
+// Code returns the reply code
+func (r *ftpReply) Code() int {
+	return r.code
+}
+
+// isFTPCode reports whether err is a reply from the server with the code
+func isFTPCode(err error, code int) bool {
+	var reply interface{ Code() int }
+
+	return errors.As(err, &reply) && reply.Code() == code
+}
+
+// ftpError wraps an error returned by the server, mapping its reply codes to the error kinds.
+// Servers use 550 for anything that makes a file unavailable, it is reported as not found
+func ftpError(op string, path string, notFound error, err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case isFTPCode(err, ftpFileUnavailable):
+		return &adapter.Error{Op: op, Path: path, Kind: notFound, Err: err}
+	case isFTPCode(err, ftpNotLoggedIn), isFTPCode(err, ftpNeedAccountForStore):
+		return &adapter.Error{Op: op, Path: path, Kind: adapter.ErrPermissionDenied, Err: err}
+	}
+
+	return adapter.NewError(op, path, notFound, err)
+}
@@ -0,0 +1,391 @@
+package ftp
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	ftpserver "github.com/fclairamb/ftpserverlib"
+	"github.com/spf13/afero"
+)
+
+// ftpDriver serves a directory to the user test with password secret
+type ftpDriver struct {
+	root     string
+	settings *ftpserver.Settings
+	tls      *tls.Config
+}
+
+func (d *ftpDriver) GetSettings() (*ftpserver.Settings, error) {
+	return d.settings, nil
+}
+
+func (d *ftpDriver) ClientConnected(cc ftpserver.ClientContext) (string, error) {
+	return "test server", nil
+}
+
+func (d *ftpDriver) ClientDisconnected(cc ftpserver.ClientContext) {}
+
+func (d *ftpDriver) AuthUser(cc ftpserver.ClientContext, user, pass string) (ftpserver.ClientDriver, error) {
+	if user != "test" || pass != "secret" {
+		return nil, errors.New("wrong password")
+	}
+
+	return afero.NewBasePathFs(afero.NewOsFs(), d.root), nil
+}
+
+func (d *ftpDriver) GetTLSConfig() (*tls.Config, error) {
+	if d.tls == nil {
+		return nil, errors.New("no TLS configured")
+	}
+
+	return d.tls, nil
+}
+
+func newFTPServer(t *testing.T, driver *ftpDriver) string {
+	driver.root = t.TempDir()
+	driver.settings = &ftpserver.Settings{
+		ListenAddr:              "127.0.0.1:0",
+		ActiveTransferPortNon20: true,
+		DefaultTransferType:     ftpserver.TransferTypeBinary,
+	}
+
+	server := ftpserver.NewFtpServer(driver)
+
+	err := server.Listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	go server.Serve()
+
+	t.Cleanup(func() {
+		server.Stop()
+	})
+
+	return server.Addr()
+}
+
+func newFTP(t *testing.T, config FTPConfig) (adapter.Adapter, string) {
+	driver := &ftpDriver{}
+
+	config.Address = newFTPServer(t, driver)
+	config.User = "test"
+	config.Password = "secret"
+	config.Root = "data"
+
+	fs, err := NewFTP(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		fs.(*FTP).Close()
+	})
+
+	return fs, filepath.Join(driver.root, "data")
+}
+
+// newCertificate creates a self signed certificate for 127.0.0.1
+func newCertificate(t *testing.T) (tls.Certificate, *x509.CertPool) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certificate, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pool := x509.NewCertPool()
+	pool.AddCert(certificate)
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, pool
+}
+
+func TestFTP_Modes(t *testing.T) {
+	certificate, pool := newCertificate(t)
+
+	tests := []struct {
+		name   string
+		config FTPConfig
+		tls    *tls.Config
+	}{
+		{"passive", FTPConfig{}, nil},
+		{"active", FTPConfig{Active: true}, nil},
+		{
+			"explicit tls",
+			FTPConfig{TLSConfig: &tls.Config{RootCAs: pool, ServerName: "127.0.0.1"}},
+			&tls.Config{Certificates: []tls.Certificate{certificate}},
+		},
+	}
+
+	for _, test := range tests {
+		driver := &ftpDriver{tls: test.tls}
+
+		config := test.config
+		config.Address = newFTPServer(t, driver)
+		config.User = "test"
+		config.Password = "secret"
+
+		fs, err := NewFTP(config)
+		if err != nil {
+			t.Logf("%s: %v", test.name, err)
+			t.Fail()
+
+			continue
+		}
+
+		err = fs.Write("test.txt", []byte("hello world"))
+		if err != nil {
+			t.Logf("%s: %v", test.name, err)
+			t.Fail()
+		}
+
+		contents, err := fs.Read("test.txt")
+		if err != nil || string(contents) != "hello world" {
+			t.Logf("%s: files does not contain: hello world, %v", test.name, err)
+			t.Fail()
+		}
+
+		fs.(*FTP).Close()
+	}
+}
+
+func TestFTP_Auth(t *testing.T) {
+	address := newFTPServer(t, &ftpDriver{})
+
+	_, err := NewFTP(FTPConfig{Address: address, User: "test", Password: "wrong"})
+	if !errors.Is(err, adapter.ErrPermissionDenied) {
+		t.Logf("expected ErrPermissionDenied, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFTP_Write(t *testing.T) {
+	fs, root := newFTP(t, FTPConfig{})
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := os.ReadFile(filepath.Join(root, "sub", "test.txt"))
+	if err != nil || string(contents) != "hello world" {
+		t.Log("expected the file to be written below the root")
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("missing.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("sub/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Put("sub/test.txt", []byte("hello put"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 9 || attributes.Path != "sub/test.txt" || attributes.MimeType != "text/plain; charset=utf-8" {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.ReadStream("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFTP_Copy(t *testing.T) {
+	fs, _ := newFTP(t, FTPConfig{Connections: 1})
+
+	err := fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Copy("test.txt", "sub/copy.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/copy.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("copy does not contain: hello world")
+		t.Fail()
+	}
+
+	err = fs.Rename("sub/copy.txt", "renamed.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("sub/copy.txt"); ok {
+		t.Log("expected sub/copy.txt to be gone")
+		t.Fail()
+	}
+
+	err = fs.Delete("renamed.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "copy.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFTP_Visibility(t *testing.T) {
+	fs, root := newFTP(t, FTPConfig{})
+
+	err := fs.CreateDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Write("dir/test.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path       string
+		visibility string
+		perm       os.FileMode
+	}{
+		{"dir", adapter.VisibilityPrivate, adapter.DirPrivate},
+		{"dir", adapter.VisibilityPublic, adapter.DirPublic},
+		{"dir/test.txt", adapter.VisibilityPrivate, adapter.FilePrivate},
+		{"dir/test.txt", adapter.VisibilityPublic, adapter.FilePublic},
+	}
+
+	for _, test := range tests {
+		err := fs.SetVisibility(test.path, test.visibility)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		info, err := os.Stat(filepath.Join(root, test.path))
+		if err != nil || info.Mode().Perm() != test.perm {
+			t.Logf("expected %s to have permissions %o", test.path, test.perm)
+			t.Fail()
+		}
+	}
+
+	err = fs.SetVisibility("missing.txt", adapter.VisibilityPublic)
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFTP_Directories(t *testing.T) {
+	fs, _ := newFTP(t, FTPConfig{})
+
+	err := fs.CreateDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("dir")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"dir/a.txt", "dir/sub/b.txt", "c.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listing, err := fs.ListContents("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := adapter.Collect(listing)
+	if err != nil || len(contents) != 5 {
+		t.Logf("expected 5 entries, got %+v %v", contents, err)
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("missing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir"); ok {
+		t.Log("expected dir to be deleted")
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Logf("expected deleting a missing directory to succeed, got %v", err)
+		t.Fail()
+	}
+}
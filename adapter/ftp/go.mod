@@ -0,0 +1,21 @@
+module github.com/edwin-luijten/go_flysystem/adapter/ftp
+
+go 1.24.0
+
+require (
+	github.com/edwin-luijten/go_flysystem v0.1.0
+	github.com/fclairamb/ftpserverlib v0.25.0
+	github.com/secsy/goftp v0.0.0-20200609142545-aa2de14babf4
+	github.com/spf13/afero v1.11.0
+)
+
+require (
+	github.com/fclairamb/go-log v0.5.0 // indirect
+	github.com/klauspost/compress v1.15.9 // indirect
+	golang.org/x/crypto v0.16.0 // indirect
+	golang.org/x/sys v0.28.0 // indirect
+	golang.org/x/text v0.14.0 // indirect
+)
+
+// The adapter is developed against the core next to it, users of the module get the required version
+replace github.com/edwin-luijten/go_flysystem => ../..
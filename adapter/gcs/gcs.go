@@ -0,0 +1,260 @@
+package gcs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+
+	"cloud.google.com/go/storage"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/edwin-luijten/go_flysystem/internal/object"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/iterator"
+)
+
+// gcsPageSize is the number of objects listed per request
+const gcsPageSize = 1000
+
+var errNoBucket = errors.New("no bucket configured")
+
+// GCSConfig configures a Google Cloud Storage adapter
+type GCSConfig struct {
+	// Bucket is the bucket files are stored in, it must exist
+	Bucket string
+	// Prefix is prepended to every object name, it allows several adapters to share a bucket
+	Prefix string
+	// Visibility is given to new files. Leaving it empty leaves ACLs alone:
+	// new files get the bucket's default object ACL and Stat doesn't report a visibility,
+	// which suits buckets with uniform bucket-level access
+	Visibility string
+	// ChunkSize is the size of the chunks of a resumable upload, smaller files are uploaded in a single request.
+	// It is rounded up to a multiple of 256 KiB, zero uses the client's default of 16 MiB
+	ChunkSize int
+}
+
+// GCS stores files in a Google Cloud Storage bucket.
+// Directories are emulated the same way as in S3, with marker objects whose name ends with a slash
+type GCS struct {
+	*object.Adapter
+}
+
+// NewGCS creates a new instance of GCS, the client decides the endpoint and credentials
+func NewGCS(client *storage.Client, config GCSConfig) (adapter.Adapter, error) {
+	if config.Bucket == "" {
+		return nil, errNoBucket
+	}
+
+	if config.Visibility != "" && predefinedACL(config.Visibility) == "" {
+		return nil, object.ErrUnknownVisibility
+	}
+
+	store := &gcsStore{
+		bucket:    client.Bucket(config.Bucket),
+		chunkSize: config.ChunkSize,
+	}
+
+	return &GCS{object.New(store, adapter.NormalizePath(config.Prefix), config.Visibility)}, nil
+}
+
+// gcsStore is the object store of the GCS adapter
+type gcsStore struct {
+	bucket    *storage.BucketHandle
+	chunkSize int
+}
+
+// Head returns the attributes of an object
+func (s *gcsStore) Head(ctx context.Context, key string) (object.Object, error) {
+	attrs, err := s.bucket.Object(key).Attrs(ctx)
+	if err != nil {
+		return object.Object{}, err
+	}
+
+	return object.Object{
+		Key:          key,
+		Size:         attrs.Size,
+		LastModified: attrs.Updated,
+		MimeType:     attrs.ContentType,
+	}, nil
+}
+
+// Get opens an object for reading
+func (s *gcsStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return s.bucket.Object(key).NewReader(ctx)
+}
+
+// Put stores an object, contents larger than the chunk size are sent as a resumable upload
+func (s *gcsStore) Put(ctx context.Context, key string, contents io.Reader, mimeType string, visibility string, exclusive bool) error {
+	o := s.bucket.Object(key)
+	if exclusive {
+		o = o.If(storage.Conditions{DoesNotExist: true})
+	}
+
+	// Cancelling the context is the only way to abandon an upload
+	ctx, cancel := context.WithCancel(ctx)
+
+	defer cancel()
+
+	w := o.NewWriter(ctx)
+	w.ContentType = mimeType
+	w.PredefinedACL = predefinedACL(visibility)
+
+	if s.chunkSize > 0 {
+		w.ChunkSize = s.chunkSize
+	}
+
+	if _, err := io.Copy(w, contents); err != nil {
+		cancel()
+		w.Close()
+
+		return err
+	}
+
+	return w.Close()
+}
+
+// Copy copies an object on the server
+func (s *gcsStore) Copy(ctx context.Context, source string, destination string, visibility string) error {
+	copier := s.bucket.Object(destination).CopierFrom(s.bucket.Object(source))
+	copier.PredefinedACL = predefinedACL(visibility)
+
+	_, err := copier.Run(ctx)
+
+	return err
+}
+
+// Delete deletes objects one at a time, GCS has no batch delete in its client
+func (s *gcsStore) Delete(ctx context.Context, keys []string) error {
+	for _, key := range keys {
+		err := s.bucket.Object(key).Delete(ctx)
+		if err != nil && !errors.Is(err, storage.ErrObjectNotExist) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// List pages through the objects whose name starts with prefix
+func (s *gcsStore) List(ctx context.Context, prefix string, delimited bool, limit int) object.Pages {
+	query := &storage.Query{Prefix: prefix}
+
+	if delimited {
+		query.Delimiter = "/"
+	}
+
+	if limit <= 0 {
+		limit = gcsPageSize
+	}
+
+	return &gcsPages{pager: iterator.NewPager(s.bucket.Objects(ctx, query), limit, "")}
+}
+
+// Visibility reads the ACL of an object, anything readable by everyone is public
+func (s *gcsStore) Visibility(ctx context.Context, key string) (string, error) {
+	rules, err := s.bucket.Object(key).ACL().List(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	for _, rule := range rules {
+		if rule.Entity == storage.AllUsers && (rule.Role == storage.RoleReader || rule.Role == storage.RoleOwner) {
+			return adapter.VisibilityPublic, nil
+		}
+	}
+
+	return adapter.VisibilityPrivate, nil
+}
+
+// SetVisibility grants or revokes read access for everyone
+func (s *gcsStore) SetVisibility(ctx context.Context, key string, visibility string) error {
+	acl := s.bucket.Object(key).ACL()
+
+	if visibility == adapter.VisibilityPublic {
+		return acl.Set(ctx, storage.AllUsers, storage.RoleReader)
+	}
+
+	// GCS responds not found when there was no grant to revoke
+	err := acl.Delete(ctx, storage.AllUsers)
+	if s.Kind(err) == adapter.ErrFileNotFound {
+		return nil
+	}
+
+	return err
+}
+
+// Kind maps the status codes of GCS to the error kinds
+func (s *gcsStore) Kind(err error) error {
+	if errors.Is(err, storage.ErrObjectNotExist) {
+		return adapter.ErrFileNotFound
+	}
+
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	switch apiErr.Code {
+	case http.StatusNotFound:
+		return adapter.ErrFileNotFound
+	case http.StatusPreconditionFailed:
+		return adapter.ErrFileExists
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return adapter.ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// gcsPages pages through a listing of GCS objects
+type gcsPages struct {
+	pager *iterator.Pager
+	done  bool
+}
+
+// More reports whether there is another page
+func (p *gcsPages) More() bool {
+	return !p.done
+}
+
+// Next fetches the next page
+func (p *gcsPages) Next() (object.Page, error) {
+	var attrs []*storage.ObjectAttrs
+
+	token, err := p.pager.NextPage(&attrs)
+	if err != nil {
+		return object.Page{}, err
+	}
+
+	p.done = token == ""
+
+	var page object.Page
+
+	for _, a := range attrs {
+		if a.Prefix != "" {
+			page.Prefixes = append(page.Prefixes, a.Prefix)
+
+			continue
+		}
+
+		page.Objects = append(page.Objects, object.Object{
+			Key:          a.Name,
+			Size:         a.Size,
+			LastModified: a.Updated,
+		})
+	}
+
+	return page, nil
+}
+
+// predefinedACL maps a visibility to a predefined ACL
+func predefinedACL(visibility string) string {
+	switch visibility {
+	case adapter.VisibilityPublic:
+		return "publicRead"
+	case adapter.VisibilityPrivate:
+		return "private"
+	}
+
+	return ""
+}
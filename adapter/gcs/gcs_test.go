@@ -0,0 +1,359 @@
+package gcs
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/fsouza/fake-gcs-server/fakestorage"
+)
+
+func newGCS(t *testing.T, config GCSConfig) adapter.Adapter {
+	server, err := fakestorage.NewServerWithOptions(fakestorage.Options{NoListener: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(server.Stop)
+
+	server.CreateBucketWithOpts(fakestorage.CreateBucketOpts{Name: "test"})
+
+	config.Bucket = "test"
+
+	fs, err := NewGCS(server.Client(), config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestGCS_Write(t *testing.T) {
+	fs := newGCS(t, GCSConfig{Visibility: adapter.VisibilityPublic})
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != adapter.VisibilityPublic || !strings.HasPrefix(attributes.MimeType, "text/plain") {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestGCS_Update(t *testing.T) {
+	fs := newGCS(t, GCSConfig{Visibility: adapter.VisibilityPublic})
+
+	err := fs.Update("test.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("test.txt")
+	if err != nil || attributes.Size != 5 || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("test.txt", adapter.VisibilityPublic)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("test.txt")
+	if err != nil || attributes.Visibility != adapter.VisibilityPublic {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+}
+
+func TestGCS_Stream(t *testing.T) {
+	fs := newGCS(t, GCSConfig{ChunkSize: 256 * 1024})
+
+	contents := bytes.Repeat([]byte("0123456789"), 60*1024)
+
+	err := fs.WriteStream("stream.bin", bytes.NewReader(contents))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("stream.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	read, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(read, contents) {
+		t.Log("stream contents don't match")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("stream.bin")
+	if err != nil || attributes.Size != int64(len(contents)) || attributes.Visibility != "" {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+}
+
+func TestGCS_Rename(t *testing.T) {
+	fs := newGCS(t, GCSConfig{})
+
+	for _, p := range []string{"dir/a.txt", "dir/sub/b.txt", "file.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := fs.Rename("file.txt", "renamed.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("file.txt"); ok {
+		t.Log("expected file.txt to be gone")
+		t.Fail()
+	}
+
+	err = fs.Rename("dir", "moved")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("moved/sub/b.txt")
+	if err != nil || string(contents) != "dir/sub/b.txt" {
+		t.Logf("expected the directory contents to move, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir"); ok {
+		t.Log("expected dir to be gone")
+		t.Fail()
+	}
+
+	err = fs.Rename("missing.txt", "other.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestGCS_Copy(t *testing.T) {
+	fs := newGCS(t, GCSConfig{Visibility: adapter.VisibilityPrivate})
+
+	err := fs.Write("test file.txt", []byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.SetVisibility("test file.txt", adapter.VisibilityPublic)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Copy("test file.txt", "sub/copy.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/copy.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("copy does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/copy.txt")
+	if err != nil || attributes.Visibility != adapter.VisibilityPublic {
+		t.Logf("expected the copy to keep its visibility, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "copy.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestGCS_Directories(t *testing.T) {
+	fs := newGCS(t, GCSConfig{})
+
+	err := fs.CreateDir("empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("empty")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("implicit/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, dir := range []string{"empty", "implicit"} {
+		attributes, err := fs.Stat(dir)
+		if err != nil || !attributes.IsDir() {
+			t.Logf("expected %s to be a directory, got %+v %v", dir, attributes, err)
+			t.Fail()
+		}
+	}
+
+	err = fs.Delete("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	for _, dir := range []string{"", "/"} {
+		err = fs.DeleteDir(dir)
+		if !errors.Is(err, adapter.ErrPermissionDenied) {
+			t.Logf("expected ErrPermissionDenied when deleting the root, got %v", err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.FileExists("implicit/test.txt"); !ok {
+		t.Log("expected the root to be left alone")
+		t.Fail()
+	}
+
+	for _, dir := range []string{"empty", "implicit"} {
+		err = fs.DeleteDir(dir)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		if ok, _ := fs.Has(dir); ok {
+			t.Logf("expected %s to be deleted", dir)
+			t.Fail()
+		}
+	}
+}
+
+func TestGCS_ListContents(t *testing.T) {
+	fs := newGCS(t, GCSConfig{Prefix: "root"})
+
+	if err := fs.CreateDir("dir/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir"}},
+		{"dir", false, []string{"dir/b.txt", "dir/empty", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "dir/b.txt", "dir/empty", "dir/sub", "dir/sub/c.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := adapter.Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err := fs.ListContents("missing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestGCS_Context(t *testing.T) {
+	fs := newGCS(t, GCSConfig{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := adapter.WithContext(fs).WriteContext(ctx, "test.txt", []byte("hello"))
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("test.txt"); ok {
+		t.Log("expected the write to be cancelled")
+		t.Fail()
+	}
+}
@@ -0,0 +1,48 @@
+package adapter
+
+import "os"
+
+// The helpers below are for adapters implemented outside this package, such as the adapter modules
+// of this repository. They give those adapters the same errors, paths, mime types and listings as the built-in ones
+
+// WrapError wraps err for an operation on a file, a missing path is reported as ErrFileNotFound
+func WrapError(op string, path string, err error) error {
+	return wrapError(op, path, err)
+}
+
+// WrapDirError wraps err for an operation on a directory, a missing path is reported as ErrDirectoryNotFound
+func WrapDirError(op string, path string, err error) error {
+	return wrapDirError(op, path, err)
+}
+
+// NewError wraps err for an operation, a missing path is reported as notFound
+func NewError(op string, path string, notFound error, err error) error {
+	return newError(op, path, notFound, err)
+}
+
+// NormalizePath turns a path into a clean, slash separated path without leading or trailing slashes
+func NormalizePath(p string) string {
+	return normalizePath(p)
+}
+
+// DetectMimeType guesses the mime type by extension, falling back to sniffing the contents
+func DetectMimeType(path string, contents []byte) string {
+	return detectMimeType(path, contents)
+}
+
+// InfoAttributes turns file info into attributes, anything not readable by group or others is private.
+// head is only called when the mime type can't be told by the extension
+func InfoAttributes(path string, info os.FileInfo, head func() []byte) FileAttributes {
+	return infoAttributes(path, info, head)
+}
+
+// NewSliceListing creates a listing over attributes that are already in memory
+func NewSliceListing(contents []FileAttributes) DirectoryListing {
+	return newSliceListing(contents)
+}
+
+// NewReadDirListing creates a listing for stores that can only read a whole directory at once,
+// it reads dir right away so a missing directory is reported here
+func NewReadDirListing(dir string, deep bool, readDir func(dir string) ([]os.FileInfo, error), describe func(p string, info os.FileInfo) FileAttributes) (DirectoryListing, error) {
+	return newReadDirListing(dir, deep, readDir, describe)
+}
@@ -0,0 +1,215 @@
+package adapter
+
+import (
+	"os"
+	"path"
+)
+
+// DirectoryListing lazily iterates over the contents of a directory
+//
+//	listing, err := a.ListContents("dir", true)
+//	if err != nil {
+//		return err
+//	}
+//
+//	defer listing.Close()
+//
+//	for listing.Next() {
+//		attributes := listing.Attributes()
+//	}
+//
+//	return listing.Err()
+type DirectoryListing interface {
+	// Next advances to the next entry, it returns false when the listing is exhausted or an error occurred
+	Next() bool
+	// Attributes returns the attributes of the current entry
+	Attributes() FileAttributes
+	// Err returns the error, if any, that stopped the iteration
+	Err() error
+	// Close releases the resources held by the listing
+	Close() error
+}
+
+// Collect drains a listing into a slice and closes it
+func Collect(listing DirectoryListing) ([]FileAttributes, error) {
+	defer listing.Close()
+
+	var contents []FileAttributes
+
+	for listing.Next() {
+		contents = append(contents, listing.Attributes())
+	}
+
+	return contents, listing.Err()
+}
+
+// sliceListing iterates over attributes that are already in memory
+type sliceListing struct {
+	contents []FileAttributes
+	current  int
+}
+
+// newSliceListing creates a listing over contents
+func newSliceListing(contents []FileAttributes) *sliceListing {
+	return &sliceListing{contents: contents, current: -1}
+}
+
+// Next advances to the next entry
+func (l *sliceListing) Next() bool {
+	if l.current+1 >= len(l.contents) {
+		return false
+	}
+
+	l.current++
+
+	return true
+}
+
+// Attributes returns the attributes of the current entry
+func (l *sliceListing) Attributes() FileAttributes {
+	return l.contents[l.current]
+}
+
+// Err always returns nil
+func (l *sliceListing) Err() error {
+	return nil
+}
+
+// Close releases the entries
+func (l *sliceListing) Close() error {
+	l.contents = nil
+	l.current = -1
+
+	return nil
+}
+
+// readDirListing walks a directory tree a directory at a time,
+// for adapters that can only read a whole directory at once
+type readDirListing struct {
+	readDir    func(dir string) ([]os.FileInfo, error)
+	describe   func(p string, info os.FileInfo) FileAttributes
+	deep       bool
+	dir        string
+	pending    []string
+	batch      []os.FileInfo
+	attributes FileAttributes
+	err        error
+}
+
+// newReadDirListing creates a listing of dir, it reads dir right away so a missing directory is reported here
+func newReadDirListing(dir string, deep bool, readDir func(dir string) ([]os.FileInfo, error), describe func(p string, info os.FileInfo) FileAttributes) (*readDirListing, error) {
+	l := &readDirListing{
+		readDir:  readDir,
+		describe: describe,
+		deep:     deep,
+	}
+
+	err := l.open(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Next advances to the next entry
+func (l *readDirListing) Next() bool {
+	for l.err == nil {
+		if len(l.batch) > 0 {
+			info := l.batch[0]
+			l.batch = l.batch[1:]
+
+			p := path.Join(l.dir, info.Name())
+
+			if l.deep && info.IsDir() {
+				l.pending = append(l.pending, p)
+			}
+
+			l.attributes = l.describe(p, info)
+
+			return true
+		}
+
+		if len(l.pending) == 0 {
+			return false
+		}
+
+		next := l.pending[0]
+		l.pending = l.pending[1:]
+
+		err := l.open(next)
+		if err != nil && !os.IsNotExist(err) {
+			l.err = wrapDirError("list", next, err)
+		}
+	}
+
+	return false
+}
+
+// Attributes returns the attributes of the current entry
+func (l *readDirListing) Attributes() FileAttributes {
+	return l.attributes
+}
+
+// Err returns the error that stopped the iteration
+func (l *readDirListing) Err() error {
+	return l.err
+}
+
+// Close stops the listing
+func (l *readDirListing) Close() error {
+	l.pending = nil
+	l.batch = nil
+
+	return nil
+}
+
+func (l *readDirListing) open(dir string) error {
+	entries, err := l.readDir(dir)
+	if err != nil {
+		return err
+	}
+
+	l.batch = entries
+	l.dir = dir
+
+	return nil
+}
+
+// mapListing changes the attributes of every entry of another listing, an error stops the iteration
+type mapListing struct {
+	DirectoryListing
+	describe   func(attributes FileAttributes) (FileAttributes, error)
+	attributes FileAttributes
+	err        error
+}
+
+// newMapListing creates a listing that passes every entry of l through describe
+func newMapListing(l DirectoryListing, describe func(attributes FileAttributes) (FileAttributes, error)) *mapListing {
+	return &mapListing{DirectoryListing: l, describe: describe}
+}
+
+// Next advances to the next entry
+func (l *mapListing) Next() bool {
+	if l.err != nil || !l.DirectoryListing.Next() {
+		return false
+	}
+
+	l.attributes, l.err = l.describe(l.DirectoryListing.Attributes())
+
+	return l.err == nil
+}
+
+// Attributes returns the attributes of the current entry
+func (l *mapListing) Attributes() FileAttributes {
+	return l.attributes
+}
+
+// Err returns the error that stopped the iteration
+func (l *mapListing) Err() error {
+	if l.err != nil {
+		return l.err
+	}
+
+	return l.DirectoryListing.Err()
+}
@@ -1,11 +1,14 @@
 package adapter
 
 import (
-	"fmt"
-	"io/ioutil"
+	"bytes"
+	"io"
 	"os"
+	"path"
 	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 )
 
 // Local ...
@@ -30,23 +33,13 @@ const DirPublic = 0755
 
 // NewLocal creates a new instance of Local
 func NewLocal(root string) (Adapter, error) {
-	var permMap = map[string]map[string]os.FileMode{
-		"file": {},
-		"dir":  {},
-	}
-
-	permMap["file"]["public"] = FilePublic
-	permMap["file"]["private"] = FilePrivate
-	permMap["dir"]["public"] = DirPublic
-	permMap["dir"]["private"] = DirPrivate
-
 	a := &Local{
-		permMap: permMap,
+		permMap: newPermMap(),
 	}
 
 	err := a.ensureDirectory(root)
 	if err != nil {
-		return nil, err
+		return nil, &Error{Op: "mkdir", Path: root, Kind: ErrUnableToCreateRoot, Err: err}
 	}
 
 	a.SetPathPrefix(root)
@@ -55,53 +48,48 @@ func NewLocal(root string) (Adapter, error) {
 	return a, nil
 }
 
-// Write a new file
+// Write a new file, it fails if the file already exists
 func (a *Local) Write(path string, contents []byte) error {
-	a.lock.Lock()
-
-	defer a.lock.Unlock()
+	return a.write("write", path, bytes.NewReader(contents), os.O_CREATE|os.O_EXCL)
+}
 
-	location := a.ApplyPathPrefix(path)
+// Update a file, it fails if the file does not exist
+func (a *Local) Update(path string, contents []byte) error {
+	return a.write("update", path, bytes.NewReader(contents), os.O_TRUNC)
+}
 
-	dir, err := filepath.Abs(filepath.Dir(location))
-	if err != nil {
-		return err
-	}
+// Put writes a file, creating or overwriting it
+func (a *Local) Put(path string, contents []byte) error {
+	return a.write("put", path, bytes.NewReader(contents), os.O_CREATE|os.O_TRUNC)
+}
 
-	err = a.ensureDirectory(dir)
-	if err != nil {
-		return err
-	}
+// Read a file
+func (a *Local) Read(path string) ([]byte, error) {
+	location := a.ApplyPathPrefix(path)
 
-	err = ioutil.WriteFile(location, contents, FilePublic)
+	contents, err := os.ReadFile(location)
 	if err != nil {
-		return err
+		return nil, wrapError("read", path, err)
 	}
 
-	return nil
+	return contents, nil
 }
 
-// Update a file
-func (a *Local) Update(path string, contents []byte) error {
-	a.lock.Lock()
-
-	defer a.lock.Unlock()
+// WriteStream writes a new file from a stream, it fails if the file already exists
+func (a *Local) WriteStream(path string, contents io.Reader) error {
+	return a.write("write", path, contents, os.O_CREATE|os.O_EXCL)
+}
 
+// ReadStream opens a file for reading, the caller must close it
+func (a *Local) ReadStream(path string) (io.ReadCloser, error) {
 	location := a.ApplyPathPrefix(path)
 
-	err := ioutil.WriteFile(location, contents, FilePublic)
+	f, err := os.Open(location)
 	if err != nil {
-		return err
+		return nil, wrapError("read", path, err)
 	}
 
-	return nil
-}
-
-// Read a file
-func (a *Local) Read(path string) ([]byte, error) {
-	location := a.ApplyPathPrefix(path)
-
-	return ioutil.ReadFile(location)
+	return f, nil
 }
 
 // Rename a file
@@ -113,7 +101,7 @@ func (a *Local) Rename(path string, newPath string) error {
 	location := a.ApplyPathPrefix(path)
 	destination := a.ApplyPathPrefix(newPath)
 
-	return os.Rename(location, destination)
+	return wrapError("rename", path, os.Rename(location, destination))
 }
 
 // Copy a file
@@ -128,15 +116,15 @@ func (a *Local) Copy(path string, newPath string) error {
 	// Get file permissions
 	info, err := os.Stat(location)
 	if err != nil {
-		return err
+		return wrapError("copy", path, err)
 	}
 
-	input, err := ioutil.ReadFile(location)
+	input, err := os.ReadFile(location)
 	if err != nil {
-		return err
+		return wrapError("copy", path, err)
 	}
 
-	return ioutil.WriteFile(destination, input, info.Mode())
+	return wrapError("copy", newPath, os.WriteFile(destination, input, info.Mode()))
 }
 
 // Delete a file
@@ -147,7 +135,7 @@ func (a *Local) Delete(path string) error {
 
 	location := a.ApplyPathPrefix(path)
 
-	return os.Remove(location)
+	return wrapError("delete", path, os.Remove(location))
 }
 
 // CreateDir creates a directory
@@ -158,18 +146,22 @@ func (a *Local) CreateDir(dir string) error {
 
 	location := a.ApplyPathPrefix(dir)
 
-	return os.Mkdir(location, DirPublic)
+	return wrapDirError("mkdir", dir, os.Mkdir(location, DirPublic))
 }
 
-// DeleteDir deletes a directory
+// DeleteDir deletes a directory, the root can't be deleted
 func (a *Local) DeleteDir(dir string) error {
 	a.lock.Lock()
 
 	defer a.lock.Unlock()
 
+	if normalizePath(dir) == "" {
+		return deleteRootError(dir)
+	}
+
 	location := a.ApplyPathPrefix(dir)
 
-	return os.RemoveAll(location)
+	return wrapDirError("rmdir", dir, os.RemoveAll(location))
 }
 
 // SetVisibility sets a file or directory to public or private
@@ -182,7 +174,7 @@ func (a *Local) SetVisibility(path string, visibility string) error {
 
 	info, err := os.Stat(location)
 	if err != nil {
-		return err
+		return wrapError("chmod", path, err)
 	}
 
 	var perm os.FileMode
@@ -193,16 +185,383 @@ func (a *Local) SetVisibility(path string, visibility string) error {
 		perm = a.permMap["file"][visibility]
 	}
 
-	return os.Chmod(location, perm)
+	return wrapError("chmod", path, os.Chmod(location, perm))
+}
+
+// Has checks if a file or directory exists
+func (a *Local) Has(path string) (bool, error) {
+	location := a.ApplyPathPrefix(path)
+
+	_, err := os.Stat(location)
+	if err == nil {
+		return true, nil
+	}
+
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+
+	return false, wrapError("stat", path, err)
+}
+
+// FileExists checks if a file exists
+func (a *Local) FileExists(path string) (bool, error) {
+	location := a.ApplyPathPrefix(path)
+
+	info, err := os.Stat(location)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, wrapError("stat", path, err)
+	}
+
+	return !info.IsDir(), nil
+}
+
+// DirectoryExists checks if a directory exists
+func (a *Local) DirectoryExists(dir string) (bool, error) {
+	location := a.ApplyPathPrefix(dir)
+
+	info, err := os.Stat(location)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return false, nil
+		}
+
+		return false, wrapDirError("stat", dir, err)
+	}
+
+	return info.IsDir(), nil
+}
+
+// Stat returns the attributes of a file or directory
+func (a *Local) Stat(path string) (FileAttributes, error) {
+	location := a.ApplyPathPrefix(path)
+
+	info, err := os.Stat(location)
+	if err != nil {
+		return FileAttributes{}, wrapError("stat", path, err)
+	}
+
+	return a.attributes(path, location, info), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories
+func (a *Local) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	l := &localListing{
+		adapter: a,
+		deep:    deep,
+	}
+
+	err := l.open(strings.Trim(filepath.ToSlash(dir), "/"))
+	if err != nil {
+		return nil, wrapDirError("list", dir, err)
+	}
+
+	return l, nil
+}
+
+func (a *Local) attributes(path string, location string, info os.FileInfo) FileAttributes {
+	return infoAttributes(filepath.ToSlash(path), info, func() []byte {
+		return a.head(location)
+	})
+}
+
+// write streams contents into a temporary file next to path and renames it into place once complete,
+// the lock is only held to check and replace the file so a slow stream doesn't block other operations
+// and a failed stream leaves the existing file untouched
+func (a *Local) write(op string, path string, contents io.Reader, flag int) error {
+	location := a.ApplyPathPrefix(path)
+
+	err := a.prepareWrite(op, path, location, flag)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(location), "."+filepath.Base(location)+".*.tmp")
+	if err != nil {
+		return wrapError(op, path, err)
+	}
+
+	_, err = io.Copy(tmp, contents)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = a.commitWrite(tmp.Name(), location, flag)
+	}
+
+	if err != nil {
+		os.Remove(tmp.Name())
+
+		return wrapError(op, path, err)
+	}
+
+	return nil
+}
+
+// prepareWrite checks that location can be written with flag, creating its directory if flag allows creating the file
+func (a *Local) prepareWrite(op string, path string, location string, flag int) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	if flag&os.O_CREATE != 0 {
+		dir, err := filepath.Abs(filepath.Dir(location))
+		if err != nil {
+			return wrapError(op, path, err)
+		}
+
+		err = a.ensureDirectory(dir)
+		if err != nil {
+			return wrapDirError(op, path, err)
+		}
+	}
+
+	_, err := checkWrite(location, flag)
+
+	return wrapError(op, path, err)
+}
+
+// commitWrite moves the temporary file into place if location can still be written with flag
+func (a *Local) commitWrite(tmp string, location string, flag int) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	perm, err := checkWrite(location, flag)
+	if err != nil {
+		return err
+	}
+
+	err = os.Chmod(tmp, perm)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, location)
+}
+
+// checkWrite reports whether location can be written with flag and returns the permissions the file should get,
+// an existing file keeps its permissions
+func checkWrite(location string, flag int) (os.FileMode, error) {
+	info, err := os.Stat(location)
+
+	switch {
+	case err == nil && info.IsDir():
+		return 0, &os.PathError{Op: "open", Path: location, Err: syscall.EISDIR}
+	case err == nil && flag&os.O_EXCL != 0:
+		return 0, &os.PathError{Op: "open", Path: location, Err: syscall.EEXIST}
+	case err == nil:
+		return info.Mode().Perm(), nil
+	case os.IsNotExist(err) && flag&os.O_CREATE != 0:
+		return FilePublic, nil
+	}
+
+	return 0, err
+}
+
+// head reads the first bytes of a file for mime type detection
+func (a *Local) head(location string) []byte {
+	f, err := os.Open(location)
+	if err != nil {
+		return nil
+	}
+
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+
+	return buf[:n]
 }
 
 func (a *Local) ensureDirectory(dir string) error {
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		err := os.Mkdir(dir, DirPublic)
-		if err != nil {
-			return fmt.Errorf("impossible to create the root directory %s", dir)
+		return os.Mkdir(dir, DirPublic)
+	}
+
+	return nil
+}
+
+// newPermMap returns the permissions of public and private files and directories
+func newPermMap() map[string]map[string]os.FileMode {
+	var permMap = map[string]map[string]os.FileMode{
+		"file": {},
+		"dir":  {},
+	}
+
+	permMap["file"]["public"] = FilePublic
+	permMap["file"]["private"] = FilePrivate
+	permMap["dir"]["public"] = DirPublic
+	permMap["dir"]["private"] = DirPrivate
+
+	return permMap
+}
+
+// infoAttributes turns file info into attributes,
+// head is only called when the mime type can't be told by the extension
+func infoAttributes(path string, info os.FileInfo, head func() []byte) FileAttributes {
+	attributes := FileAttributes{
+		Path:         path,
+		LastModified: info.ModTime(),
+		Visibility:   VisibilityPublic,
+	}
+
+	// Anything not readable by group or others is considered private
+	if info.Mode().Perm()&0077 == 0 {
+		attributes.Visibility = VisibilityPrivate
+	}
+
+	if info.IsDir() {
+		attributes.Type = TypeDir
+
+		return attributes
+	}
+
+	attributes.Type = TypeFile
+	attributes.Size = info.Size()
+	attributes.MimeType = detectMimeType(path, nil)
+
+	if attributes.MimeType == "" {
+		attributes.MimeType = detectMimeType(path, head())
+	}
+
+	return attributes
+}
+
+// listingBatchSize is the number of directory entries read from disk at once
+const listingBatchSize = 128
+
+// localListing walks a directory tree without loading it into memory
+type localListing struct {
+	adapter    *Local
+	deep       bool
+	current    *os.File
+	dir        string
+	pending    []string
+	batch      []os.DirEntry
+	attributes FileAttributes
+	err        error
+}
+
+// Next advances to the next entry
+func (l *localListing) Next() bool {
+	for l.err == nil {
+		if len(l.batch) > 0 {
+			entry := l.batch[0]
+			l.batch = l.batch[1:]
+
+			info, err := entry.Info()
+			if err != nil {
+				// The entry was removed while listing
+				if os.IsNotExist(err) {
+					continue
+				}
+
+				l.err = wrapError("list", path.Join(l.dir, entry.Name()), err)
+
+				return false
+			}
+
+			p := path.Join(l.dir, entry.Name())
+
+			if l.deep && info.IsDir() {
+				l.pending = append(l.pending, p)
+			}
+
+			l.attributes = l.adapter.attributes(p, l.adapter.ApplyPathPrefix(filepath.FromSlash(p)), info)
+
+			return true
+		}
+
+		if l.current != nil {
+			entries, err := l.current.ReadDir(listingBatchSize)
+			if len(entries) > 0 {
+				l.batch = entries
+
+				continue
+			}
+
+			if err != nil && err != io.EOF {
+				l.err = wrapDirError("list", l.dir, err)
+
+				return false
+			}
+
+			l.err = l.current.Close()
+			l.current = nil
+
+			continue
 		}
+
+		if len(l.pending) == 0 {
+			return false
+		}
+
+		next := l.pending[0]
+		l.pending = l.pending[1:]
+
+		err := l.open(next)
+		if err != nil && !os.IsNotExist(err) {
+			l.err = wrapDirError("list", next, err)
+		}
+	}
+
+	return false
+}
+
+// Attributes returns the attributes of the current entry
+func (l *localListing) Attributes() FileAttributes {
+	return l.attributes
+}
+
+// Err returns the error that stopped the iteration
+func (l *localListing) Err() error {
+	return l.err
+}
+
+// Close closes the directory currently being read
+func (l *localListing) Close() error {
+	l.pending = nil
+	l.batch = nil
+
+	if l.current == nil {
+		return nil
+	}
+
+	err := l.current.Close()
+	l.current = nil
+
+	return err
+}
+
+func (l *localListing) open(dir string) error {
+	location := l.adapter.ApplyPathPrefix(filepath.FromSlash(dir))
+
+	f, err := os.Open(location)
+	if err != nil {
+		return err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+
+		return err
 	}
 
+	if !info.IsDir() {
+		f.Close()
+
+		return &os.PathError{Op: "readdir", Path: location, Err: syscall.ENOTDIR}
+	}
+
+	l.current = f
+	l.dir = dir
+
 	return nil
 }
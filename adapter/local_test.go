@@ -1,10 +1,13 @@
 package adapter
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
+	"strings"
 	"testing"
+	"testing/iotest"
 )
 
 var dataPath = "../_testdata/local"
@@ -43,7 +46,7 @@ func TestLocal_Write(t *testing.T) {
 		t.Fail()
 	}
 
-	bytes, err := ioutil.ReadFile("../_testdata/local/test.txt")
+	bytes, err := os.ReadFile("../_testdata/local/test.txt")
 	if err != nil {
 		t.Log(err)
 		t.Fail()
@@ -54,6 +57,12 @@ func TestLocal_Write(t *testing.T) {
 		t.Fail()
 	}
 
+	err = fs.Write("test.txt", []byte("hello again"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
 	fs, err = NewLocal("../_testdata/should/fail")
 
 	if err == nil {
@@ -95,12 +104,59 @@ func TestLocal_Update(t *testing.T) {
 	}
 
 	err = fs.Update("test.txt", []byte("hello"))
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	bytes, err := os.ReadFile("../_testdata/local/test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if string(bytes) != "hello" {
+		t.Log("files does not contain: hello")
+		t.Fail()
+	}
+}
+
+func TestLocal_Put(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Put("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Put("test.txt", []byte("hello"))
 	if err != nil {
 		t.Log(err)
 		t.Fail()
 	}
 
-	bytes, err := ioutil.ReadFile("../_testdata/local/test.txt")
+	bytes, err := os.ReadFile("../_testdata/local/test.txt")
 	if err != nil {
 		t.Log(err)
 		t.Fail()
@@ -123,7 +179,7 @@ func TestLocal_Read(t *testing.T) {
 		t.Fail()
 	}
 
-	err = fs.Update("test.txt", []byte("hello"))
+	err = fs.Write("test.txt", []byte("hello"))
 	if err != nil {
 		t.Log(err)
 		t.Fail()
@@ -312,6 +368,17 @@ func TestLocal_DeleteDir(t *testing.T) {
 		t.Log(err)
 		t.Fail()
 	}
+
+	err = fs.DeleteDir("/")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Logf("expected ErrPermissionDenied, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists(""); !ok {
+		t.Log("expected the root to be left alone")
+		t.Fail()
+	}
 }
 
 func TestLocal_SetVisibility(t *testing.T) {
@@ -373,3 +440,537 @@ func TestLocal_SetVisibility(t *testing.T) {
 		t.Fail()
 	}
 }
+
+func TestLocal_Has(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("subdir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	ok, err := fs.Has("test.txt")
+	if err != nil || !ok {
+		t.Log("expected test.txt to exist")
+		t.Fail()
+	}
+
+	ok, err = fs.Has("subdir")
+	if err != nil || !ok {
+		t.Log("expected subdir to exist")
+		t.Fail()
+	}
+
+	ok, err = fs.Has("non-existing.txt")
+	if err != nil || ok {
+		t.Log("expected non-existing.txt not to exist")
+		t.Fail()
+	}
+}
+
+func TestLocal_FileExists(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("subdir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	ok, err := fs.FileExists("test.txt")
+	if err != nil || !ok {
+		t.Log("expected test.txt to be a file")
+		t.Fail()
+	}
+
+	ok, err = fs.FileExists("subdir")
+	if err != nil || ok {
+		t.Log("expected subdir not to be a file")
+		t.Fail()
+	}
+
+	ok, err = fs.FileExists("non-existing.txt")
+	if err != nil || ok {
+		t.Log("expected non-existing.txt not to exist")
+		t.Fail()
+	}
+}
+
+func TestLocal_DirectoryExists(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("subdir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	ok, err := fs.DirectoryExists("subdir")
+	if err != nil || !ok {
+		t.Log("expected subdir to be a directory")
+		t.Fail()
+	}
+
+	ok, err = fs.DirectoryExists("test.txt")
+	if err != nil || ok {
+		t.Log("expected test.txt not to be a directory")
+		t.Fail()
+	}
+
+	ok, err = fs.DirectoryExists("non-existing")
+	if err != nil || ok {
+		t.Log("expected non-existing not to exist")
+		t.Fail()
+	}
+}
+
+func TestLocal_Stat(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if !attributes.IsFile() || attributes.Size != 11 {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+
+	if attributes.MimeType != "text/plain; charset=utf-8" {
+		t.Logf("unexpected mime type: %s", attributes.MimeType)
+		t.Fail()
+	}
+
+	if attributes.Visibility != VisibilityPublic || attributes.LastModified.IsZero() {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("test.txt", VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if attributes.Visibility != VisibilityPrivate {
+		t.Logf("expected private visibility, got %s", attributes.Visibility)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("subdir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("subdir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if !attributes.IsDir() {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+
+	_, err = fs.Stat("non-existing.txt")
+	if err == nil {
+		t.Log("expected an error: non existing file")
+		t.Fail()
+	}
+}
+
+func TestLocal_ListContents(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath + "/listing")
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt"} {
+		err = fs.Write(p, []byte("hello world"))
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+	}
+
+	listing, err := fs.ListContents("", false)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := Collect(listing)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if len(contents) != 2 {
+		t.Logf("expected 2 entries, got %d", len(contents))
+		t.Fail()
+	}
+
+	listing, err = fs.ListContents("", true)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	paths := map[string]string{}
+
+	for listing.Next() {
+		attributes := listing.Attributes()
+		paths[attributes.Path] = attributes.Type
+	}
+
+	if err := listing.Err(); err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	listing.Close()
+
+	expected := map[string]string{
+		"a.txt":            TypeFile,
+		"sub":              TypeDir,
+		"sub/b.txt":        TypeFile,
+		"sub/deeper":       TypeDir,
+		"sub/deeper/c.txt": TypeFile,
+	}
+
+	if len(paths) != len(expected) {
+		t.Logf("unexpected listing: %v", paths)
+		t.Fail()
+	}
+
+	for p, typ := range expected {
+		if paths[p] != typ {
+			t.Logf("expected %s to be a %s", p, typ)
+			t.Fail()
+		}
+	}
+
+	listing, err = fs.ListContents("sub", false)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err = Collect(listing)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if len(contents) != 2 || contents[0].Path[:4] != "sub/" {
+		t.Logf("unexpected listing: %v", contents)
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("non-existing", false)
+	if err == nil {
+		t.Log("expected an error: non existing directory")
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("a.txt", false)
+	if err == nil {
+		t.Log("expected an error: not a directory")
+		t.Fail()
+	}
+}
+
+func TestLocal_WriteStream(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream/test.txt", strings.NewReader("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	bytes, err := os.ReadFile("../_testdata/local/stream/test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if string(bytes) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream/broken.txt", iotest.ErrReader(errors.New("broken")))
+	if err == nil {
+		t.Log("expected an error: broken reader")
+		t.Fail()
+	}
+
+	if _, err := os.Stat("../_testdata/local/stream/broken.txt"); !os.IsNotExist(err) {
+		t.Log("expected the partial file to be removed")
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream/test.txt", strings.NewReader("hello again"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestLocal_WriteStreamStalled(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Write("stream/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	r, w := io.Pipe()
+	done := make(chan error)
+
+	go func() {
+		done <- fs.WriteStream("stream/stalled.txt", r)
+	}()
+
+	// A stalled upload must not block other operations
+	_, err = w.Write([]byte("partial"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("stream/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Logf("expected to read hello world, got %q, %v", contents, err)
+		t.Fail()
+	}
+
+	err = fs.Put("stream/test.txt", []byte("hello again"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	w.CloseWithError(errors.New("broken"))
+
+	if err := <-done; err == nil {
+		t.Log("expected an error: broken reader")
+		t.Fail()
+	}
+
+	entries, err := os.ReadDir("../_testdata/local/stream")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if len(entries) != 1 || entries[0].Name() != "test.txt" {
+		t.Logf("expected only test.txt to remain, got %d entries", len(entries))
+		t.Fail()
+	}
+}
+
+func TestLocal_ReadStream(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	stream, err := fs.ReadStream("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer stream.Close()
+
+	contents, err := io.ReadAll(stream)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if string(contents) != "hello" {
+		t.Log("files does not contain: hello")
+		t.Fail()
+	}
+
+	_, err = fs.ReadStream("non-existing.txt")
+	if err == nil {
+		t.Log("expected an error: non existing file")
+		t.Fail()
+	}
+}
+
+func TestLocal_Errors(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	fs, err := NewLocal(dataPath)
+
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("non-existing.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Delete("non-existing.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("non-existing", false)
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("non-existing/deeper/test.txt", []byte("hello"))
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("subdir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("subdir")
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	_, err = NewLocal("../_testdata/should/fail")
+	if !errors.Is(err, ErrUnableToCreateRoot) {
+		t.Logf("expected ErrUnableToCreateRoot, got %v", err)
+		t.Fail()
+	}
+
+	if os.Geteuid() == 0 {
+		return
+	}
+
+	err = fs.Write("private.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	os.Chmod("../_testdata/local/private.txt", 0200)
+
+	_, err = fs.Read("private.txt")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Logf("expected ErrPermissionDenied, got %v", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,458 @@
+package adapter
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errMoveIntoItself = errors.New("can't move a directory into itself")
+
+// Memory keeps files and directories in memory, it is safe for concurrent use
+type Memory struct {
+	lock    *sync.RWMutex
+	entries map[string]*memoryEntry
+}
+
+// memoryEntry is a file or directory
+type memoryEntry struct {
+	dir          bool
+	contents     []byte
+	visibility   string
+	lastModified time.Time
+}
+
+// NewMemory creates a new instance of Memory
+func NewMemory() Adapter {
+	return &Memory{
+		lock:    &sync.RWMutex{},
+		entries: map[string]*memoryEntry{},
+	}
+}
+
+// Write a new file, it fails if the file already exists
+func (a *Memory) Write(path string, contents []byte) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	p := normalizePath(path)
+
+	if _, ok := a.entries[p]; ok || p == "" {
+		return wrapError("write", path, ErrFileExists)
+	}
+
+	return a.put("write", p, contents)
+}
+
+// Update a file, it fails if the file does not exist
+func (a *Memory) Update(path string, contents []byte) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	p := normalizePath(path)
+
+	entry, ok := a.entries[p]
+	if !ok || entry.dir {
+		return wrapError("update", path, ErrFileNotFound)
+	}
+
+	entry.contents = copyBytes(contents)
+	entry.lastModified = time.Now()
+
+	return nil
+}
+
+// Put writes a file, creating or overwriting it
+func (a *Memory) Put(path string, contents []byte) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	p := normalizePath(path)
+
+	if a.isDir(p) {
+		return wrapError("put", path, ErrFileExists)
+	}
+
+	return a.put("put", p, contents)
+}
+
+// Read a file
+func (a *Memory) Read(path string) ([]byte, error) {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	entry, ok := a.entries[normalizePath(path)]
+	if !ok || entry.dir {
+		return nil, wrapError("read", path, ErrFileNotFound)
+	}
+
+	return copyBytes(entry.contents), nil
+}
+
+// WriteStream writes a new file from a stream, it fails if the file already exists
+func (a *Memory) WriteStream(path string, contents io.Reader) error {
+	if ok, _ := a.Has(path); ok {
+		return wrapError("write", path, ErrFileExists)
+	}
+
+	buf, err := io.ReadAll(contents)
+	if err != nil {
+		return wrapError("write", path, err)
+	}
+
+	return a.Write(path, buf)
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *Memory) ReadStream(path string) (io.ReadCloser, error) {
+	contents, err := a.Read(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// Rename a file or directory
+func (a *Memory) Rename(path string, newPath string) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	p := normalizePath(path)
+	destination := normalizePath(newPath)
+
+	entry, ok := a.entries[p]
+	if !ok {
+		return wrapError("rename", path, ErrFileNotFound)
+	}
+
+	if entry.dir && strings.HasPrefix(destination+"/", p+"/") {
+		return wrapError("rename", newPath, errMoveIntoItself)
+	}
+
+	// Replacing a directory would orphan its contents
+	if existing, ok := a.entries[destination]; destination == "" || ok && (existing.dir || entry.dir) {
+		return wrapError("rename", newPath, ErrFileExists)
+	}
+
+	if err := a.ensureParents("rename", destination); err != nil {
+		return err
+	}
+
+	moved := map[string]*memoryEntry{destination: entry}
+
+	delete(a.entries, p)
+
+	for child, e := range a.entries {
+		if entry.dir && strings.HasPrefix(child, p+"/") {
+			moved[destination+strings.TrimPrefix(child, p)] = e
+			delete(a.entries, child)
+		}
+	}
+
+	for child, e := range moved {
+		a.entries[child] = e
+	}
+
+	return nil
+}
+
+// Copy a file
+func (a *Memory) Copy(path string, newPath string) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	entry, ok := a.entries[normalizePath(path)]
+	if !ok || entry.dir {
+		return wrapError("copy", path, ErrFileNotFound)
+	}
+
+	destination := normalizePath(newPath)
+
+	if a.isDir(destination) {
+		return wrapError("copy", newPath, ErrFileExists)
+	}
+
+	if err := a.ensureParents("copy", destination); err != nil {
+		return err
+	}
+
+	a.entries[destination] = &memoryEntry{
+		contents:     copyBytes(entry.contents),
+		visibility:   entry.visibility,
+		lastModified: time.Now(),
+	}
+
+	return nil
+}
+
+// Delete a file
+func (a *Memory) Delete(path string) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	p := normalizePath(path)
+
+	entry, ok := a.entries[p]
+	if !ok || entry.dir {
+		return wrapError("delete", path, ErrFileNotFound)
+	}
+
+	delete(a.entries, p)
+
+	return nil
+}
+
+// CreateDir creates a directory
+func (a *Memory) CreateDir(dir string) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	p := normalizePath(dir)
+
+	if _, ok := a.entries[p]; ok || p == "" {
+		return wrapDirError("mkdir", dir, ErrFileExists)
+	}
+
+	if err := a.ensureParents("mkdir", p); err != nil {
+		return err
+	}
+
+	a.entries[p] = &memoryEntry{dir: true, visibility: VisibilityPublic, lastModified: time.Now()}
+
+	return nil
+}
+
+// DeleteDir deletes a directory and its contents, the root can't be deleted
+func (a *Memory) DeleteDir(dir string) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	p := normalizePath(dir)
+	if p == "" {
+		return deleteRootError(dir)
+	}
+
+	if entry, ok := a.entries[p]; ok && !entry.dir {
+		return wrapDirError("rmdir", dir, ErrDirectoryNotFound)
+	}
+
+	delete(a.entries, p)
+
+	for child := range a.entries {
+		if strings.HasPrefix(child, p+"/") {
+			delete(a.entries, child)
+		}
+	}
+
+	return nil
+}
+
+// SetVisibility sets a file or directory to public or private
+func (a *Memory) SetVisibility(path string, visibility string) error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	entry, ok := a.entries[normalizePath(path)]
+	if !ok {
+		return wrapError("chmod", path, ErrFileNotFound)
+	}
+
+	entry.visibility = visibility
+
+	return nil
+}
+
+// Has checks if a file or directory exists, the root always exists
+func (a *Memory) Has(path string) (bool, error) {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	p := normalizePath(path)
+
+	_, ok := a.entries[p]
+
+	return p == "" || ok, nil
+}
+
+// FileExists checks if a file exists
+func (a *Memory) FileExists(path string) (bool, error) {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	entry, ok := a.entries[normalizePath(path)]
+
+	return ok && !entry.dir, nil
+}
+
+// DirectoryExists checks if a directory exists
+func (a *Memory) DirectoryExists(dir string) (bool, error) {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	p := normalizePath(dir)
+	if p == "" {
+		return true, nil
+	}
+
+	entry, ok := a.entries[p]
+
+	return ok && entry.dir, nil
+}
+
+// Stat returns the attributes of a file or directory, the root is a directory
+func (a *Memory) Stat(path string) (FileAttributes, error) {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	p := normalizePath(path)
+	if p == "" {
+		return FileAttributes{Type: TypeDir, Visibility: VisibilityPublic}, nil
+	}
+
+	entry, ok := a.entries[p]
+	if !ok {
+		return FileAttributes{}, wrapError("stat", path, ErrFileNotFound)
+	}
+
+	return entry.attributes(p), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// The listing is a snapshot taken when it is created
+func (a *Memory) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	p := normalizePath(dir)
+
+	if entry, ok := a.entries[p]; p != "" && (!ok || !entry.dir) {
+		return nil, wrapDirError("list", dir, ErrDirectoryNotFound)
+	}
+
+	prefix := p + "/"
+	if p == "" {
+		prefix = ""
+	}
+
+	var contents []FileAttributes
+
+	for child, entry := range a.entries {
+		if !strings.HasPrefix(child, prefix) {
+			continue
+		}
+
+		if !deep && strings.Contains(strings.TrimPrefix(child, prefix), "/") {
+			continue
+		}
+
+		contents = append(contents, entry.attributes(child))
+	}
+
+	sort.Slice(contents, func(i, j int) bool {
+		return contents[i].Path < contents[j].Path
+	})
+
+	return newSliceListing(contents), nil
+}
+
+// put stores a file, the lock must be held
+func (a *Memory) put(op string, p string, contents []byte) error {
+	if err := a.ensureParents(op, p); err != nil {
+		return err
+	}
+
+	visibility := VisibilityPublic
+	if entry, ok := a.entries[p]; ok {
+		visibility = entry.visibility
+	}
+
+	a.entries[p] = &memoryEntry{
+		contents:     copyBytes(contents),
+		visibility:   visibility,
+		lastModified: time.Now(),
+	}
+
+	return nil
+}
+
+// ensureParents creates the missing parent directories of p, the lock must be held
+func (a *Memory) ensureParents(op string, p string) error {
+	dir := path.Dir(p)
+
+	for dir != "." && dir != "/" {
+		entry, ok := a.entries[dir]
+		if ok && !entry.dir {
+			return wrapDirError(op, p, ErrDirectoryNotFound)
+		}
+
+		if !ok {
+			a.entries[dir] = &memoryEntry{dir: true, visibility: VisibilityPublic, lastModified: time.Now()}
+		}
+
+		dir = path.Dir(dir)
+	}
+
+	return nil
+}
+
+// isDir reports whether p is the root or a directory, the lock must be held
+func (a *Memory) isDir(p string) bool {
+	entry, ok := a.entries[p]
+
+	return p == "" || ok && entry.dir
+}
+
+// attributes returns the attributes of the entry stored at p
+func (e *memoryEntry) attributes(p string) FileAttributes {
+	attributes := FileAttributes{
+		Path:         p,
+		Type:         TypeFile,
+		LastModified: e.lastModified,
+		Visibility:   e.visibility,
+	}
+
+	if e.dir {
+		attributes.Type = TypeDir
+
+		return attributes
+	}
+
+	attributes.Size = int64(len(e.contents))
+	attributes.MimeType = detectMimeType(p, e.contents)
+
+	return attributes
+}
+
+// normalizePath turns a path into a clean, slash separated path without leading or trailing slashes
+func normalizePath(p string) string {
+	return strings.TrimPrefix(path.Clean("/"+strings.ReplaceAll(p, "\\", "/")), "/")
+}
+
+// copyBytes returns a copy of b so callers can't modify stored contents
+func copyBytes(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+
+	return c
+}
@@ -0,0 +1,407 @@
+package adapter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestMemory_Write(t *testing.T) {
+	fs := NewMemory()
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	ok, err := fs.DirectoryExists("sub")
+	if err != nil || !ok {
+		t.Log("expected the parent directory to be created")
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt/fail.txt", []byte("hello"))
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("/", []byte("hello"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists for the root, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Put("", []byte("hello"))
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists for the root, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestMemory_Update(t *testing.T) {
+	fs := NewMemory()
+
+	err := fs.Update("test.txt", []byte("hello"))
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("test.txt", VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("test.txt")
+	if err != nil || attributes.Size != 5 || attributes.Visibility != VisibilityPrivate {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+
+	err = fs.Put("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+}
+
+func TestMemory_Stream(t *testing.T) {
+	fs := NewMemory()
+
+	err := fs.WriteStream("test.txt", strings.NewReader("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	stream, err := fs.ReadStream("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer stream.Close()
+
+	contents, err := io.ReadAll(stream)
+	if err != nil || string(contents) != "hello" {
+		t.Log("files does not contain: hello")
+		t.Fail()
+	}
+
+	_, err = fs.ReadStream("non-existing.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestMemory_Rename(t *testing.T) {
+	fs := NewMemory()
+
+	err := fs.Write("dir/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Rename("dir/test.txt", "dir/renamed.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("dir/renamed.txt"); !ok {
+		t.Log("expected dir/renamed.txt to exist")
+		t.Fail()
+	}
+
+	err = fs.Rename("dir", "moved")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("moved/renamed.txt"); !ok {
+		t.Log("expected the directory contents to be moved")
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("dir"); ok {
+		t.Log("expected dir to be gone")
+		t.Fail()
+	}
+
+	err = fs.Rename("moved", "moved/deeper")
+	if err == nil {
+		t.Log("expected an error: move into itself")
+		t.Fail()
+	}
+
+	err = fs.Rename("non-existing.txt", "test.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Rename("test.txt", "moved")
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists when renaming onto a directory, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("moved/renamed.txt"); !ok {
+		t.Log("expected the directory contents to be left alone")
+		t.Fail()
+	}
+
+	err = fs.Rename("test.txt", "/")
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists when renaming onto the root, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Copy("test.txt", "moved")
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists when copying onto a directory, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestMemory_Copy(t *testing.T) {
+	fs := NewMemory()
+
+	err := fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Copy("test.txt", "copy/test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("copy/test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Log("file contents are not equal")
+		t.Fail()
+	}
+
+	err = fs.Copy("non-existing.txt", "test2.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestMemory_Directories(t *testing.T) {
+	fs := NewMemory()
+
+	err := fs.CreateDir("sub/deeper")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("sub")
+	if !errors.Is(err, ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/deeper/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Delete("sub")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("sub/deeper/test.txt")
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("sub/deeper/test.txt"); !ok {
+		t.Log("expected the file to be left alone")
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("sub")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("sub/deeper/test.txt"); ok {
+		t.Log("expected the directory contents to be deleted")
+		t.Fail()
+	}
+}
+
+func TestMemory_Root(t *testing.T) {
+	fs := NewMemory()
+
+	err := fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if ok, _ := fs.Has(""); !ok {
+		t.Log("expected the root to exist")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("/")
+	if err != nil || !attributes.IsDir() {
+		t.Logf("expected the root to be a directory, got %v, %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("")
+	if !errors.Is(err, ErrPermissionDenied) {
+		t.Logf("expected ErrPermissionDenied, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("test.txt"); !ok {
+		t.Log("expected the contents of the root to be left alone")
+		t.Fail()
+	}
+}
+
+func TestMemory_ListContents(t *testing.T) {
+	fs := NewMemory()
+
+	for _, p := range []string{"a.txt", "sub/b.txt", "sub/deeper/c.txt"} {
+		err := fs.Write(p, []byte("hello world"))
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+	}
+
+	listing, err := fs.ListContents("", false)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	contents, err := Collect(listing)
+	if err != nil || len(contents) != 2 {
+		t.Logf("unexpected listing: %v", contents)
+		t.Fail()
+	}
+
+	listing, err = fs.ListContents("sub", true)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	contents, err = Collect(listing)
+	if err != nil || len(contents) != 3 || contents[0].Path != "sub/b.txt" || !contents[1].IsDir() {
+		t.Logf("unexpected listing: %v", contents)
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("a.txt", false)
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestMemory_Concurrent(t *testing.T) {
+	fs := NewMemory()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 50; i++ {
+		i := i
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			p := fmt.Sprintf("dir/%d.txt", i)
+
+			if err := fs.Write(p, []byte("hello")); err != nil {
+				t.Log(err)
+				t.Fail()
+			}
+
+			if _, err := fs.Read(p); err != nil {
+				t.Log(err)
+				t.Fail()
+			}
+
+			listing, err := fs.ListContents("dir", false)
+			if err == nil {
+				Collect(listing)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	listing, err := fs.ListContents("dir", false)
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	contents, _ := Collect(listing)
+	if len(contents) != 50 {
+		t.Logf("expected 50 entries, got %d", len(contents))
+		t.Fail()
+	}
+}
@@ -0,0 +1,203 @@
+package adapter
+
+import (
+	"context"
+	"io"
+)
+
+// readOnlyMethods implements the methods of Adapter that change something, they all fail with ErrReadOnly
+type readOnlyMethods struct{}
+
+// Write fails, the adapter is read-only
+func (readOnlyMethods) Write(path string, contents []byte) error {
+	return wrapError("write", path, ErrReadOnly)
+}
+
+// Update fails, the adapter is read-only
+func (readOnlyMethods) Update(path string, contents []byte) error {
+	return wrapError("update", path, ErrReadOnly)
+}
+
+// Put fails, the adapter is read-only
+func (readOnlyMethods) Put(path string, contents []byte) error {
+	return wrapError("put", path, ErrReadOnly)
+}
+
+// WriteStream fails, the adapter is read-only
+func (readOnlyMethods) WriteStream(path string, contents io.Reader) error {
+	return wrapError("write", path, ErrReadOnly)
+}
+
+// Rename fails, the adapter is read-only
+func (readOnlyMethods) Rename(path string, newPath string) error {
+	return wrapError("rename", path, ErrReadOnly)
+}
+
+// Copy fails, the adapter is read-only
+func (readOnlyMethods) Copy(path string, newPath string) error {
+	return wrapError("copy", path, ErrReadOnly)
+}
+
+// Delete fails, the adapter is read-only
+func (readOnlyMethods) Delete(path string) error {
+	return wrapError("delete", path, ErrReadOnly)
+}
+
+// CreateDir fails, the adapter is read-only
+func (readOnlyMethods) CreateDir(dir string) error {
+	return wrapDirError("mkdir", dir, ErrReadOnly)
+}
+
+// DeleteDir fails, the adapter is read-only
+func (readOnlyMethods) DeleteDir(dir string) error {
+	return wrapDirError("rmdir", dir, ErrReadOnly)
+}
+
+// SetVisibility fails, the adapter is read-only
+func (readOnlyMethods) SetVisibility(path string, visibility string) error {
+	return wrapError("chmod", path, ErrReadOnly)
+}
+
+// readOnly passes reads through to an adapter and rejects every change.
+// It has no Unwrap so the adapter it wraps can't be reached
+type readOnly struct {
+	readOnlyMethods
+	inner   Adapter
+	context ContextAdapter
+}
+
+// ReadOnly wraps an adapter so Read and the query methods pass through,
+// while Write, Update, Put, WriteStream, Rename, Copy, Delete, CreateDir, DeleteDir and SetVisibility fail with ErrReadOnly.
+// The result is a ContextAdapter that uses the context methods of inner when it has them, Close closes inner when it can be closed
+func ReadOnly(inner Adapter) Adapter {
+	return &readOnly{inner: inner, context: WithContext(inner)}
+}
+
+// Close closes the inner adapter, if it can be closed
+func (a *readOnly) Close() error {
+	if c, ok := a.inner.(io.Closer); ok {
+		return c.Close()
+	}
+
+	return nil
+}
+
+// Read a file
+func (a *readOnly) Read(path string) ([]byte, error) {
+	return a.inner.Read(path)
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *readOnly) ReadStream(path string) (io.ReadCloser, error) {
+	return a.inner.ReadStream(path)
+}
+
+// Has checks if a file or directory exists
+func (a *readOnly) Has(path string) (bool, error) {
+	return a.inner.Has(path)
+}
+
+// FileExists checks if a file exists
+func (a *readOnly) FileExists(path string) (bool, error) {
+	return a.inner.FileExists(path)
+}
+
+// DirectoryExists checks if a directory exists
+func (a *readOnly) DirectoryExists(dir string) (bool, error) {
+	return a.inner.DirectoryExists(dir)
+}
+
+// Stat returns the attributes of a file or directory
+func (a *readOnly) Stat(path string) (FileAttributes, error) {
+	return a.inner.Stat(path)
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories
+func (a *readOnly) ListContents(dir string, deep bool) (DirectoryListing, error) {
+	return a.inner.ListContents(dir, deep)
+}
+
+// WriteContext fails, the adapter is read-only
+func (a *readOnly) WriteContext(ctx context.Context, path string, contents []byte) error {
+	return a.Write(path, contents)
+}
+
+// UpdateContext fails, the adapter is read-only
+func (a *readOnly) UpdateContext(ctx context.Context, path string, contents []byte) error {
+	return a.Update(path, contents)
+}
+
+// PutContext fails, the adapter is read-only
+func (a *readOnly) PutContext(ctx context.Context, path string, contents []byte) error {
+	return a.Put(path, contents)
+}
+
+// WriteStreamContext fails, the adapter is read-only
+func (a *readOnly) WriteStreamContext(ctx context.Context, path string, contents io.Reader) error {
+	return a.WriteStream(path, contents)
+}
+
+// RenameContext fails, the adapter is read-only
+func (a *readOnly) RenameContext(ctx context.Context, path string, newPath string) error {
+	return a.Rename(path, newPath)
+}
+
+// CopyContext fails, the adapter is read-only
+func (a *readOnly) CopyContext(ctx context.Context, path string, newPath string) error {
+	return a.Copy(path, newPath)
+}
+
+// DeleteContext fails, the adapter is read-only
+func (a *readOnly) DeleteContext(ctx context.Context, path string) error {
+	return a.Delete(path)
+}
+
+// CreateDirContext fails, the adapter is read-only
+func (a *readOnly) CreateDirContext(ctx context.Context, dir string) error {
+	return a.CreateDir(dir)
+}
+
+// DeleteDirContext fails, the adapter is read-only
+func (a *readOnly) DeleteDirContext(ctx context.Context, dir string) error {
+	return a.DeleteDir(dir)
+}
+
+// SetVisibilityContext fails, the adapter is read-only
+func (a *readOnly) SetVisibilityContext(ctx context.Context, path string, visibility string) error {
+	return a.SetVisibility(path, visibility)
+}
+
+// ReadContext reads a file
+func (a *readOnly) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	return a.context.ReadContext(ctx, path)
+}
+
+// ReadStreamContext opens a file for reading, the caller must close it
+func (a *readOnly) ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	return a.context.ReadStreamContext(ctx, path)
+}
+
+// HasContext checks if a file or directory exists
+func (a *readOnly) HasContext(ctx context.Context, path string) (bool, error) {
+	return a.context.HasContext(ctx, path)
+}
+
+// FileExistsContext checks if a file exists
+func (a *readOnly) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	return a.context.FileExistsContext(ctx, path)
+}
+
+// DirectoryExistsContext checks if a directory exists
+func (a *readOnly) DirectoryExistsContext(ctx context.Context, dir string) (bool, error) {
+	return a.context.DirectoryExistsContext(ctx, dir)
+}
+
+// StatContext returns the attributes of a file or directory
+func (a *readOnly) StatContext(ctx context.Context, path string) (FileAttributes, error) {
+	return a.context.StatContext(ctx, path)
+}
+
+// ListContentsContext lists the contents of a directory, deep also lists the contents of subdirectories
+func (a *readOnly) ListContentsContext(ctx context.Context, dir string, deep bool) (DirectoryListing, error) {
+	return a.context.ListContentsContext(ctx, dir, deep)
+}
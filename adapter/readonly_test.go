@@ -0,0 +1,175 @@
+package adapter
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestReadOnly(t *testing.T) {
+	inner := NewMemory()
+
+	if err := inner.Write("dir/a.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := ReadOnly(inner)
+
+	contents, err := fs.Read("dir/a.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("files does not contain: hello, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("dir/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, _ = io.ReadAll(r)
+	r.Close()
+
+	if string(contents) != "hello" {
+		t.Logf("unexpected contents: %q", contents)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("dir/a.txt"); !ok {
+		t.Log("expected dir/a.txt to exist")
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir"); !ok {
+		t.Log("expected dir to exist")
+		t.Fail()
+	}
+
+	if attributes, err := fs.Stat("dir/a.txt"); err != nil || attributes.Size != 5 {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	listing, err := fs.ListContents("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if contents, _ := Collect(listing); len(contents) != 2 {
+		t.Logf("expected 2 entries, got %+v", contents)
+		t.Fail()
+	}
+
+	errs := []error{
+		fs.Write("new.txt", []byte("hello")),
+		fs.Update("dir/a.txt", []byte("hello")),
+		fs.Put("dir/a.txt", []byte("hello")),
+		fs.WriteStream("new.txt", strings.NewReader("hello")),
+		fs.Rename("dir/a.txt", "b.txt"),
+		fs.Copy("dir/a.txt", "b.txt"),
+		fs.Delete("dir/a.txt"),
+		fs.CreateDir("new"),
+		fs.DeleteDir("dir"),
+		fs.SetVisibility("dir/a.txt", VisibilityPrivate),
+	}
+
+	for i, err := range errs {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Logf("#%d: expected ErrReadOnly, got %v", i, err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := inner.Has("new.txt"); ok {
+		t.Log("expected nothing to be written")
+		t.Fail()
+	}
+
+	if _, ok := fs.(interface{ Unwrap() Adapter }); ok {
+		t.Log("expected the inner adapter to be unreachable")
+		t.Fail()
+	}
+}
+
+// closingContext records the context of ReadContext and whether it was closed
+type closingContext struct {
+	ContextAdapter
+	ctx    context.Context
+	closed bool
+}
+
+// ReadContext records ctx and reads the file
+func (a *closingContext) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	a.ctx = ctx
+
+	return a.ContextAdapter.ReadContext(ctx, path)
+}
+
+// Close records that the adapter was closed
+func (a *closingContext) Close() error {
+	a.closed = true
+
+	return nil
+}
+
+func TestReadOnly_Forwards(t *testing.T) {
+	inner := &closingContext{ContextAdapter: WithContext(NewMemory())}
+
+	if err := inner.Write("a.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	fs, ok := ReadOnly(inner).(ContextAdapter)
+	if !ok {
+		t.Fatal("expected a ContextAdapter")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	contents, err := fs.ReadContext(ctx, "a.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("files does not contain: hello, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	if inner.ctx != ctx {
+		t.Log("expected the context to reach the inner adapter")
+		t.Fail()
+	}
+
+	err = fs.WriteContext(ctx, "a.txt", []byte("hello"))
+	if !errors.Is(err, ErrReadOnly) {
+		t.Logf("expected ErrReadOnly, got %v", err)
+		t.Fail()
+	}
+
+	c, ok := fs.(io.Closer)
+	if !ok {
+		t.Fatal("expected an io.Closer")
+	}
+
+	if err := c.Close(); err != nil || !inner.closed {
+		t.Logf("expected the inner adapter to be closed, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestReadOnly_Cancelled(t *testing.T) {
+	fs := ReadOnly(NewMemory()).(ContextAdapter)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := fs.StatContext(ctx, "a.txt")
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	if err := fs.(io.Closer).Close(); err != nil {
+		t.Logf("expected closing an adapter without Close to succeed, got %v", err)
+		t.Fail()
+	}
+}
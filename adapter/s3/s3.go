@@ -0,0 +1,370 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/edwin-luijten/go_flysystem/internal/object"
+)
+
+var errNoBucket = errors.New("no bucket configured")
+
+// S3DefaultPartSize is the part size used when S3Config.PartSize is not set, it is the smallest part S3 accepts
+const S3DefaultPartSize = 5 * 1024 * 1024
+
+// allUsersURI is the grantee S3 uses for anonymous access
+const allUsersURI = "http://acs.amazonaws.com/groups/global/AllUsers"
+
+// s3DeleteBatchSize is the number of objects S3 deletes in one request
+const s3DeleteBatchSize = 1000
+
+// S3Config configures an S3 adapter
+type S3Config struct {
+	// Bucket is the bucket files are stored in, it must exist
+	Bucket string
+	// Prefix is prepended to every key, it allows several adapters to share a bucket
+	Prefix string
+	// Visibility is given to new files. Leaving it empty leaves ACLs alone:
+	// new files get the bucket's default ACL and Stat doesn't report a visibility,
+	// which suits buckets and S3 compatible stores without ACL support
+	Visibility string
+	// PartSize is the size of the parts of a multipart upload, smaller files are uploaded in a single request.
+	// S3 rejects parts smaller than 5 MiB, except for the last one
+	PartSize int64
+}
+
+// S3 stores files in an S3 compatible bucket.
+// Directories don't exist in S3, they are emulated with empty marker objects whose key ends with a slash
+// and by the keys of the files in them
+type S3 struct {
+	*object.Adapter
+}
+
+// NewS3 creates a new instance of S3, the client decides the endpoint, region and credentials
+func NewS3(client *s3.Client, config S3Config) (adapter.Adapter, error) {
+	if config.Bucket == "" {
+		return nil, errNoBucket
+	}
+
+	if config.Visibility != "" && cannedACL(config.Visibility) == "" {
+		return nil, object.ErrUnknownVisibility
+	}
+
+	partSize := config.PartSize
+	if partSize <= 0 {
+		partSize = S3DefaultPartSize
+	}
+
+	store := &s3Store{
+		client:   client,
+		bucket:   config.Bucket,
+		partSize: partSize,
+	}
+
+	return &S3{object.New(store, adapter.NormalizePath(config.Prefix), config.Visibility)}, nil
+}
+
+// s3Store is the object store of the S3 adapter
+type s3Store struct {
+	client   *s3.Client
+	bucket   string
+	partSize int64
+}
+
+// Head returns the attributes of an object
+func (s *s3Store) Head(ctx context.Context, key string) (object.Object, error) {
+	out, err := s.client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return object.Object{}, err
+	}
+
+	return object.Object{
+		Key:          key,
+		Size:         aws.ToInt64(out.ContentLength),
+		LastModified: aws.ToTime(out.LastModified),
+		MimeType:     aws.ToString(out.ContentType),
+	}, nil
+}
+
+// Get opens an object for reading
+func (s *s3Store) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	out, err := s.client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return out.Body, nil
+}
+
+// Put stores an object, contents larger than the part size are sent as a multipart upload
+func (s *s3Store) Put(ctx context.Context, key string, contents io.Reader, mimeType string, visibility string, exclusive bool) error {
+	var part bytes.Buffer
+
+	n, err := io.CopyN(&part, contents, s.partSize)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	var contentType *string
+	if mimeType != "" {
+		contentType = aws.String(mimeType)
+	}
+
+	var ifNoneMatch *string
+	if exclusive {
+		ifNoneMatch = aws.String("*")
+	}
+
+	if n < s.partSize {
+		_, err = s.client.PutObject(ctx, &s3.PutObjectInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(key),
+			Body:          bytes.NewReader(part.Bytes()),
+			ContentLength: aws.Int64(n),
+			ContentType:   contentType,
+			ACL:           cannedACL(visibility),
+			IfNoneMatch:   ifNoneMatch,
+		})
+
+		return err
+	}
+
+	created, err := s.client.CreateMultipartUpload(ctx, &s3.CreateMultipartUploadInput{
+		Bucket:      aws.String(s.bucket),
+		Key:         aws.String(key),
+		ContentType: contentType,
+		ACL:         cannedACL(visibility),
+	})
+	if err != nil {
+		return err
+	}
+
+	parts, err := s.uploadParts(ctx, key, created.UploadId, &part, contents)
+	if err == nil {
+		_, err = s.client.CompleteMultipartUpload(ctx, &s3.CompleteMultipartUploadInput{
+			Bucket:          aws.String(s.bucket),
+			Key:             aws.String(key),
+			UploadId:        created.UploadId,
+			MultipartUpload: &types.CompletedMultipartUpload{Parts: parts},
+			IfNoneMatch:     ifNoneMatch,
+		})
+	}
+
+	if err != nil {
+		// Parts of an upload that is never completed are stored, and billed, until it is aborted.
+		// ctx may be the reason the upload failed, so it isn't used for the abort
+		s.client.AbortMultipartUpload(context.Background(), &s3.AbortMultipartUploadInput{
+			Bucket:   aws.String(s.bucket),
+			Key:      aws.String(key),
+			UploadId: created.UploadId,
+		})
+	}
+
+	return err
+}
+
+// uploadParts uploads the buffered first part and the rest of contents, one part at a time
+func (s *s3Store) uploadParts(ctx context.Context, key string, uploadID *string, part *bytes.Buffer, contents io.Reader) ([]types.CompletedPart, error) {
+	var parts []types.CompletedPart
+
+	for number := int32(1); part.Len() > 0; number++ {
+		out, err := s.client.UploadPart(ctx, &s3.UploadPartInput{
+			Bucket:        aws.String(s.bucket),
+			Key:           aws.String(key),
+			UploadId:      uploadID,
+			PartNumber:    aws.Int32(number),
+			Body:          bytes.NewReader(part.Bytes()),
+			ContentLength: aws.Int64(int64(part.Len())),
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		parts = append(parts, types.CompletedPart{ETag: out.ETag, PartNumber: aws.Int32(number)})
+
+		part.Reset()
+
+		_, err = io.CopyN(part, contents, s.partSize)
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+	}
+
+	return parts, nil
+}
+
+// Copy copies an object on the server
+func (s *s3Store) Copy(ctx context.Context, source string, destination string, visibility string) error {
+	_, err := s.client.CopyObject(ctx, &s3.CopyObjectInput{
+		Bucket:     aws.String(s.bucket),
+		Key:        aws.String(destination),
+		CopySource: aws.String((&url.URL{Path: s.bucket + "/" + source}).EscapedPath()),
+		ACL:        cannedACL(visibility),
+	})
+
+	return err
+}
+
+// Delete deletes objects, a thousand per request
+func (s *s3Store) Delete(ctx context.Context, keys []string) error {
+	for len(keys) > 0 {
+		n := len(keys)
+		if n > s3DeleteBatchSize {
+			n = s3DeleteBatchSize
+		}
+
+		objects := make([]types.ObjectIdentifier, n)
+		for i, key := range keys[:n] {
+			objects[i] = types.ObjectIdentifier{Key: aws.String(key)}
+		}
+
+		out, err := s.client.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(s.bucket),
+			Delete: &types.Delete{Objects: objects, Quiet: aws.Bool(true)},
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(out.Errors) > 0 {
+			return fmt.Errorf("%s: %w", aws.ToString(out.Errors[0].Key), object.ErrIncompleteDeletion)
+		}
+
+		keys = keys[n:]
+	}
+
+	return nil
+}
+
+// List pages through the objects whose key starts with prefix
+func (s *s3Store) List(ctx context.Context, prefix string, delimited bool, limit int) object.Pages {
+	input := &s3.ListObjectsV2Input{
+		Bucket: aws.String(s.bucket),
+		Prefix: aws.String(prefix),
+	}
+
+	if delimited {
+		input.Delimiter = aws.String("/")
+	}
+
+	if limit > 0 {
+		input.MaxKeys = aws.Int32(int32(limit))
+	}
+
+	return &s3Pages{ctx: ctx, pages: s3.NewListObjectsV2Paginator(s.client, input)}
+}
+
+// Visibility reads the ACL of an object, anything readable by everyone is public
+func (s *s3Store) Visibility(ctx context.Context, key string) (string, error) {
+	out, err := s.client.GetObjectAcl(ctx, &s3.GetObjectAclInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+	})
+	if err != nil {
+		return "", err
+	}
+
+	for _, grant := range out.Grants {
+		if grant.Grantee == nil || aws.ToString(grant.Grantee.URI) != allUsersURI {
+			continue
+		}
+
+		if grant.Permission == types.PermissionRead || grant.Permission == types.PermissionFullControl {
+			return adapter.VisibilityPublic, nil
+		}
+	}
+
+	return adapter.VisibilityPrivate, nil
+}
+
+// SetVisibility replaces the ACL of an object
+func (s *s3Store) SetVisibility(ctx context.Context, key string, visibility string) error {
+	_, err := s.client.PutObjectAcl(ctx, &s3.PutObjectAclInput{
+		Bucket: aws.String(s.bucket),
+		Key:    aws.String(key),
+		ACL:    cannedACL(visibility),
+	})
+
+	return err
+}
+
+// Kind maps the error codes of S3 to the error kinds
+func (s *s3Store) Kind(err error) error {
+	var apiErr smithy.APIError
+	if !errors.As(err, &apiErr) {
+		return nil
+	}
+
+	switch apiErr.ErrorCode() {
+	case "NoSuchKey", "NotFound":
+		return adapter.ErrFileNotFound
+	case "PreconditionFailed":
+		return adapter.ErrFileExists
+	case "AccessDenied", "Forbidden":
+		return adapter.ErrPermissionDenied
+	}
+
+	return nil
+}
+
+// s3Pages pages through a listing of S3 objects
+type s3Pages struct {
+	ctx   context.Context
+	pages *s3.ListObjectsV2Paginator
+}
+
+// More reports whether there is another page
+func (p *s3Pages) More() bool {
+	return p.pages.HasMorePages()
+}
+
+// Next fetches the next page
+func (p *s3Pages) Next() (object.Page, error) {
+	out, err := p.pages.NextPage(p.ctx)
+	if err != nil {
+		return object.Page{}, err
+	}
+
+	var page object.Page
+
+	for _, prefix := range out.CommonPrefixes {
+		page.Prefixes = append(page.Prefixes, aws.ToString(prefix.Prefix))
+	}
+
+	for _, o := range out.Contents {
+		page.Objects = append(page.Objects, object.Object{
+			Key:          aws.ToString(o.Key),
+			Size:         aws.ToInt64(o.Size),
+			LastModified: aws.ToTime(o.LastModified),
+		})
+	}
+
+	return page, nil
+}
+
+// cannedACL maps a visibility to an ACL
+func cannedACL(visibility string) types.ObjectCannedACL {
+	switch visibility {
+	case adapter.VisibilityPublic:
+		return types.ObjectCannedACLPublicRead
+	case adapter.VisibilityPrivate:
+		return types.ObjectCannedACLPrivate
+	}
+
+	return ""
+}
@@ -0,0 +1,465 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/edwin-luijten/go_flysystem/internal/object"
+	"github.com/johannesboyne/gofakes3"
+	"github.com/johannesboyne/gofakes3/backend/s3mem"
+)
+
+// aclServer puts the object ACL subresource, which gofakes3 lacks, in front of a fake S3
+type aclServer struct {
+	backend *s3mem.Backend
+	handler http.Handler
+	lock    sync.Mutex
+	public  map[string]bool
+	uploads int
+}
+
+func (s *aclServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	parts := strings.SplitN(strings.TrimPrefix(r.URL.Path, "/"), "/", 2)
+	if len(parts) < 2 {
+		s.handler.ServeHTTP(w, r)
+
+		return
+	}
+
+	bucket, key := parts[0], parts[1]
+	query := r.URL.Query()
+	public := r.Header.Get("X-Amz-Acl") == "public-read"
+
+	if _, ok := query["acl"]; !ok {
+		_, multipart := query["uploads"]
+
+		s.lock.Lock()
+		if (r.Method == http.MethodPut && query.Get("partNumber") == "") || multipart {
+			s.public[key] = public
+		}
+		if multipart {
+			s.uploads++
+		}
+		s.lock.Unlock()
+
+		s.handler.ServeHTTP(w, r)
+
+		return
+	}
+
+	if _, err := s.backend.HeadObject(bucket, key); err != nil {
+		w.WriteHeader(http.StatusNotFound)
+		fmt.Fprint(w, `<Error><Code>NoSuchKey</Code><Message>The specified key does not exist.</Message></Error>`)
+
+		return
+	}
+
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	if r.Method == http.MethodPut {
+		s.public[key] = public
+
+		return
+	}
+
+	grants := ""
+	if s.public[key] {
+		grants = `<Grant><Grantee xmlns:xsi="http://www.w3.org/2001/XMLSchema-instance" xsi:type="Group"><URI>` + allUsersURI + `</URI></Grantee><Permission>READ</Permission></Grant>`
+	}
+
+	fmt.Fprint(w, `<AccessControlPolicy><Owner><ID>owner</ID></Owner><AccessControlList>`+grants+`</AccessControlList></AccessControlPolicy>`)
+}
+
+func newS3(t *testing.T, config S3Config) (adapter.Adapter, *aclServer) {
+	backend := s3mem.New()
+	if err := backend.CreateBucket("test"); err != nil {
+		t.Fatal(err)
+	}
+
+	acl := &aclServer{
+		backend: backend,
+		handler: gofakes3.New(backend).Server(),
+		public:  map[string]bool{},
+	}
+
+	server := httptest.NewServer(acl)
+	t.Cleanup(server.Close)
+
+	client := s3.New(s3.Options{
+		BaseEndpoint: aws.String(server.URL),
+		UsePathStyle: true,
+		Region:       "us-east-1",
+		Credentials:  credentials.NewStaticCredentialsProvider("key", "secret", ""),
+	})
+
+	config.Bucket = "test"
+
+	fs, err := NewS3(client, config)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs, acl
+}
+
+func TestS3_Write(t *testing.T) {
+	fs, _ := newS3(t, S3Config{Visibility: adapter.VisibilityPublic})
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != adapter.VisibilityPublic || !strings.HasPrefix(attributes.MimeType, "text/plain") {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestS3_Update(t *testing.T) {
+	fs, _ := newS3(t, S3Config{Visibility: adapter.VisibilityPublic})
+
+	err := fs.Update("test.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("test.txt")
+	if err != nil || attributes.Size != 5 || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+
+	err = fs.Put("new.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("new.txt")
+	if err != nil || attributes.Visibility != adapter.VisibilityPublic {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+}
+
+func TestS3_Stream(t *testing.T) {
+	fs, server := newS3(t, S3Config{PartSize: 1024})
+
+	contents := bytes.Repeat([]byte("0123456789"), 500)
+
+	err := fs.WriteStream("stream.bin", bytes.NewReader(contents))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if server.uploads != 1 {
+		t.Logf("expected a multipart upload, got %d", server.uploads)
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("stream.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	read, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(read, contents) {
+		t.Log("stream contents don't match")
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream.bin", bytes.NewReader(contents))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("stream.bin")
+	if err != nil || attributes.Size != int64(len(contents)) || attributes.Visibility != "" {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+}
+
+func TestS3_Rename(t *testing.T) {
+	fs, _ := newS3(t, S3Config{Visibility: adapter.VisibilityPublic})
+
+	for _, p := range []string{"dir/a.txt", "dir/sub/b.txt", "file.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := fs.Rename("file.txt", "renamed.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("file.txt"); ok {
+		t.Log("expected file.txt to be gone")
+		t.Fail()
+	}
+
+	err = fs.Rename("dir", "moved")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("moved/sub/b.txt")
+	if err != nil || string(contents) != "dir/sub/b.txt" {
+		t.Logf("expected the directory contents to move, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir"); ok {
+		t.Log("expected dir to be gone")
+		t.Fail()
+	}
+
+	err = fs.Rename("moved", "moved/inside")
+	if !errors.Is(err, object.ErrMoveIntoItself) {
+		t.Logf("expected object.ErrMoveIntoItself, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Rename("missing.txt", "other.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestS3_Copy(t *testing.T) {
+	fs, _ := newS3(t, S3Config{Visibility: adapter.VisibilityPublic})
+
+	err := fs.Write("test file.txt", []byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.SetVisibility("test file.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Copy("test file.txt", "sub/copy.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/copy.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("copy does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/copy.txt")
+	if err != nil || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("expected the copy to keep its visibility, got %+v", attributes)
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "copy.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestS3_Directories(t *testing.T) {
+	fs, _ := newS3(t, S3Config{})
+
+	err := fs.CreateDir("empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("empty")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("implicit/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, dir := range []string{"empty", "implicit"} {
+		attributes, err := fs.Stat(dir)
+		if err != nil || !attributes.IsDir() {
+			t.Logf("expected %s to be a directory, got %+v %v", dir, attributes, err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.FileExists("implicit"); ok {
+		t.Log("expected a directory not to be a file")
+		t.Fail()
+	}
+
+	err = fs.Delete("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	for _, dir := range []string{"", "/"} {
+		err = fs.DeleteDir(dir)
+		if !errors.Is(err, adapter.ErrPermissionDenied) {
+			t.Logf("expected ErrPermissionDenied when deleting the root, got %v", err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.FileExists("implicit/test.txt"); !ok {
+		t.Log("expected the root to be left alone")
+		t.Fail()
+	}
+
+	for _, dir := range []string{"empty", "implicit"} {
+		err = fs.DeleteDir(dir)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		if ok, _ := fs.Has(dir); ok {
+			t.Logf("expected %s to be deleted", dir)
+			t.Fail()
+		}
+	}
+}
+
+func TestS3_ListContents(t *testing.T) {
+	fs, _ := newS3(t, S3Config{Prefix: "root"})
+
+	if err := fs.CreateDir("dir/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir"}},
+		{"dir", false, []string{"dir/b.txt", "dir/empty", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "dir/b.txt", "dir/empty", "dir/sub", "dir/sub/c.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := adapter.Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err := fs.ListContents("missing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestS3_Context(t *testing.T) {
+	fs, _ := newS3(t, S3Config{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := adapter.WithContext(fs).WriteContext(ctx, "test.txt", []byte("hello"))
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("test.txt"); ok {
+		t.Log("expected the write to be cancelled")
+		t.Fail()
+	}
+}
@@ -0,0 +1,18 @@
+module github.com/edwin-luijten/go_flysystem/adapter/sftp
+
+go 1.24.0
+
+require (
+	github.com/edwin-luijten/go_flysystem v0.1.0
+	github.com/pkg/sftp v1.13.10
+	golang.org/x/crypto v0.45.0
+)
+
+require (
+	github.com/klauspost/compress v1.15.9 // indirect
+	github.com/kr/fs v0.1.0 // indirect
+	golang.org/x/sys v0.38.0 // indirect
+)
+
+// The adapter is developed against the core next to it, users of the module get the required version
+replace github.com/edwin-luijten/go_flysystem => ../..
@@ -0,0 +1,623 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpPerms are the permissions of public and private files and directories, the same as Local uses
+var sftpPerms = map[string]map[string]os.FileMode{
+	"file": {adapter.VisibilityPublic: adapter.FilePublic, adapter.VisibilityPrivate: adapter.FilePrivate},
+	"dir":  {adapter.VisibilityPublic: adapter.DirPublic, adapter.VisibilityPrivate: adapter.DirPrivate},
+}
+
+var (
+	errNoAuth    = errors.New("no password or private key configured")
+	errNoHostKey = errors.New("no host key configured, refusing to connect to an unverified host")
+)
+
+// posixRename is the OpenSSH extension that renames over an existing file
+const posixRename = "posix-rename@openssh.com"
+
+// SFTPConfig configures an SFTP adapter
+type SFTPConfig struct {
+	// Address is the host and port of the server
+	Address string
+	// User to log in as
+	User string
+	// Password authenticates with a password, it can be combined with a private key
+	Password string
+	// PrivateKey is a PEM encoded private key
+	PrivateKey []byte
+	// Passphrase decrypts the private key, if it is encrypted
+	Passphrase string
+	// HostKey is the public key the server must present
+	HostKey ssh.PublicKey
+	// HostKeyCallback verifies the server instead of HostKey, for example with knownhosts.New
+	HostKeyCallback ssh.HostKeyCallback
+	// Root is the directory on the server the paths are relative to, it is created when missing
+	Root string
+	// Timeout limits how long connecting may take
+	Timeout time.Duration
+}
+
+// SFTP stores files on an SFTP server.
+// A single connection is shared by all operations, it is reopened on the next operation after it was lost
+type SFTP struct {
+	adapter.BaseAdapter
+	root    string
+	address string
+	config  *ssh.ClientConfig
+	lock    *sync.Mutex
+	conn    *ssh.Client
+	client  *sftp.Client
+}
+
+// NewSFTP creates a new instance of SFTP, it connects to the server to create the root directory
+func NewSFTP(config SFTPConfig) (adapter.Adapter, error) {
+	clientConfig, err := newSSHConfig(config)
+	if err != nil {
+		return nil, &adapter.Error{Op: "connect", Path: config.Address, Err: err}
+	}
+
+	root := config.Root
+	if root == "" {
+		root = "."
+	}
+
+	a := &SFTP{
+		root:    root,
+		address: config.Address,
+		config:  clientConfig,
+		lock:    &sync.Mutex{},
+	}
+
+	a.SetPathSeparator("/")
+	a.SetPathPrefix(strings.TrimSuffix(root, "/"))
+
+	c, err := a.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.MkdirAll(root)
+	if err != nil {
+		a.Close()
+
+		return nil, &adapter.Error{Op: "mkdir", Path: root, Kind: adapter.ErrUnableToCreateRoot, Err: err}
+	}
+
+	return a, nil
+}
+
+// newSSHConfig turns the configuration into an SSH client configuration
+func newSSHConfig(config SFTPConfig) (*ssh.ClientConfig, error) {
+	var auth []ssh.AuthMethod
+
+	if len(config.PrivateKey) > 0 {
+		var signer ssh.Signer
+		var err error
+
+		if config.Passphrase != "" {
+			signer, err = ssh.ParsePrivateKeyWithPassphrase(config.PrivateKey, []byte(config.Passphrase))
+		} else {
+			signer, err = ssh.ParsePrivateKey(config.PrivateKey)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		auth = append(auth, ssh.PublicKeys(signer))
+	}
+
+	if config.Password != "" {
+		auth = append(auth, ssh.Password(config.Password))
+	}
+
+	if len(auth) == 0 {
+		return nil, errNoAuth
+	}
+
+	hostKeyCallback := config.HostKeyCallback
+	if hostKeyCallback == nil && config.HostKey != nil {
+		hostKeyCallback = ssh.FixedHostKey(config.HostKey)
+	}
+
+	if hostKeyCallback == nil {
+		return nil, errNoHostKey
+	}
+
+	return &ssh.ClientConfig{
+		User:            config.User,
+		Auth:            auth,
+		HostKeyCallback: hostKeyCallback,
+		Timeout:         config.Timeout,
+	}, nil
+}
+
+// Close closes the connection to the server
+func (a *SFTP) Close() error {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	if a.client == nil {
+		return nil
+	}
+
+	a.client.Close()
+	err := a.conn.Close()
+
+	a.client = nil
+	a.conn = nil
+
+	return err
+}
+
+// Write a new file, it fails if the file already exists
+func (a *SFTP) Write(path string, contents []byte) error {
+	return a.WriteStream(path, bytes.NewReader(contents))
+}
+
+// Update a file, it fails if the file does not exist
+func (a *SFTP) Update(path string, contents []byte) error {
+	return a.write("update", path, bytes.NewReader(contents), os.O_TRUNC)
+}
+
+// Put writes a file, creating or overwriting it
+func (a *SFTP) Put(path string, contents []byte) error {
+	return a.write("put", path, bytes.NewReader(contents), os.O_CREATE|os.O_TRUNC)
+}
+
+// Read a file
+func (a *SFTP) Read(path string) ([]byte, error) {
+	f, err := a.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	contents, err := io.ReadAll(f)
+	if err != nil {
+		return nil, adapter.WrapError("read", path, err)
+	}
+
+	return contents, nil
+}
+
+// WriteStream writes a new file from a stream, it fails if the file already exists
+func (a *SFTP) WriteStream(path string, contents io.Reader) error {
+	return a.write("write", path, contents, os.O_CREATE|os.O_EXCL)
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *SFTP) ReadStream(path string) (io.ReadCloser, error) {
+	c, err := a.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := c.Open(a.location(path))
+	if err != nil {
+		return nil, adapter.WrapError("read", path, err)
+	}
+
+	return f, nil
+}
+
+// Rename a file or directory, an existing file is replaced if the server supports it
+func (a *SFTP) Rename(path string, newPath string) error {
+	c, err := a.connection()
+	if err != nil {
+		return err
+	}
+
+	location := a.location(path)
+	destination := a.location(newPath)
+
+	return adapter.WrapError("rename", path, rename(c, location, destination))
+}
+
+// Copy a file, SFTP can't copy on the server so the file is downloaded and uploaded again
+func (a *SFTP) Copy(path string, newPath string) error {
+	c, err := a.connection()
+	if err != nil {
+		return err
+	}
+
+	source, err := c.Open(a.location(path))
+	if err != nil {
+		return adapter.WrapError("copy", path, err)
+	}
+
+	defer source.Close()
+
+	info, err := source.Stat()
+	if err != nil {
+		return adapter.WrapError("copy", path, err)
+	}
+
+	err = a.write("copy", newPath, source, os.O_CREATE|os.O_TRUNC)
+	if err != nil {
+		return err
+	}
+
+	return adapter.WrapError("copy", newPath, c.Chmod(a.location(newPath), info.Mode().Perm()))
+}
+
+// Delete a file
+func (a *SFTP) Delete(path string) error {
+	c, err := a.connection()
+	if err != nil {
+		return err
+	}
+
+	return adapter.WrapError("delete", path, c.Remove(a.location(path)))
+}
+
+// CreateDir creates a directory
+func (a *SFTP) CreateDir(dir string) error {
+	ok, err := a.Has(dir)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return adapter.WrapDirError("mkdir", dir, adapter.ErrFileExists)
+	}
+
+	c, err := a.connection()
+	if err != nil {
+		return err
+	}
+
+	location := a.location(dir)
+
+	err = c.Mkdir(location)
+	if err != nil {
+		return adapter.WrapDirError("mkdir", dir, err)
+	}
+
+	return adapter.WrapDirError("mkdir", dir, c.Chmod(location, adapter.DirPublic))
+}
+
+// DeleteDir deletes a directory and its contents
+func (a *SFTP) DeleteDir(dir string) error {
+	c, err := a.connection()
+	if err != nil {
+		return err
+	}
+
+	err = c.RemoveAll(a.location(dir))
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return adapter.WrapDirError("rmdir", dir, err)
+}
+
+// SetVisibility sets a file or directory to public or private
+func (a *SFTP) SetVisibility(path string, visibility string) error {
+	c, err := a.connection()
+	if err != nil {
+		return err
+	}
+
+	location := a.location(path)
+
+	info, err := c.Stat(location)
+	if err != nil {
+		return adapter.WrapError("chmod", path, err)
+	}
+
+	var perm os.FileMode
+
+	if info.IsDir() {
+		perm = sftpPerms["dir"][visibility]
+	} else {
+		perm = sftpPerms["file"][visibility]
+	}
+
+	return adapter.WrapError("chmod", path, c.Chmod(location, perm))
+}
+
+// Has checks if a file or directory exists
+func (a *SFTP) Has(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil, err
+}
+
+// FileExists checks if a file exists
+func (a *SFTP) FileExists(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil && !info.IsDir(), err
+}
+
+// DirectoryExists checks if a directory exists
+func (a *SFTP) DirectoryExists(dir string) (bool, error) {
+	info, err := a.stat("stat", dir)
+
+	return info != nil && info.IsDir(), err
+}
+
+// Stat returns the attributes of a file or directory
+func (a *SFTP) Stat(path string) (adapter.FileAttributes, error) {
+	info, err := a.stat("stat", path)
+	if err != nil {
+		return adapter.FileAttributes{}, err
+	}
+
+	if info == nil {
+		return adapter.FileAttributes{}, adapter.WrapError("stat", path, adapter.ErrFileNotFound)
+	}
+
+	return a.attributes(path, info), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// Directories are read from the server one at a time while iterating
+func (a *SFTP) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	l, err := adapter.NewReadDirListing(adapter.NormalizePath(dir), deep, a.readDir, a.attributes)
+	if err != nil {
+		return nil, adapter.WrapDirError("list", dir, err)
+	}
+
+	return l, nil
+}
+
+// connection returns the shared client, connecting when there is none
+func (a *SFTP) connection() (*sftp.Client, error) {
+	a.lock.Lock()
+
+	defer a.lock.Unlock()
+
+	if a.client != nil {
+		return a.client, nil
+	}
+
+	conn, err := ssh.Dial("tcp", a.address, a.config)
+	if err != nil {
+		return nil, sshError(a.address, err)
+	}
+
+	client, err := sftp.NewClient(conn)
+	if err != nil {
+		conn.Close()
+
+		return nil, &adapter.Error{Op: "connect", Path: a.address, Err: err}
+	}
+
+	a.conn = conn
+	a.client = client
+
+	// Forget the connection once it is lost, so the next operation reconnects
+	go func() {
+		conn.Wait()
+
+		a.lock.Lock()
+
+		defer a.lock.Unlock()
+
+		if a.conn == conn {
+			client.Close()
+
+			a.conn = nil
+			a.client = nil
+		}
+	}()
+
+	return client, nil
+}
+
+// readDir reads the entries of a directory
+func (a *SFTP) readDir(dir string) ([]os.FileInfo, error) {
+	c, err := a.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	return c.ReadDir(a.location(dir))
+}
+
+// stat returns the file info of path, or nil if it does not exist
+func (a *SFTP) stat(op string, path string) (os.FileInfo, error) {
+	c, err := a.connection()
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := c.Stat(a.location(path))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, adapter.WrapError(op, path, err)
+	}
+
+	return info, nil
+}
+
+func (a *SFTP) attributes(p string, info os.FileInfo) adapter.FileAttributes {
+	return adapter.InfoAttributes(adapter.NormalizePath(p), info, func() []byte {
+		return a.head(p)
+	})
+}
+
+// write copies contents into the file opened with flag, creating its directory if flag allows creating the file.
+// An existing file is replaced by writing a temporary file next to it and renaming it into place,
+// so a failed write leaves the existing file untouched
+func (a *SFTP) write(op string, p string, contents io.Reader, flag int) error {
+	c, err := a.connection()
+	if err != nil {
+		return err
+	}
+
+	location := a.location(p)
+
+	if flag&os.O_CREATE != 0 {
+		err = c.MkdirAll(path.Dir(location))
+		if err != nil {
+			return adapter.WrapDirError(op, p, err)
+		}
+	}
+
+	if flag&os.O_TRUNC == 0 {
+		return adapter.WrapError(op, p, create(c, location, contents))
+	}
+
+	info, err := c.Stat(location)
+	if err != nil && (!os.IsNotExist(err) || flag&os.O_CREATE == 0) {
+		return adapter.WrapError(op, p, err)
+	}
+
+	tmp, err := tempPath(location)
+	if err != nil {
+		return adapter.WrapError(op, p, err)
+	}
+
+	err = create(c, tmp, contents)
+	if err != nil {
+		return adapter.WrapError(op, p, err)
+	}
+
+	if info != nil {
+		err = c.Chmod(tmp, info.Mode().Perm())
+	}
+
+	if err == nil {
+		err = replace(c, tmp, location)
+	}
+
+	if err != nil {
+		c.Remove(tmp)
+
+		return adapter.WrapError(op, p, err)
+	}
+
+	return nil
+}
+
+// location returns the path on the server, remote paths always use forward slashes
+func (a *SFTP) location(p string) string {
+	return path.Clean(a.ApplyPathPrefix(adapter.NormalizePath(p)))
+}
+
+// head reads the first bytes of a file for mime type detection
+func (a *SFTP) head(path string) []byte {
+	f, err := a.ReadStream(path)
+	if err != nil {
+		return nil
+	}
+
+	defer f.Close()
+
+	buf := make([]byte, 512)
+	n, _ := io.ReadFull(f, buf)
+
+	return buf[:n]
+}
+
+// create writes contents to a new file, a partially written file is removed.
+// SFTP servers don't report which error made an exclusive open fail, an existing file is reported as ErrFileExists
+func create(c *sftp.Client, location string, contents io.Reader) error {
+	f, err := c.OpenFile(location, os.O_WRONLY|os.O_CREATE|os.O_EXCL)
+	if err != nil {
+		if _, statErr := c.Lstat(location); statErr == nil {
+			return adapter.ErrFileExists
+		}
+
+		return err
+	}
+
+	_, err = f.ReadFrom(contents)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		c.Remove(location)
+	}
+
+	return err
+}
+
+// rename moves location to destination, replacing an existing file if the server supports it
+func rename(c *sftp.Client, location string, destination string) error {
+	if _, ok := c.HasExtension(posixRename); ok {
+		return c.PosixRename(location, destination)
+	}
+
+	return c.Rename(location, destination)
+}
+
+// replace moves tmp over location. Without posix-rename the existing file is moved aside first,
+// and moved back if tmp can't take its place
+func replace(c *sftp.Client, tmp string, location string) error {
+	if _, ok := c.HasExtension(posixRename); ok {
+		return c.PosixRename(tmp, location)
+	}
+
+	backup, err := tempPath(location)
+	if err != nil {
+		return err
+	}
+
+	err = c.Rename(location, backup)
+	if os.IsNotExist(err) {
+		return c.Rename(tmp, location)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = c.Rename(tmp, location)
+	if err != nil {
+		c.Rename(backup, location)
+
+		return err
+	}
+
+	return c.Remove(backup)
+}
+
+// tempPath returns a path for a temporary file next to location
+func tempPath(location string) (string, error) {
+	suffix := make([]byte, 8)
+
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(path.Dir(location), fmt.Sprintf(".%s.%x.tmp", path.Base(location), suffix)), nil
+}
+
+// sshError wraps an error that occurred while connecting
+func sshError(address string, err error) error {
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return &adapter.Error{Op: "connect", Path: address, Err: err}
+	}
+
+	// Authentication and host key failures
+	return &adapter.Error{Op: "connect", Path: address, Kind: adapter.ErrPermissionDenied, Err: err}
+}
@@ -0,0 +1,504 @@
+package sftp
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpServer is an in-process SSH server that only offers the sftp subsystem
+type sftpServer struct {
+	listener    net.Listener
+	config      *ssh.ServerConfig
+	root        string
+	hostKey     ssh.PublicKey
+	userKey     []byte
+	lock        sync.Mutex
+	conns       []*ssh.ServerConn
+	connections int
+}
+
+func newSFTPServer(t *testing.T) *sftpServer {
+	_, hostPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hostSigner, err := ssh.NewSignerFromKey(hostPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, userPrivate, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	block, err := ssh.MarshalPrivateKey(userPrivate, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	userSigner, err := ssh.NewSignerFromKey(userPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s := &sftpServer{
+		root:    t.TempDir(),
+		hostKey: hostSigner.PublicKey(),
+		userKey: pem.EncodeToMemory(block),
+	}
+
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == "test" && string(password) == "secret" {
+				return nil, nil
+			}
+
+			return nil, errors.New("wrong password")
+		},
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "test" && bytes.Equal(key.Marshal(), userSigner.PublicKey().Marshal()) {
+				return nil, nil
+			}
+
+			return nil, errors.New("unknown key")
+		},
+	}
+	s.config.AddHostKey(hostSigner)
+
+	s.listener, err = net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		s.listener.Close()
+		s.disconnect()
+	})
+
+	go s.serve()
+
+	return s
+}
+
+func (s *sftpServer) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+
+		go s.handle(conn)
+	}
+}
+
+func (s *sftpServer) handle(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+
+		return
+	}
+
+	s.lock.Lock()
+	s.conns = append(s.conns, serverConn)
+	s.connections++
+	s.lock.Unlock()
+
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+
+			continue
+		}
+
+		channel, requests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+
+		go func() {
+			for req := range requests {
+				ok := req.Type == "subsystem" && len(req.Payload) > 4 && string(req.Payload[4:]) == "sftp"
+				req.Reply(ok, nil)
+
+				if !ok {
+					continue
+				}
+
+				server, err := sftp.NewServer(channel, sftp.WithServerWorkingDirectory(s.root))
+				if err != nil {
+					channel.Close()
+
+					return
+				}
+
+				server.Serve()
+				channel.Close()
+			}
+		}()
+	}
+}
+
+// disconnect drops every connection to the server
+func (s *sftpServer) disconnect() {
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+
+	s.conns = nil
+}
+
+func (s *sftpServer) count() int {
+	s.lock.Lock()
+
+	defer s.lock.Unlock()
+
+	return s.connections
+}
+
+func newSFTP(t *testing.T) (adapter.Adapter, *sftpServer) {
+	server := newSFTPServer(t)
+
+	fs, err := NewSFTP(SFTPConfig{
+		Address:  server.listener.Addr().String(),
+		User:     "test",
+		Password: "secret",
+		HostKey:  server.hostKey,
+		Root:     "data",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		fs.(*SFTP).Close()
+	})
+
+	return fs, server
+}
+
+func TestSFTP_Auth(t *testing.T) {
+	server := newSFTPServer(t)
+
+	fs, err := NewSFTP(SFTPConfig{
+		Address:    server.listener.Addr().String(),
+		User:       "test",
+		PrivateKey: server.userKey,
+		HostKey:    server.hostKey,
+	})
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	} else {
+		fs.(*SFTP).Close()
+	}
+
+	_, err = NewSFTP(SFTPConfig{
+		Address:  server.listener.Addr().String(),
+		User:     "test",
+		Password: "wrong",
+		HostKey:  server.hostKey,
+	})
+	if !errors.Is(err, adapter.ErrPermissionDenied) {
+		t.Logf("expected ErrPermissionDenied, got %v", err)
+		t.Fail()
+	}
+
+	_, err = NewSFTP(SFTPConfig{
+		Address:  server.listener.Addr().String(),
+		User:     "test",
+		Password: "secret",
+	})
+	if !errors.Is(err, errNoHostKey) {
+		t.Logf("expected errNoHostKey, got %v", err)
+		t.Fail()
+	}
+
+	otherKey, _, _ := ed25519.GenerateKey(rand.Reader)
+	otherPublicKey, _ := ssh.NewPublicKey(otherKey)
+
+	_, err = NewSFTP(SFTPConfig{
+		Address:  server.listener.Addr().String(),
+		User:     "test",
+		Password: "secret",
+		HostKey:  otherPublicKey,
+	})
+	if err == nil || !strings.Contains(err.Error(), "host key mismatch") {
+		t.Logf("expected a host key mismatch, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSFTP_Write(t *testing.T) {
+	fs, server := newSFTP(t)
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := os.ReadFile(filepath.Join(server.root, "data", "sub", "test.txt"))
+	if err != nil || string(contents) != "hello world" {
+		t.Log("expected the file to be written below the root")
+		t.Fail()
+	}
+
+	contents, err = fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("missing.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("sub/test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("sub/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if attributes, _ := fs.Stat("sub/test.txt"); attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("expected the update to keep the visibility, got %s", attributes.Visibility)
+		t.Fail()
+	}
+
+	entries, err := os.ReadDir(filepath.Join(server.root, "data", "sub"))
+	if err != nil || len(entries) != 1 {
+		t.Logf("expected no temporary files to be left behind, got %v %v", entries, err)
+		t.Fail()
+	}
+
+	err = fs.Put("sub/test.txt", []byte("hello put"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 9 || attributes.Path != "sub/test.txt" || !attributes.IsFile() {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSFTP_Copy(t *testing.T) {
+	fs, _ := newSFTP(t)
+
+	err := fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.SetVisibility("test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Copy("test.txt", "sub/copy.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/copy.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Rename("sub/copy.txt", "test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("sub/copy.txt"); ok {
+		t.Log("expected sub/copy.txt to be gone")
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "copy.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSFTP_Visibility(t *testing.T) {
+	fs, server := newSFTP(t)
+
+	err := fs.CreateDir("dir")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path       string
+		visibility string
+		perm       os.FileMode
+	}{
+		{"dir", adapter.VisibilityPrivate, adapter.DirPrivate},
+		{"dir", adapter.VisibilityPublic, adapter.DirPublic},
+		{"dir/test.txt", adapter.VisibilityPrivate, adapter.FilePrivate},
+		{"dir/test.txt", adapter.VisibilityPublic, adapter.FilePublic},
+	}
+
+	err = fs.Write("dir/test.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, test := range tests {
+		err := fs.SetVisibility(test.path, test.visibility)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		info, err := os.Stat(filepath.Join(server.root, "data", test.path))
+		if err != nil || info.Mode().Perm() != test.perm {
+			t.Logf("expected %s to have permissions %o", test.path, test.perm)
+			t.Fail()
+		}
+
+		attributes, err := fs.Stat(test.path)
+		if err != nil || attributes.Visibility != test.visibility {
+			t.Logf("expected %s to be %s, got %+v", test.path, test.visibility, attributes)
+			t.Fail()
+		}
+	}
+}
+
+func TestSFTP_Directories(t *testing.T) {
+	fs, _ := newSFTP(t)
+
+	err := fs.CreateDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("dir")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"dir/a.txt", "dir/sub/b.txt", "c.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listing, err := fs.ListContents("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := adapter.Collect(listing)
+	if err != nil || len(contents) != 5 {
+		t.Logf("expected 5 entries, got %+v %v", contents, err)
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("missing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir"); ok {
+		t.Log("expected dir to be deleted")
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Logf("expected deleting a missing directory to succeed, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSFTP_Reconnect(t *testing.T) {
+	fs, server := newSFTP(t)
+
+	for i := 0; i < 5; i++ {
+		if _, err := fs.Has("test.txt"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if server.count() != 1 {
+		t.Logf("expected the connection to be reused, got %d connections", server.count())
+		t.Fail()
+	}
+
+	server.disconnect()
+
+	// The first operation may still see the connection that is going away
+	var err error
+	for i := 0; i < 10; i++ {
+		if err = fs.Write("test.txt", []byte("hello")); err == nil {
+			break
+		}
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if err != nil || server.count() != 2 {
+		t.Logf("expected to reconnect, got %d connections: %v", server.count(), err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,760 @@
+package sql
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+)
+
+var (
+	errInvalidTable      = errors.New("table name may only contain letters, digits and underscores")
+	errUnknownVisibility = errors.New("unknown visibility")
+	errMoveIntoItself    = errors.New("can't move a directory into itself")
+)
+
+// sqlTableName matches a table name, optionally qualified with a schema
+var sqlTableName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// sqlSchema creates the table when it does not exist yet.
+// BLOB holds 64 KiB in MySQL, create the table with a LONGBLOB yourself for larger files
+const sqlSchema = `CREATE TABLE IF NOT EXISTS $table (
+	path VARCHAR(767) NOT NULL PRIMARY KEY,
+	dir BOOLEAN NOT NULL,
+	contents BLOB,
+	visibility VARCHAR(16) NOT NULL,
+	created_at TIMESTAMP NOT NULL,
+	updated_at TIMESTAMP NOT NULL
+)`
+
+// SQL stores files as rows of a database table, with ? placeholders as used by SQLite and MySQL.
+// Directories are either explicit rows created by CreateDir or implied by the paths of the files in them.
+// Changes that touch more than one row run in a transaction, so Rename and Copy are atomic
+type SQL struct {
+	db    *sql.DB
+	table string
+}
+
+// sqlQuerier runs queries on the database or in a transaction
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// sqlEntry is a row of the table, without its contents
+type sqlEntry struct {
+	dir          bool
+	size         int64
+	visibility   string
+	lastModified time.Time
+}
+
+// NewSQL creates a new instance of SQL, it creates the table if it does not exist
+func NewSQL(db *sql.DB, table string) (adapter.Adapter, error) {
+	if !sqlTableName.MatchString(table) {
+		return nil, &adapter.Error{Op: "mkdir", Path: table, Kind: adapter.ErrUnableToCreateRoot, Err: errInvalidTable}
+	}
+
+	a := &SQL{db: db, table: table}
+
+	_, err := db.Exec(a.query(sqlSchema))
+	if err != nil {
+		return nil, &adapter.Error{Op: "mkdir", Path: table, Kind: adapter.ErrUnableToCreateRoot, Err: err}
+	}
+
+	return a, nil
+}
+
+// Write a new file, it fails if the file already exists
+func (a *SQL) Write(path string, contents []byte) error {
+	return a.WriteContext(context.Background(), path, contents)
+}
+
+// Update a file, it fails if the file does not exist
+func (a *SQL) Update(path string, contents []byte) error {
+	return a.UpdateContext(context.Background(), path, contents)
+}
+
+// Put writes a file, creating or overwriting it
+func (a *SQL) Put(path string, contents []byte) error {
+	return a.PutContext(context.Background(), path, contents)
+}
+
+// Read a file
+func (a *SQL) Read(path string) ([]byte, error) {
+	return a.ReadContext(context.Background(), path)
+}
+
+// WriteStream writes a new file from a stream, it fails if the file already exists
+func (a *SQL) WriteStream(path string, contents io.Reader) error {
+	return a.WriteStreamContext(context.Background(), path, contents)
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *SQL) ReadStream(path string) (io.ReadCloser, error) {
+	return a.ReadStreamContext(context.Background(), path)
+}
+
+// Rename a file or directory
+func (a *SQL) Rename(path string, newPath string) error {
+	return a.RenameContext(context.Background(), path, newPath)
+}
+
+// Copy a file
+func (a *SQL) Copy(path string, newPath string) error {
+	return a.CopyContext(context.Background(), path, newPath)
+}
+
+// Delete a file
+func (a *SQL) Delete(path string) error {
+	return a.DeleteContext(context.Background(), path)
+}
+
+// CreateDir creates a directory
+func (a *SQL) CreateDir(dir string) error {
+	return a.CreateDirContext(context.Background(), dir)
+}
+
+// DeleteDir deletes a directory and its contents
+func (a *SQL) DeleteDir(dir string) error {
+	return a.DeleteDirContext(context.Background(), dir)
+}
+
+// SetVisibility sets a file or directory to public or private
+func (a *SQL) SetVisibility(path string, visibility string) error {
+	return a.SetVisibilityContext(context.Background(), path, visibility)
+}
+
+// Has checks if a file or directory exists
+func (a *SQL) Has(path string) (bool, error) {
+	return a.HasContext(context.Background(), path)
+}
+
+// FileExists checks if a file exists
+func (a *SQL) FileExists(path string) (bool, error) {
+	return a.FileExistsContext(context.Background(), path)
+}
+
+// DirectoryExists checks if a directory exists
+func (a *SQL) DirectoryExists(dir string) (bool, error) {
+	return a.DirectoryExistsContext(context.Background(), dir)
+}
+
+// Stat returns the attributes of a file or directory
+func (a *SQL) Stat(path string) (adapter.FileAttributes, error) {
+	return a.StatContext(context.Background(), path)
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories
+func (a *SQL) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	return a.ListContentsContext(context.Background(), dir, deep)
+}
+
+// WriteContext writes a new file
+func (a *SQL) WriteContext(ctx context.Context, path string, contents []byte) error {
+	p := adapter.NormalizePath(path)
+
+	return a.transaction(ctx, "write", path, func(tx *sql.Tx) error {
+		kind, err := a.kind(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind != "" {
+			return adapter.WrapError("write", path, adapter.ErrFileExists)
+		}
+
+		return a.insert(ctx, tx, "write", p, false, contents, adapter.VisibilityPublic)
+	})
+}
+
+// UpdateContext updates a file
+func (a *SQL) UpdateContext(ctx context.Context, path string, contents []byte) error {
+	res, err := a.db.ExecContext(ctx, a.query(`UPDATE $table SET contents = ?, updated_at = ? WHERE path = ? AND dir = ?`),
+		contents, time.Now().UTC(), adapter.NormalizePath(path), false)
+
+	return a.affected("update", path, res, err)
+}
+
+// PutContext writes a file, an existing file keeps its visibility
+func (a *SQL) PutContext(ctx context.Context, path string, contents []byte) error {
+	p := adapter.NormalizePath(path)
+
+	return a.transaction(ctx, "put", path, func(tx *sql.Tx) error {
+		kind, err := a.kind(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		switch kind {
+		case adapter.TypeDir:
+			return adapter.WrapError("put", path, adapter.ErrFileExists)
+		case adapter.TypeFile:
+			_, err = tx.ExecContext(ctx, a.query(`UPDATE $table SET contents = ?, updated_at = ? WHERE path = ?`), contents, time.Now().UTC(), p)
+
+			return err
+		}
+
+		return a.insert(ctx, tx, "put", p, false, contents, adapter.VisibilityPublic)
+	})
+}
+
+// ReadContext reads a file
+func (a *SQL) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	var contents []byte
+
+	err := a.db.QueryRowContext(ctx, a.query(`SELECT contents FROM $table WHERE path = ? AND dir = ?`), adapter.NormalizePath(path), false).Scan(&contents)
+	if err != nil {
+		return nil, sqlError("read", path, adapter.ErrFileNotFound, err)
+	}
+
+	if contents == nil {
+		contents = []byte{}
+	}
+
+	return contents, nil
+}
+
+// WriteStreamContext writes a new file from a stream, the stream is read into memory first
+func (a *SQL) WriteStreamContext(ctx context.Context, path string, contents io.Reader) error {
+	b, err := io.ReadAll(contents)
+	if err != nil {
+		return adapter.WrapError("write", path, err)
+	}
+
+	return a.WriteContext(ctx, path, b)
+}
+
+// ReadStreamContext opens a file for reading, the file is read into memory at once
+func (a *SQL) ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	contents, err := a.ReadContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	return io.NopCloser(bytes.NewReader(contents)), nil
+}
+
+// RenameContext renames a file or directory, a directory is moved with all its rows in one transaction.
+// An existing file at the destination is overwritten, an existing directory is not
+func (a *SQL) RenameContext(ctx context.Context, path string, newPath string) error {
+	p := adapter.NormalizePath(path)
+	destination := adapter.NormalizePath(newPath)
+
+	return a.transaction(ctx, "rename", path, func(tx *sql.Tx) error {
+		kind, err := a.kind(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind == "" {
+			return adapter.WrapError("rename", path, adapter.ErrFileNotFound)
+		}
+
+		if p == destination {
+			return nil
+		}
+
+		if kind == adapter.TypeDir && strings.HasPrefix(destination+"/", p+"/") {
+			return adapter.WrapError("rename", newPath, errMoveIntoItself)
+		}
+
+		existing, err := a.kind(ctx, tx, destination)
+		if err != nil {
+			return err
+		}
+
+		if existing == adapter.TypeDir {
+			return adapter.WrapError("rename", newPath, adapter.ErrFileExists)
+		}
+
+		err = a.checkParents(ctx, tx, "rename", destination)
+		if err != nil {
+			return err
+		}
+
+		moves := map[string]string{p: destination}
+
+		if kind == adapter.TypeDir {
+			children, err := a.children(ctx, tx, p)
+			if err != nil {
+				return err
+			}
+
+			for _, child := range children {
+				moves[child] = destination + strings.TrimPrefix(child, p)
+			}
+		}
+
+		for from, to := range moves {
+			_, err = tx.ExecContext(ctx, a.query(`DELETE FROM $table WHERE path = ?`), to)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, a.query(`UPDATE $table SET path = ? WHERE path = ?`), to, from)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// CopyContext copies a file in one transaction, the copy keeps the visibility of the file
+func (a *SQL) CopyContext(ctx context.Context, path string, newPath string) error {
+	p := adapter.NormalizePath(path)
+	destination := adapter.NormalizePath(newPath)
+
+	return a.transaction(ctx, "copy", path, func(tx *sql.Tx) error {
+		var contents []byte
+		var visibility string
+
+		err := tx.QueryRowContext(ctx, a.query(`SELECT contents, visibility FROM $table WHERE path = ? AND dir = ?`), p, false).Scan(&contents, &visibility)
+		if err != nil {
+			return sqlError("copy", path, adapter.ErrFileNotFound, err)
+		}
+
+		if p == destination {
+			return nil
+		}
+
+		kind, err := a.kind(ctx, tx, destination)
+		if err != nil {
+			return err
+		}
+
+		if kind == adapter.TypeDir {
+			return adapter.WrapError("copy", newPath, adapter.ErrFileExists)
+		}
+
+		_, err = tx.ExecContext(ctx, a.query(`DELETE FROM $table WHERE path = ?`), destination)
+		if err != nil {
+			return err
+		}
+
+		return a.insert(ctx, tx, "copy", destination, false, contents, visibility)
+	})
+}
+
+// DeleteContext deletes a file
+func (a *SQL) DeleteContext(ctx context.Context, path string) error {
+	res, err := a.db.ExecContext(ctx, a.query(`DELETE FROM $table WHERE path = ? AND dir = ?`), adapter.NormalizePath(path), false)
+
+	return a.affected("delete", path, res, err)
+}
+
+// CreateDirContext creates an explicit directory row
+func (a *SQL) CreateDirContext(ctx context.Context, dir string) error {
+	p := adapter.NormalizePath(dir)
+
+	return a.transaction(ctx, "mkdir", dir, func(tx *sql.Tx) error {
+		kind, err := a.kind(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind != "" {
+			return adapter.WrapDirError("mkdir", dir, adapter.ErrFileExists)
+		}
+
+		return a.insert(ctx, tx, "mkdir", p, true, nil, adapter.VisibilityPublic)
+	})
+}
+
+// DeleteDirContext deletes a directory and its contents in one transaction, a missing directory is ignored
+func (a *SQL) DeleteDirContext(ctx context.Context, dir string) error {
+	p := adapter.NormalizePath(dir)
+
+	return a.transaction(ctx, "rmdir", dir, func(tx *sql.Tx) error {
+		kind, err := a.kind(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind == adapter.TypeFile {
+			return adapter.WrapDirError("rmdir", dir, adapter.ErrDirectoryNotFound)
+		}
+
+		n, prefix := sqlPrefix(p)
+
+		_, err = tx.ExecContext(ctx, a.query(`DELETE FROM $table WHERE path = ? OR SUBSTR(path, 1, ?) = ?`), p, n, prefix)
+
+		return err
+	})
+}
+
+// SetVisibilityContext sets the visibility of a file or directory.
+// A directory that is only implied by its contents is stored as an explicit row to keep its visibility
+func (a *SQL) SetVisibilityContext(ctx context.Context, path string, visibility string) error {
+	if visibility != adapter.VisibilityPublic && visibility != adapter.VisibilityPrivate {
+		return adapter.WrapError("chmod", path, errUnknownVisibility)
+	}
+
+	p := adapter.NormalizePath(path)
+
+	return a.transaction(ctx, "chmod", path, func(tx *sql.Tx) error {
+		res, err := tx.ExecContext(ctx, a.query(`UPDATE $table SET visibility = ? WHERE path = ?`), visibility, p)
+		if err != nil {
+			return err
+		}
+
+		if n, err := res.RowsAffected(); err != nil || n > 0 {
+			return err
+		}
+
+		kind, err := a.kind(ctx, tx, p)
+		if err != nil {
+			return err
+		}
+
+		if kind == "" || p == "" {
+			return adapter.WrapError("chmod", path, adapter.ErrFileNotFound)
+		}
+
+		return a.insert(ctx, tx, "chmod", p, true, nil, visibility)
+	})
+}
+
+// HasContext checks if a file or directory exists
+func (a *SQL) HasContext(ctx context.Context, path string) (bool, error) {
+	kind, err := a.kind(ctx, a.db, adapter.NormalizePath(path))
+
+	return kind != "", sqlError("stat", path, adapter.ErrFileNotFound, err)
+}
+
+// FileExistsContext checks if a file exists
+func (a *SQL) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	kind, err := a.kind(ctx, a.db, adapter.NormalizePath(path))
+
+	return kind == adapter.TypeFile, sqlError("stat", path, adapter.ErrFileNotFound, err)
+}
+
+// DirectoryExistsContext checks if a directory row or a file in the directory exists
+func (a *SQL) DirectoryExistsContext(ctx context.Context, dir string) (bool, error) {
+	kind, err := a.kind(ctx, a.db, adapter.NormalizePath(dir))
+
+	return kind == adapter.TypeDir, sqlError("stat", dir, adapter.ErrDirectoryNotFound, err)
+}
+
+// StatContext returns the attributes of a file or directory.
+// The contents are only read when the mime type can't be told by the extension
+func (a *SQL) StatContext(ctx context.Context, path string) (adapter.FileAttributes, error) {
+	p := adapter.NormalizePath(path)
+
+	entry, err := a.lookup(ctx, a.db, p)
+	if err != nil {
+		return adapter.FileAttributes{}, sqlError("stat", path, adapter.ErrFileNotFound, err)
+	}
+
+	if entry == nil {
+		ok, err := a.hasChildren(ctx, a.db, p)
+		if err != nil {
+			return adapter.FileAttributes{}, sqlError("stat", path, adapter.ErrFileNotFound, err)
+		}
+
+		if !ok && p != "" {
+			return adapter.FileAttributes{}, adapter.WrapError("stat", path, adapter.ErrFileNotFound)
+		}
+
+		entry = &sqlEntry{dir: true, visibility: adapter.VisibilityPublic}
+	}
+
+	attributes := entry.attributes(p)
+
+	if attributes.IsFile() && attributes.MimeType == "" {
+		contents, err := a.ReadContext(ctx, path)
+		if err != nil {
+			return adapter.FileAttributes{}, err
+		}
+
+		attributes.MimeType = adapter.DetectMimeType(p, contents)
+	}
+
+	return attributes, nil
+}
+
+// ListContentsContext lists the contents of a directory, deep also lists the contents of subdirectories.
+// The rows are read at once, mime types are guessed from the extension only
+func (a *SQL) ListContentsContext(ctx context.Context, dir string, deep bool) (adapter.DirectoryListing, error) {
+	p := adapter.NormalizePath(dir)
+
+	kind, err := a.kind(ctx, a.db, p)
+	if err != nil {
+		return nil, sqlError("list", dir, adapter.ErrDirectoryNotFound, err)
+	}
+
+	if kind != adapter.TypeDir {
+		return nil, adapter.WrapDirError("list", dir, adapter.ErrDirectoryNotFound)
+	}
+
+	n, prefix := sqlPrefix(p)
+
+	rows, err := a.db.QueryContext(ctx, a.query(`SELECT path, dir, LENGTH(contents), visibility, updated_at FROM $table WHERE SUBSTR(path, 1, ?) = ?`), n, prefix)
+	if err != nil {
+		return nil, sqlError("list", dir, adapter.ErrDirectoryNotFound, err)
+	}
+
+	defer rows.Close()
+
+	entries := map[string]adapter.FileAttributes{}
+
+	for rows.Next() {
+		var child string
+		var size sql.NullInt64
+
+		entry := &sqlEntry{}
+
+		err = rows.Scan(&child, &entry.dir, &size, &entry.visibility, &entry.lastModified)
+		if err != nil {
+			return nil, sqlError("list", dir, adapter.ErrDirectoryNotFound, err)
+		}
+
+		entry.size = size.Int64
+
+		// Directories between dir and the row are implied by it
+		for parent := path.Dir(child); strings.HasPrefix(parent, prefix) && parent != p && parent != "."; parent = path.Dir(parent) {
+			if _, ok := entries[parent]; !ok {
+				entries[parent] = adapter.FileAttributes{Path: parent, Type: adapter.TypeDir, Visibility: adapter.VisibilityPublic}
+			}
+		}
+
+		entries[child] = entry.attributes(child)
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, sqlError("list", dir, adapter.ErrDirectoryNotFound, err)
+	}
+
+	contents := make([]adapter.FileAttributes, 0, len(entries))
+
+	for child, attributes := range entries {
+		if !deep && strings.Contains(strings.TrimPrefix(child, prefix), "/") {
+			continue
+		}
+
+		contents = append(contents, attributes)
+	}
+
+	sort.Slice(contents, func(i, j int) bool {
+		return contents[i].Path < contents[j].Path
+	})
+
+	return adapter.NewSliceListing(contents), nil
+}
+
+// query fills in the table name
+func (a *SQL) query(query string) string {
+	return strings.ReplaceAll(query, "$table", a.table)
+}
+
+// transaction runs fn in a transaction, it is committed when fn succeeds and rolled back otherwise
+func (a *SQL) transaction(ctx context.Context, op string, path string, fn func(tx *sql.Tx) error) error {
+	tx, err := a.db.BeginTx(ctx, nil)
+	if err != nil {
+		return adapter.WrapError(op, path, err)
+	}
+
+	err = fn(tx)
+	if err != nil {
+		tx.Rollback()
+
+		return adapter.WrapError(op, path, err)
+	}
+
+	return adapter.WrapError(op, path, tx.Commit())
+}
+
+// insert adds a row, it fails if a parent of p is a file
+func (a *SQL) insert(ctx context.Context, q sqlQuerier, op string, p string, dir bool, contents []byte, visibility string) error {
+	err := a.checkParents(ctx, q, op, p)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+
+	_, err = q.ExecContext(ctx, a.query(`INSERT INTO $table (path, dir, contents, visibility, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?)`),
+		p, dir, contents, visibility, now, now)
+
+	return err
+}
+
+// affected reports ErrFileNotFound when a statement on a single file didn't change a row
+func (a *SQL) affected(op string, path string, res sql.Result, err error) error {
+	if err != nil {
+		return adapter.WrapError(op, path, err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return adapter.WrapError(op, path, err)
+	}
+
+	if n == 0 {
+		return adapter.WrapError(op, path, adapter.ErrFileNotFound)
+	}
+
+	return nil
+}
+
+// kind returns TypeFile or TypeDir for an existing path, or an empty string if it does not exist
+func (a *SQL) kind(ctx context.Context, q sqlQuerier, p string) (string, error) {
+	if p == "" {
+		return adapter.TypeDir, nil
+	}
+
+	entry, err := a.lookup(ctx, q, p)
+	if err != nil {
+		return "", err
+	}
+
+	if entry != nil && !entry.dir {
+		return adapter.TypeFile, nil
+	}
+
+	if entry != nil {
+		return adapter.TypeDir, nil
+	}
+
+	ok, err := a.hasChildren(ctx, q, p)
+	if err != nil || !ok {
+		return "", err
+	}
+
+	return adapter.TypeDir, nil
+}
+
+// lookup returns the row stored at p, or nil if there is none
+func (a *SQL) lookup(ctx context.Context, q sqlQuerier, p string) (*sqlEntry, error) {
+	var size sql.NullInt64
+
+	entry := &sqlEntry{}
+
+	err := q.QueryRowContext(ctx, a.query(`SELECT dir, LENGTH(contents), visibility, updated_at FROM $table WHERE path = ?`), p).
+		Scan(&entry.dir, &size, &entry.visibility, &entry.lastModified)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	entry.size = size.Int64
+
+	return entry, nil
+}
+
+// hasChildren reports whether there are rows in the directory p
+func (a *SQL) hasChildren(ctx context.Context, q sqlQuerier, p string) (bool, error) {
+	var child string
+
+	n, prefix := sqlPrefix(p)
+
+	err := q.QueryRowContext(ctx, a.query(`SELECT path FROM $table WHERE SUBSTR(path, 1, ?) = ? LIMIT 1`), n, prefix).Scan(&child)
+	if errors.Is(err, sql.ErrNoRows) {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// children returns the paths of the rows in the directory p
+func (a *SQL) children(ctx context.Context, q sqlQuerier, p string) ([]string, error) {
+	n, prefix := sqlPrefix(p)
+
+	rows, err := q.QueryContext(ctx, a.query(`SELECT path FROM $table WHERE SUBSTR(path, 1, ?) = ?`), n, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	var children []string
+
+	for rows.Next() {
+		var child string
+
+		if err := rows.Scan(&child); err != nil {
+			return nil, err
+		}
+
+		children = append(children, child)
+	}
+
+	return children, rows.Err()
+}
+
+// checkParents fails when one of the parents of p is a file
+func (a *SQL) checkParents(ctx context.Context, q sqlQuerier, op string, p string) error {
+	for dir := path.Dir(p); dir != "." && dir != "/"; dir = path.Dir(dir) {
+		entry, err := a.lookup(ctx, q, dir)
+		if err != nil {
+			return err
+		}
+
+		if entry != nil && !entry.dir {
+			return adapter.WrapDirError(op, p, adapter.ErrDirectoryNotFound)
+		}
+
+		if entry != nil {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+// attributes describes the row stored at p
+func (e *sqlEntry) attributes(p string) adapter.FileAttributes {
+	attributes := adapter.FileAttributes{
+		Path:         p,
+		Type:         adapter.TypeFile,
+		LastModified: e.lastModified,
+		Visibility:   e.visibility,
+	}
+
+	if e.dir {
+		attributes.Type = adapter.TypeDir
+
+		return attributes
+	}
+
+	attributes.Size = e.size
+	attributes.MimeType = adapter.DetectMimeType(p, nil)
+
+	return attributes
+}
+
+// sqlPrefix returns the arguments of a SUBSTR(path, 1, ?) = ? condition that matches the paths in the directory p.
+// LIKE ignores case in SQLite and in MySQL's default collation, comparing the prefix follows the collation of the column
+func sqlPrefix(p string) (int, string) {
+	if p == "" {
+		return 0, ""
+	}
+
+	prefix := p + "/"
+
+	return utf8.RuneCountInString(prefix), prefix
+}
+
+// sqlError wraps an error of the database, a missing row is reported as notFound
+func sqlError(op string, path string, notFound error, err error) error {
+	if errors.Is(err, sql.ErrNoRows) {
+		return &adapter.Error{Op: op, Path: path, Kind: notFound}
+	}
+
+	return adapter.NewError(op, path, notFound, err)
+}
@@ -0,0 +1,447 @@
+package sql
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	_ "modernc.org/sqlite"
+)
+
+func newSQL(t *testing.T) (adapter.Adapter, *sql.DB) {
+	db, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "test.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() {
+		db.Close()
+	})
+
+	fs, err := NewSQL(db, "files")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs, db
+}
+
+func TestNewSQL_InvalidTable(t *testing.T) {
+	_, db := newSQL(t)
+
+	_, err := NewSQL(db, "files; DROP TABLE files")
+	if !errors.Is(err, adapter.ErrUnableToCreateRoot) || !errors.Is(err, errInvalidTable) {
+		t.Logf("expected errInvalidTable, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSQL_Write(t *testing.T) {
+	fs, _ := newSQL(t)
+
+	before := time.Now().Add(-time.Second)
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != adapter.VisibilityPublic || !strings.HasPrefix(attributes.MimeType, "text/plain") || attributes.LastModified.Before(before) {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt/nested.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("missing.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("sub/test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Put("sub/test.txt", []byte("hello put"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err = fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 9 || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("expected Put to keep the visibility, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Put("sub", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists for a directory, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.WriteStream("empty", strings.NewReader(""))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err = fs.Read("empty")
+	if err != nil || contents == nil || len(contents) != 0 {
+		t.Logf("expected an empty file, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	_, err = fs.ReadStream("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Delete("sub/test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Delete("sub/test.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSQL_Rename(t *testing.T) {
+	fs, _ := newSQL(t)
+
+	if err := fs.CreateDir("dir/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"dir/a.txt", "dir/sub/b.txt", "file.txt", "dir_other/c.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := fs.Rename("file.txt", "renamed.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("file.txt"); ok {
+		t.Log("expected file.txt to be gone")
+		t.Fail()
+	}
+
+	err = fs.Rename("dir", "moved/dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"moved/dir/a.txt", "moved/dir/sub/b.txt"} {
+		contents, err := fs.Read(p)
+		if err != nil || !strings.HasSuffix(string(contents), strings.TrimPrefix(p, "moved/")) {
+			t.Logf("expected %s to be moved, got %q %v", p, contents, err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.DirectoryExists("moved/dir/empty"); !ok {
+		t.Log("expected the empty directory to be moved")
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("dir"); ok {
+		t.Log("expected dir to be gone")
+		t.Fail()
+	}
+
+	// LIKE wildcards in the path must not match other directories
+	if ok, _ := fs.FileExists("dir_other/c.txt"); !ok {
+		t.Log("expected dir_other to be left alone")
+		t.Fail()
+	}
+
+	err = fs.Rename("moved", "moved/inside")
+	if !errors.Is(err, errMoveIntoItself) {
+		t.Logf("expected errMoveIntoItself, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Rename("renamed.txt", "dir_other")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Rename("missing.txt", "other.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSQL_Copy(t *testing.T) {
+	fs, _ := newSQL(t)
+
+	err := fs.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.SetVisibility("test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Copy("test.txt", "sub/copy.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/copy.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("copy does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/copy.txt")
+	if err != nil || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("expected the copy to keep its visibility, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "copy.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Copy("test.txt", "sub")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSQL_Directories(t *testing.T) {
+	fs, _ := newSQL(t)
+
+	err := fs.CreateDir("dir/empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("dir/empty")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt", "dir%/d.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir", "dir%"}},
+		{"dir", false, []string{"dir/b.txt", "dir/empty", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "dir%", "dir%/d.txt", "dir/b.txt", "dir/empty", "dir/sub", "dir/sub/c.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := adapter.Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err = fs.ListContents("missing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("dir/sub", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("dir/sub")
+	if err != nil || !attributes.IsDir() || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Logf("expected the implicit directory to keep its visibility, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"dir", "dir/sub/c.txt", "dir/empty"} {
+		if ok, _ := fs.Has(p); ok {
+			t.Logf("expected %s to be deleted", p)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.FileExists("dir%/d.txt"); !ok {
+		t.Log("expected dir% to be left alone")
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Logf("expected deleting a missing directory to succeed, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestSQL_CaseSensitive(t *testing.T) {
+	fs, _ := newSQL(t)
+
+	for _, p := range []string{"DOCS/secret.txt", "docs/a.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if ok, _ := fs.DirectoryExists("Docs"); ok {
+		t.Log("expected Docs not to match DOCS or docs")
+		t.Fail()
+	}
+
+	listing, err := fs.ListContents("docs", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := adapter.Collect(listing)
+	if err != nil || len(listed) != 1 || listed[0].Path != "docs/a.txt" {
+		t.Logf("expected only docs/a.txt, got %v %v", listed, err)
+		t.Fail()
+	}
+
+	err = fs.Rename("docs", "moved")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("moved")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("DOCS/secret.txt")
+	if err != nil || string(contents) != "DOCS/secret.txt" {
+		t.Logf("expected DOCS/secret.txt to be left alone, got %q %v", contents, err)
+		t.Fail()
+	}
+}
+
+func TestSQL_Context(t *testing.T) {
+	fs, _ := newSQL(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := adapter.WithContext(fs).WriteContext(ctx, "test.txt", []byte("hello"))
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("test.txt"); ok {
+		t.Log("expected the write to be cancelled")
+		t.Fail()
+	}
+}
+
+func TestSQL_Transaction(t *testing.T) {
+	fs, db := newSQL(t)
+
+	for _, p := range []string{"dir/a.txt", "dir/b.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Make the move of the second row fail, the first one has to be rolled back
+	_, err := db.Exec(`CREATE TRIGGER fail_move BEFORE UPDATE OF path ON files WHEN NEW.path = 'moved/b.txt' BEGIN SELECT RAISE(ABORT, 'no'); END`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.Rename("dir", "moved")
+	if err == nil {
+		t.Log("expected the rename to fail")
+		t.Fail()
+	}
+
+	for _, p := range []string{"dir/a.txt", "dir/b.txt"} {
+		if ok, _ := fs.FileExists(p); !ok {
+			t.Logf("expected %s to be left in place", p)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.Has("moved"); ok {
+		t.Log("expected nothing to be moved")
+		t.Fail()
+	}
+}
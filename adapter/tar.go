@@ -0,0 +1,155 @@
+package adapter
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"io"
+	"os"
+)
+
+// Magic numbers of the compression formats a tar archive may be wrapped in
+var (
+	gzipMagic  = []byte{0x1f, 0x8b}
+	bzip2Magic = []byte("BZh")
+)
+
+// Tar reads files from a tar archive, it is read-only and every change fails with ErrReadOnly.
+// Archives compressed with gzip or bzip2 are recognised by their contents, whatever their extension.
+// A tar archive can only be read from the start: reading a file scans the archive up to it,
+// plain archives skip over the other files, compressed archives have to decompress them
+type Tar struct {
+	archive
+	file *os.File
+	size int64
+}
+
+// NewTar opens a tar archive and indexes its files and directories, call Close to close the archive file.
+// Entries other than regular files and directories, such as links, are left out
+func NewTar(path string) (Adapter, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, wrapError("open", path, err)
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+
+		return nil, wrapError("open", path, err)
+	}
+
+	a := &Tar{file: file, size: info.Size()}
+
+	a.archive = newArchive(a.openEntry)
+
+	var sniffErr error
+
+	err = a.scan(func(header *tar.Header, r *tar.Reader, index int) bool {
+		switch header.Typeflag {
+		case tar.TypeReg:
+			entry := a.add(header.Name, header.FileInfo(), index)
+
+			// Opening the file later means scanning the archive from the start again,
+			// read what's needed to guess the mime type while passing by
+			if entry != nil && detectMimeType(header.Name, nil) == "" {
+				entry.head, sniffErr = io.ReadAll(io.LimitReader(r, sniffSize))
+			}
+		case tar.TypeDir:
+			a.add(header.Name, header.FileInfo(), index)
+		}
+
+		return sniffErr == nil
+	})
+	if err == nil {
+		err = sniffErr
+	}
+
+	if err != nil {
+		file.Close()
+
+		return nil, wrapError("open", path, err)
+	}
+
+	return a, nil
+}
+
+// Close closes the archive file
+func (a *Tar) Close() error {
+	return a.file.Close()
+}
+
+// openEntry scans the archive up to the entry and returns a reader of its contents
+func (a *Tar) openEntry(entry *archiveEntry) (io.ReadCloser, error) {
+	var contents io.ReadCloser
+
+	// The reader is handed out before the scan is done, stop right after so it isn't moved on
+	err := a.scan(func(header *tar.Header, r *tar.Reader, index int) bool {
+		if index != entry.index {
+			return true
+		}
+
+		contents = io.NopCloser(r)
+
+		return false
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if contents == nil {
+		return nil, os.ErrNotExist
+	}
+
+	return contents, nil
+}
+
+// scan calls fn with every header in the archive until it returns false.
+// Each scan reads the file through its own section so scans can run at the same time
+func (a *Tar) scan(fn func(header *tar.Header, r *tar.Reader, index int) bool) error {
+	r, err := tarDecompress(io.NewSectionReader(a.file, 0, a.size))
+	if err != nil {
+		return err
+	}
+
+	tr := tar.NewReader(r)
+
+	for index := 0; ; index++ {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if !fn(header, tr, index) {
+			return nil
+		}
+	}
+}
+
+// tarDecompress wraps r in a decompressor when it starts with the magic number of gzip or bzip2.
+// A plain archive is returned as is, so the tar reader can seek over the contents it skips
+func tarDecompress(r *io.SectionReader) (io.Reader, error) {
+	magic := make([]byte, len(bzip2Magic))
+
+	n, err := r.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	magic = magic[:n]
+
+	switch {
+	case bytes.HasPrefix(magic, gzipMagic):
+		return gzip.NewReader(bufio.NewReader(r))
+	case bytes.HasPrefix(magic, bzip2Magic):
+		return bzip2.NewReader(bufio.NewReader(r)), nil
+	}
+
+	return r, nil
+}
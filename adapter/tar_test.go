@@ -0,0 +1,192 @@
+package adapter
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// newTarFile writes a tar archive, optionally compressed with gzip.
+// b.txt is stored twice, the second copy replaces the first
+func newTarFile(t *testing.T, compress bool) string {
+	p := filepath.Join(t.TempDir(), "test.tar")
+
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	var w io.Writer = f
+
+	if compress {
+		gz := gzip.NewWriter(f)
+		defer gz.Close()
+
+		w = gz
+	}
+
+	tw := tar.NewWriter(w)
+	defer tw.Close()
+
+	entries := []*tar.Header{
+		{Name: "a.txt", Mode: 0644, Size: 11, Typeflag: tar.TypeReg},
+		{Name: "dir/", Mode: 0755, Typeflag: tar.TypeDir},
+		{Name: "dir/b.txt", Mode: 0644, Size: 3, Typeflag: tar.TypeReg},
+		{Name: "dir/link", Linkname: "b.txt", Typeflag: tar.TypeSymlink},
+		{Name: "./dir/sub/c.txt", Mode: 0600, Size: 7, Typeflag: tar.TypeReg},
+		{Name: "dir/b.txt", Mode: 0644, Size: 9, Typeflag: tar.TypeReg},
+		{Name: "dir/page", Mode: 0644, Size: 13, Typeflag: tar.TypeReg},
+	}
+
+	contents := []string{"hello world", "", "old", "", "private", "dir/b.txt", "<html></html>"}
+
+	for i, header := range entries {
+		if err := tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := io.WriteString(tw, contents[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return p
+}
+
+func TestTar_Read(t *testing.T) {
+	for _, compress := range []bool{false, true} {
+		fs, err := NewTar(newTarFile(t, compress))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := fs.Read("a.txt")
+		if err != nil || string(contents) != "hello world" {
+			t.Logf("compress=%v: files does not contain: hello world", compress)
+			t.Fail()
+		}
+
+		contents, err = fs.Read("dir/b.txt")
+		if err != nil || string(contents) != "dir/b.txt" {
+			t.Logf("compress=%v: expected the last copy, got %q %v", compress, contents, err)
+			t.Fail()
+		}
+
+		attributes, err := fs.Stat("dir/sub/c.txt")
+		if err != nil || attributes.Size != 7 || attributes.Visibility != VisibilityPrivate || !attributes.IsFile() {
+			t.Logf("compress=%v: unexpected attributes: %+v %v", compress, attributes, err)
+			t.Fail()
+		}
+
+		if ok, _ := fs.Has("dir/link"); ok {
+			t.Logf("compress=%v: expected links to be left out", compress)
+			t.Fail()
+		}
+
+		_, err = fs.ReadStream("missing.txt")
+		if !errors.Is(err, ErrFileNotFound) {
+			t.Logf("compress=%v: expected ErrFileNotFound, got %v", compress, err)
+			t.Fail()
+		}
+
+		err = fs.Write("new.txt", []byte("hello"))
+		if !errors.Is(err, ErrReadOnly) {
+			t.Logf("compress=%v: expected ErrReadOnly, got %v", compress, err)
+			t.Fail()
+		}
+
+		fs.(*Tar).Close()
+	}
+}
+
+func TestTar_ListContents(t *testing.T) {
+	fs, err := NewTar(newTarFile(t, true))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.(*Tar).Close()
+
+	// Listing must not scan the archive again, the mime types were sniffed while indexing
+	fs.(*Tar).open = func(entry *archiveEntry) (io.ReadCloser, error) {
+		t.Error("expected the archive not to be scanned again")
+
+		return nil, os.ErrNotExist
+	}
+
+	listing, err := fs.ListContents("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := Collect(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, attributes := range contents {
+		paths = append(paths, attributes.Path)
+
+		if attributes.Path == "dir/page" && !strings.HasPrefix(attributes.MimeType, "text/html") {
+			t.Logf("expected dir/page to be sniffed as html, got %q", attributes.MimeType)
+			t.Fail()
+		}
+	}
+
+	expected := []string{"a.txt", "dir", "dir/b.txt", "dir/page", "dir/sub", "dir/sub/c.txt"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Logf("expected %v, got %v", expected, paths)
+		t.Fail()
+	}
+}
+
+func TestTar_ConcurrentReads(t *testing.T) {
+	fs, err := NewTar(newTarFile(t, false))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.(*Tar).Close()
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			contents, err := fs.Read("dir/sub/c.txt")
+			if err != nil || string(contents) != "private" {
+				t.Logf("unexpected contents: %q %v", contents, err)
+				t.Fail()
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestTar_Invalid(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "invalid.tar.gz")
+
+	err := os.WriteFile(p, []byte{0x1f, 0x8b, 0x00}, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = NewTar(p)
+	if err == nil {
+		t.Log("expected an error for a corrupt archive")
+		t.Fail()
+	}
+}
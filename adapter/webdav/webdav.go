@@ -0,0 +1,358 @@
+package webdav
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"github.com/studio-b12/gowebdav"
+)
+
+var errNoVisibility = errors.New("webdav has no visibility")
+
+// WebDAVConfig configures a WebDAV adapter
+type WebDAVConfig struct {
+	// URL of the collection the paths are relative to, for Nextcloud https://host/remote.php/dav/files/<user>
+	URL string
+	// User to log in as, basic or digest authentication is negotiated with the server
+	User string
+	// Password of the user
+	Password string
+	// Timeout limits every request, including the transfer of the body
+	Timeout time.Duration
+	// Transport sends the requests, it defaults to http.DefaultTransport
+	Transport http.RoundTripper
+}
+
+// WebDAV stores files on a WebDAV server such as Nextcloud.
+// Directories are collections, WebDAV has no permissions so files have no visibility
+type WebDAV struct {
+	client *gowebdav.Client
+}
+
+// NewWebDAV creates a new instance of WebDAV, it connects to the server to check the credentials
+func NewWebDAV(config WebDAVConfig) (adapter.Adapter, error) {
+	client := gowebdav.NewClient(config.URL, config.User, config.Password)
+
+	if config.Timeout > 0 {
+		client.SetTimeout(config.Timeout)
+	}
+
+	if config.Transport != nil {
+		client.SetTransport(config.Transport)
+	}
+
+	err := client.Connect()
+	if err != nil {
+		return nil, webdavError("connect", config.URL, adapter.ErrDirectoryNotFound, err)
+	}
+
+	return &WebDAV{client: client}, nil
+}
+
+// Write a new file, it fails if the file already exists
+func (a *WebDAV) Write(path string, contents []byte) error {
+	ok, err := a.Has(path)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return adapter.WrapError("write", path, adapter.ErrFileExists)
+	}
+
+	return webdavError("write", path, adapter.ErrFileNotFound, a.client.Write(a.location(path), contents, 0))
+}
+
+// Update a file, it fails if the file does not exist
+func (a *WebDAV) Update(path string, contents []byte) error {
+	ok, err := a.FileExists(path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("update", path, adapter.ErrFileNotFound)
+	}
+
+	return webdavError("update", path, adapter.ErrFileNotFound, a.client.Write(a.location(path), contents, 0))
+}
+
+// Put writes a file, creating or overwriting it
+func (a *WebDAV) Put(path string, contents []byte) error {
+	return webdavError("put", path, adapter.ErrFileNotFound, a.client.Write(a.location(path), contents, 0))
+}
+
+// Read a file
+func (a *WebDAV) Read(path string) ([]byte, error) {
+	contents, err := a.client.Read(a.location(path))
+	if err != nil {
+		return nil, webdavError("read", path, adapter.ErrFileNotFound, err)
+	}
+
+	return contents, nil
+}
+
+// WriteStream writes a new file from a stream, it fails if the file already exists.
+// Streams that can't seek are kept in memory while uploading, in case the server asks to authenticate again
+func (a *WebDAV) WriteStream(path string, contents io.Reader) error {
+	ok, err := a.Has(path)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return adapter.WrapError("write", path, adapter.ErrFileExists)
+	}
+
+	// A length of -1 sends the stream chunked, the length isn't known up front
+	err = a.client.WriteStreamWithLength(a.location(path), contents, -1, 0)
+
+	return webdavError("write", path, adapter.ErrFileNotFound, err)
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *WebDAV) ReadStream(path string) (io.ReadCloser, error) {
+	r, err := a.client.ReadStream(a.location(path))
+	if err != nil {
+		return nil, webdavError("read", path, adapter.ErrFileNotFound, err)
+	}
+
+	return r, nil
+}
+
+// Rename a file or directory with MOVE.
+// Not every server creates the missing parents of the destination, so they are created first
+func (a *WebDAV) Rename(path string, newPath string) error {
+	ok, err := a.Has(path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("rename", path, adapter.ErrFileNotFound)
+	}
+
+	err = a.client.MkdirAll(a.parent(newPath), 0)
+	if err != nil {
+		return webdavError("rename", newPath, adapter.ErrDirectoryNotFound, err)
+	}
+
+	return webdavError("rename", path, adapter.ErrFileNotFound, a.client.Rename(a.location(path), a.location(newPath), true))
+}
+
+// Copy a file with COPY, the server copies it without downloading
+func (a *WebDAV) Copy(path string, newPath string) error {
+	ok, err := a.FileExists(path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("copy", path, adapter.ErrFileNotFound)
+	}
+
+	return webdavError("copy", path, adapter.ErrFileNotFound, a.client.Copy(a.location(path), a.location(newPath), true))
+}
+
+// Delete a file
+func (a *WebDAV) Delete(path string) error {
+	ok, err := a.FileExists(path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("delete", path, adapter.ErrFileNotFound)
+	}
+
+	return webdavError("delete", path, adapter.ErrFileNotFound, a.client.Remove(a.location(path)))
+}
+
+// CreateDir creates a collection and its missing parents with MKCOL
+func (a *WebDAV) CreateDir(dir string) error {
+	ok, err := a.Has(dir)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return adapter.WrapDirError("mkdir", dir, adapter.ErrFileExists)
+	}
+
+	return webdavError("mkdir", dir, adapter.ErrDirectoryNotFound, a.client.MkdirAll(a.location(dir), 0))
+}
+
+// DeleteDir deletes a collection and its contents, a single DELETE removes the whole tree
+func (a *WebDAV) DeleteDir(dir string) error {
+	info, err := a.stat("rmdir", dir)
+	if err != nil || info == nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return adapter.WrapDirError("rmdir", dir, adapter.ErrDirectoryNotFound)
+	}
+
+	return webdavError("rmdir", dir, adapter.ErrDirectoryNotFound, a.client.RemoveAll(a.location(dir)))
+}
+
+// SetVisibility is not supported, WebDAV has no permissions
+func (a *WebDAV) SetVisibility(path string, visibility string) error {
+	ok, err := a.Has(path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("chmod", path, adapter.ErrFileNotFound)
+	}
+
+	return adapter.WrapError("chmod", path, errNoVisibility)
+}
+
+// Has checks if a file or directory exists
+func (a *WebDAV) Has(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil, err
+}
+
+// FileExists checks if a file exists
+func (a *WebDAV) FileExists(path string) (bool, error) {
+	info, err := a.stat("stat", path)
+
+	return info != nil && !info.IsDir(), err
+}
+
+// DirectoryExists checks if a directory exists
+func (a *WebDAV) DirectoryExists(dir string) (bool, error) {
+	info, err := a.stat("stat", dir)
+
+	return info != nil && info.IsDir(), err
+}
+
+// Stat returns the attributes of a file or directory, the mime type is the one reported by the server
+func (a *WebDAV) Stat(path string) (adapter.FileAttributes, error) {
+	info, err := a.stat("stat", path)
+	if err != nil {
+		return adapter.FileAttributes{}, err
+	}
+
+	if info == nil {
+		return adapter.FileAttributes{}, adapter.WrapError("stat", path, adapter.ErrFileNotFound)
+	}
+
+	return a.attributes(path, info), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// Every collection is read with its own PROPFIND while iterating
+func (a *WebDAV) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	l, err := adapter.NewReadDirListing(adapter.NormalizePath(dir), deep, a.readDir, a.attributes)
+	if err != nil {
+		return nil, webdavError("list", dir, adapter.ErrDirectoryNotFound, err)
+	}
+
+	return l, nil
+}
+
+// location returns the path on the server, relative to the configured URL
+func (a *WebDAV) location(p string) string {
+	return "/" + adapter.NormalizePath(p)
+}
+
+// parent returns the location of the collection p is in
+func (a *WebDAV) parent(p string) string {
+	return path.Dir(a.location(p))
+}
+
+// readDir reads the members of a collection
+func (a *WebDAV) readDir(dir string) ([]os.FileInfo, error) {
+	entries, err := a.client.ReadDir(a.location(dir))
+	if gowebdav.IsErrNotFound(err) {
+		return nil, os.ErrNotExist
+	}
+
+	// Servers return the members in any order
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, err
+}
+
+// stat returns the file info of path, or nil if it does not exist
+func (a *WebDAV) stat(op string, path string) (os.FileInfo, error) {
+	info, err := a.client.Stat(a.location(path))
+	if gowebdav.IsErrNotFound(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, webdavError(op, path, adapter.ErrFileNotFound, err)
+	}
+
+	return info, nil
+}
+
+// attributes describes a file or collection, the permissions reported by gowebdav are made up so there is no visibility
+func (a *WebDAV) attributes(p string, info os.FileInfo) adapter.FileAttributes {
+	attributes := adapter.FileAttributes{
+		Path:         adapter.NormalizePath(p),
+		LastModified: info.ModTime(),
+	}
+
+	if info.IsDir() {
+		attributes.Type = adapter.TypeDir
+
+		return attributes
+	}
+
+	attributes.Type = adapter.TypeFile
+	attributes.Size = info.Size()
+
+	if file, ok := info.(interface{ ContentType() string }); ok {
+		attributes.MimeType = file.ContentType()
+	}
+
+	if attributes.MimeType == "" {
+		attributes.MimeType = adapter.DetectMimeType(attributes.Path, nil)
+	}
+
+	return attributes
+}
+
+// webdavStatus returns the status code of a failed request, or 0 if the request wasn't answered
+func webdavStatus(err error) int {
+	var status gowebdav.StatusError
+	if errors.As(err, &status) {
+		return status.Status
+	}
+
+	return 0
+}
+
+// webdavError wraps an error returned by the server, mapping its status codes to the error kinds.
+// Servers answer 409 when the parent collection is missing, it is reported as not found
+func webdavError(op string, path string, notFound error, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch webdavStatus(err) {
+	case http.StatusNotFound, http.StatusConflict:
+		return &adapter.Error{Op: op, Path: path, Kind: notFound, Err: err}
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return &adapter.Error{Op: op, Path: path, Kind: adapter.ErrPermissionDenied, Err: err}
+	case http.StatusPreconditionFailed:
+		return &adapter.Error{Op: op, Path: path, Kind: adapter.ErrFileExists, Err: err}
+	}
+
+	return adapter.NewError(op, path, notFound, err)
+}
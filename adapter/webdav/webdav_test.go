@@ -0,0 +1,367 @@
+package webdav
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"golang.org/x/net/webdav"
+)
+
+const (
+	webdavUser     = "test"
+	webdavPassword = "secret"
+	webdavRealm    = "flysystem"
+	webdavNonce    = "dcd98b7102dd2f0e8b11d0f600bfb0c093"
+)
+
+// webdavAuth only lets requests through that authenticate as webdavUser, with basic or digest authentication.
+// The digest response is checked against the uri in the header, the client sends the path without the prefix of the URL
+func webdavAuth(digest bool, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !digest {
+			user, password, ok := r.BasicAuth()
+			if ok && user == webdavUser && password == webdavPassword {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, webdavRealm))
+			w.WriteHeader(http.StatusUnauthorized)
+
+			return
+		}
+
+		header := r.Header.Get("Authorization")
+		if strings.HasPrefix(header, "Digest ") {
+			params := map[string]string{}
+			for _, part := range strings.Split(strings.TrimPrefix(header, "Digest "), ", ") {
+				if k, v, ok := strings.Cut(part, "="); ok {
+					params[k] = strings.Trim(v, `"`)
+				}
+			}
+
+			ha1 := webdavMD5(webdavUser + ":" + webdavRealm + ":" + webdavPassword)
+			ha2 := webdavMD5(r.Method + ":" + params["uri"])
+			expected := webdavMD5(strings.Join([]string{ha1, webdavNonce, params["nc"], params["cnonce"], params["qop"], ha2}, ":"))
+
+			if params["username"] == webdavUser && params["nonce"] == webdavNonce && params["response"] == expected {
+				next.ServeHTTP(w, r)
+
+				return
+			}
+		}
+
+		w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Digest realm="%s", nonce="%s", qop="auth"`, webdavRealm, webdavNonce))
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+}
+
+func webdavMD5(s string) string {
+	sum := md5.Sum([]byte(s))
+
+	return hex.EncodeToString(sum[:])
+}
+
+// newWebDAVServer serves a temporary directory with the webdav handler of x/net under /dav/
+func newWebDAVServer(t *testing.T, digest bool) string {
+	handler := &webdav.Handler{
+		Prefix:     "/dav",
+		FileSystem: webdav.Dir(t.TempDir()),
+		LockSystem: webdav.NewMemLS(),
+	}
+
+	server := httptest.NewServer(webdavAuth(digest, handler))
+	t.Cleanup(server.Close)
+
+	return server.URL + "/dav/"
+}
+
+func newWebDAV(t *testing.T) adapter.Adapter {
+	fs, err := NewWebDAV(WebDAVConfig{
+		URL:      newWebDAVServer(t, false),
+		User:     webdavUser,
+		Password: webdavPassword,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return fs
+}
+
+func TestWebDAV_Auth(t *testing.T) {
+	for _, digest := range []bool{false, true} {
+		url := newWebDAVServer(t, digest)
+
+		_, err := NewWebDAV(WebDAVConfig{URL: url, User: webdavUser, Password: "wrong"})
+		if !errors.Is(err, adapter.ErrPermissionDenied) {
+			t.Logf("digest=%v: expected ErrPermissionDenied, got %v", digest, err)
+			t.Fail()
+		}
+
+		fs, err := NewWebDAV(WebDAVConfig{URL: url, User: webdavUser, Password: webdavPassword})
+		if err != nil {
+			t.Logf("digest=%v: %v", digest, err)
+			t.Fail()
+
+			continue
+		}
+
+		err = fs.Write("sub/test.txt", []byte("hello"))
+		if err != nil {
+			t.Logf("digest=%v: %v", digest, err)
+			t.Fail()
+		}
+
+		contents, err := fs.Read("sub/test.txt")
+		if err != nil || string(contents) != "hello" {
+			t.Logf("digest=%v: files does not contain: hello", digest)
+			t.Fail()
+		}
+	}
+}
+
+func TestWebDAV_Write(t *testing.T) {
+	fs := newWebDAV(t)
+
+	err := fs.Write("sub/test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("sub/test.txt")
+	if err != nil || attributes.Size != 11 || attributes.Visibility != "" || !strings.HasPrefix(attributes.MimeType, "text/plain") {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Write("sub/test.txt", []byte("hello again"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("missing.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Update("sub/test.txt", []byte("hello update"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.SetVisibility("sub/test.txt", adapter.VisibilityPrivate)
+	if !errors.Is(err, errNoVisibility) {
+		t.Logf("expected errNoVisibility, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestWebDAV_Stream(t *testing.T) {
+	fs := newWebDAV(t)
+
+	contents := bytes.Repeat([]byte("0123456789"), 100*1024)
+
+	// A reader that can't seek is sent chunked
+	err := fs.WriteStream("stream.bin", io.MultiReader(bytes.NewReader(contents)))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("stream.bin")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer r.Close()
+
+	read, err := io.ReadAll(r)
+	if err != nil || !bytes.Equal(read, contents) {
+		t.Log("stream contents don't match")
+		t.Fail()
+	}
+
+	err = fs.WriteStream("stream.bin", bytes.NewReader(contents))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.ReadStream("missing.bin")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestWebDAV_Copy(t *testing.T) {
+	fs := newWebDAV(t)
+
+	for _, p := range []string{"test file.txt", "dir/a.txt", "dir/sub/b.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err := fs.Copy("test file.txt", "other/copy.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := fs.Read("other/copy.txt")
+	if err != nil || string(contents) != "test file.txt" {
+		t.Logf("unexpected contents of the copy: %q %v", contents, err)
+		t.Fail()
+	}
+
+	err = fs.Copy("missing.txt", "copy.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Rename("dir", "moved/dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err = fs.Read("moved/dir/sub/b.txt")
+	if err != nil || string(contents) != "dir/sub/b.txt" {
+		t.Logf("expected the directory contents to move, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.DirectoryExists("dir"); ok {
+		t.Log("expected dir to be gone")
+		t.Fail()
+	}
+
+	err = fs.Rename("test file.txt", "renamed.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.FileExists("renamed.txt"); !ok {
+		t.Log("expected renamed.txt to exist")
+		t.Fail()
+	}
+
+	err = fs.Rename("missing.txt", "other.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestWebDAV_Directories(t *testing.T) {
+	fs := newWebDAV(t)
+
+	err := fs.CreateDir("dir/empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("dir/empty")
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"a.txt", "dir/b.txt", "dir/sub/c.txt"} {
+		if err := fs.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir"}},
+		{"dir", false, []string{"dir/b.txt", "dir/empty", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "dir/b.txt", "dir/empty", "dir/sub", "dir/sub/c.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := adapter.Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err = fs.ListContents("missing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Delete("dir")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := fs.Has("dir/sub/c.txt"); ok {
+		t.Log("expected dir to be deleted")
+		t.Fail()
+	}
+
+	err = fs.DeleteDir("dir")
+	if err != nil {
+		t.Logf("expected deleting a missing directory to succeed, got %v", err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,197 @@
+package adapter
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// Zip reads files from a ZIP archive, it is read-only and every change fails with ErrReadOnly
+type Zip struct {
+	archive
+	reader *zip.ReadCloser
+}
+
+// NewZip opens a ZIP archive, call Close to close the archive file
+func NewZip(path string) (Adapter, error) {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return nil, wrapError("open", path, err)
+	}
+
+	a := &Zip{reader: reader}
+
+	a.archive = newArchive(func(entry *archiveEntry) (io.ReadCloser, error) {
+		return reader.File[entry.index].Open()
+	})
+
+	for i, f := range reader.File {
+		a.add(f.Name, f.FileInfo(), i)
+	}
+
+	return a, nil
+}
+
+// Close closes the archive file
+func (a *Zip) Close() error {
+	return a.reader.Close()
+}
+
+// WritableZip keeps the contents of a ZIP archive in memory and writes them back when it is closed.
+// Until then changes are only made in memory, so the archive is left as it was when Close is not called
+type WritableZip struct {
+	*Memory
+	path    string
+	permMap map[string]map[string]os.FileMode
+}
+
+// NewWritableZip reads a ZIP archive into memory, the archive is created on Close if it does not exist
+func NewWritableZip(path string) (Adapter, error) {
+	a := &WritableZip{
+		Memory:  NewMemory().(*Memory),
+		path:    path,
+		permMap: newPermMap(),
+	}
+
+	reader, err := zip.OpenReader(path)
+	if os.IsNotExist(err) {
+		return a, nil
+	}
+
+	if err != nil {
+		return nil, wrapError("open", path, err)
+	}
+
+	defer reader.Close()
+
+	for _, f := range reader.File {
+		err = a.load(f)
+		if err != nil {
+			return nil, wrapError("open", path, err)
+		}
+	}
+
+	return a, nil
+}
+
+// Close writes the archive, it replaces the archive file at once so a failed write leaves the old archive intact
+func (a *WritableZip) Close() error {
+	a.lock.RLock()
+
+	defer a.lock.RUnlock()
+
+	tmp, err := os.CreateTemp(filepath.Dir(a.path), ".flysystem-*.zip")
+	if err != nil {
+		return wrapError("close", a.path, err)
+	}
+
+	defer os.Remove(tmp.Name())
+
+	err = a.write(tmp)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(tmp.Name(), a.path)
+	}
+
+	return wrapError("close", a.path, err)
+}
+
+// load adds a file or directory of the archive, the lock doesn't have to be held while opening
+func (a *WritableZip) load(f *zip.File) error {
+	p := normalizePath(f.Name)
+	if p == "" {
+		return nil
+	}
+
+	info := f.FileInfo()
+
+	entry := &memoryEntry{
+		dir:          info.IsDir(),
+		visibility:   VisibilityPublic,
+		lastModified: f.Modified,
+	}
+
+	if info.Mode().Perm()&0077 == 0 && info.Mode().Perm() != 0 {
+		entry.visibility = VisibilityPrivate
+	}
+
+	if !entry.dir {
+		r, err := f.Open()
+		if err != nil {
+			return err
+		}
+
+		var buf bytes.Buffer
+
+		_, err = io.Copy(&buf, r)
+		r.Close()
+
+		if err != nil {
+			return err
+		}
+
+		entry.contents = buf.Bytes()
+	}
+
+	err := a.ensureParents("open", p)
+	if err != nil {
+		return err
+	}
+
+	a.entries[p] = entry
+
+	return nil
+}
+
+// write writes every entry to w in path order, the lock must be held
+func (a *WritableZip) write(w io.Writer) error {
+	paths := make([]string, 0, len(a.entries))
+	for p := range a.entries {
+		paths = append(paths, p)
+	}
+
+	sort.Strings(paths)
+
+	writer := zip.NewWriter(w)
+
+	for _, p := range paths {
+		entry := a.entries[p]
+
+		header := &zip.FileHeader{
+			Name:     p,
+			Method:   zip.Deflate,
+			Modified: entry.lastModified,
+		}
+
+		if entry.dir {
+			header.Name += "/"
+			header.Method = zip.Store
+			header.SetMode(os.ModeDir | a.permMap["dir"][entry.visibility])
+		} else {
+			header.SetMode(a.permMap["file"][entry.visibility])
+		}
+
+		if header.Modified.IsZero() {
+			header.Modified = time.Now()
+		}
+
+		fw, err := writer.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+
+		_, err = fw.Write(entry.contents)
+		if err != nil {
+			return err
+		}
+	}
+
+	return writer.Close()
+}
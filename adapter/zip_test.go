@@ -0,0 +1,327 @@
+package adapter
+
+import (
+	"archive/zip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newZipFile writes a ZIP archive with an explicit and an implicit directory and a private file
+func newZipFile(t *testing.T) string {
+	p := filepath.Join(t.TempDir(), "test.zip")
+
+	f, err := os.Create(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	w := zip.NewWriter(f)
+
+	entries := []struct {
+		name     string
+		mode     os.FileMode
+		contents string
+	}{
+		{"a.txt", 0644, "hello world"},
+		{"dir/", os.ModeDir | 0755, ""},
+		{"dir/b.txt", 0644, "dir/b.txt"},
+		{"dir/sub/c", 0600, "<html><body>private</body></html>"},
+		{"implicit/d.txt", 0644, "implicit/d.txt"},
+	}
+
+	for _, entry := range entries {
+		header := &zip.FileHeader{Name: entry.name, Method: zip.Deflate}
+		header.SetMode(entry.mode)
+
+		fw, err := w.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = io.WriteString(fw, entry.contents)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return p
+}
+
+func TestZip_Read(t *testing.T) {
+	fs, err := NewZip(newZipFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.(*Zip).Close()
+
+	contents, err := fs.Read("a.txt")
+	if err != nil || string(contents) != "hello world" {
+		t.Log("files does not contain: hello world")
+		t.Fail()
+	}
+
+	r, err := fs.ReadStream("/dir/b.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err = io.ReadAll(r)
+	r.Close()
+
+	if err != nil || string(contents) != "dir/b.txt" {
+		t.Logf("unexpected contents: %q %v", contents, err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("missing.txt")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("dir")
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound for a directory, got %v", err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("dir/sub/c")
+	if err != nil || attributes.Size != 33 || attributes.Visibility != VisibilityPrivate || !strings.HasPrefix(attributes.MimeType, "text/html") {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	for _, dir := range []string{"dir", "dir/sub", "implicit"} {
+		if ok, _ := fs.DirectoryExists(dir); !ok {
+			t.Logf("expected %s to be a directory", dir)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.FileExists("dir"); ok {
+		t.Log("expected dir not to be a file")
+		t.Fail()
+	}
+}
+
+func TestZip_ListContents(t *testing.T) {
+	fs, err := NewZip(newZipFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.(*Zip).Close()
+
+	tests := []struct {
+		dir      string
+		deep     bool
+		expected []string
+	}{
+		{"", false, []string{"a.txt", "dir", "implicit"}},
+		{"dir", false, []string{"dir/b.txt", "dir/sub"}},
+		{"", true, []string{"a.txt", "dir", "dir/b.txt", "dir/sub", "dir/sub/c", "implicit", "implicit/d.txt"}},
+	}
+
+	for _, test := range tests {
+		listing, err := fs.ListContents(test.dir, test.deep)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		contents, err := Collect(listing)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var paths []string
+		for _, attributes := range contents {
+			paths = append(paths, attributes.Path)
+		}
+
+		if strings.Join(paths, ",") != strings.Join(test.expected, ",") {
+			t.Logf("listing %q deep=%v: expected %v, got %v", test.dir, test.deep, test.expected, paths)
+			t.Fail()
+		}
+	}
+
+	_, err = fs.ListContents("a.txt", false)
+	if !errors.Is(err, ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestZip_ReadOnly(t *testing.T) {
+	fs, err := NewZip(newZipFile(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer fs.(*Zip).Close()
+
+	changes := map[string]error{
+		"write":      fs.Write("new.txt", []byte("hello")),
+		"update":     fs.Update("a.txt", []byte("hello")),
+		"put":        fs.Put("a.txt", []byte("hello")),
+		"stream":     fs.WriteStream("new.txt", strings.NewReader("hello")),
+		"rename":     fs.Rename("a.txt", "b.txt"),
+		"copy":       fs.Copy("a.txt", "b.txt"),
+		"delete":     fs.Delete("a.txt"),
+		"mkdir":      fs.CreateDir("new"),
+		"rmdir":      fs.DeleteDir("dir"),
+		"visibility": fs.SetVisibility("a.txt", VisibilityPrivate),
+	}
+
+	for name, err := range changes {
+		if !errors.Is(err, ErrReadOnly) {
+			t.Logf("%s: expected ErrReadOnly, got %v", name, err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := fs.Has("new.txt"); ok {
+		t.Log("expected new.txt not to exist")
+		t.Fail()
+	}
+}
+
+func TestZip_Missing(t *testing.T) {
+	_, err := NewZip(filepath.Join(t.TempDir(), "missing.zip"))
+	if !errors.Is(err, ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestWritableZip(t *testing.T) {
+	p := newZipFile(t)
+
+	fs, err := NewWritableZip(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	attributes, err := fs.Stat("dir/sub/c")
+	if err != nil || attributes.Visibility != VisibilityPrivate {
+		t.Logf("expected the visibility to be read from the archive, got %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	err = fs.Write("new/e.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Rename("implicit", "moved")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Delete("a.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.CreateDir("empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.(*WritableZip).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewZip(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reopened.(*Zip).Close()
+
+	listing, err := reopened.ListContents("", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := Collect(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, attributes := range contents {
+		paths = append(paths, attributes.Path)
+	}
+
+	expected := []string{"dir", "dir/b.txt", "dir/sub", "dir/sub/c", "empty", "moved", "moved/d.txt", "new", "new/e.txt"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Logf("expected %v, got %v", expected, paths)
+		t.Fail()
+	}
+
+	read, err := reopened.Read("new/e.txt")
+	if err != nil || string(read) != "hello" {
+		t.Logf("unexpected contents: %q %v", read, err)
+		t.Fail()
+	}
+
+	attributes, err = reopened.Stat("dir/sub/c")
+	if err != nil || attributes.Visibility != VisibilityPrivate {
+		t.Logf("expected the visibility to be written, got %+v %v", attributes, err)
+		t.Fail()
+	}
+}
+
+func TestWritableZip_Create(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "new.zip")
+
+	fs, err := NewWritableZip(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := os.Stat(p); !os.IsNotExist(err) {
+		t.Log("expected the archive not to be created before Close")
+		t.Fail()
+	}
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = fs.(*WritableZip).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewZip(p)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer reopened.(*Zip).Close()
+
+	contents, err := reopened.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("unexpected contents: %q %v", contents, err)
+		t.Fail()
+	}
+}
@@ -0,0 +1,91 @@
+package flysystem
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Result is the outcome of an operation on a single adapter.
+// In transactional mode RolledBack reports whether a successful change was undone
+// and RollbackErr why undoing it failed
+type Result struct {
+	Index       int
+	Name        string
+	Err         error
+	RolledBack  bool
+	RollbackErr error
+}
+
+// MultiError is returned when an operation failed on one or more adapters.
+// It lists the result of every adapter involved so it is clear which replicas are behind,
+// adapters that were cancelled because another one failed report context.Canceled
+type MultiError struct {
+	Results []Result
+}
+
+// Error returns the error message
+func (e *MultiError) Error() string {
+	failed := e.Failed()
+	messages := make([]string, 0, len(failed))
+
+	for _, r := range failed {
+		messages = append(messages, fmt.Sprintf("adapter #%d (%s): %v", r.Index, r.Name, r.Err))
+	}
+
+	for _, r := range e.Results {
+		if r.RollbackErr != nil {
+			messages = append(messages, fmt.Sprintf("adapter #%d (%s): rollback failed: %v", r.Index, r.Name, r.RollbackErr))
+		}
+	}
+
+	return fmt.Sprintf("%d of %d adapters failed: %s", len(failed), len(e.Results), strings.Join(messages, "; "))
+}
+
+// Failed returns the results of the adapters that failed
+func (e *MultiError) Failed() []Result {
+	var failed []Result
+
+	for _, r := range e.Results {
+		if r.Err != nil {
+			failed = append(failed, r)
+		}
+	}
+
+	return failed
+}
+
+// Succeeded returns the results of the adapters that succeeded
+func (e *MultiError) Succeeded() []Result {
+	var succeeded []Result
+
+	for _, r := range e.Results {
+		if r.Err == nil {
+			succeeded = append(succeeded, r)
+		}
+	}
+
+	return succeeded
+}
+
+// Is reports whether any of the adapter errors matches target
+func (e *MultiError) Is(target error) bool {
+	for _, r := range e.Results {
+		if r.Err != nil && errors.Is(r.Err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first adapter error that matches target
+func (e *MultiError) As(target interface{}) bool {
+	for _, r := range e.Results {
+		if r.Err != nil && errors.As(r.Err, target) {
+			return true
+		}
+	}
+
+	return false
+}
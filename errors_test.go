@@ -0,0 +1,52 @@
+package flysystem
+
+import (
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	cause := &adapter.Error{Op: "write", Path: "test.txt", Kind: adapter.ErrFileExists}
+	err := error(&MultiError{Results: []Result{
+		{Index: 0, Name: "primary"},
+		{Index: 1, Name: "replica", Err: cause},
+	}})
+
+	if err.Error() != "1 of 2 adapters failed: adapter #1 (replica): write test.txt: file already exists" {
+		t.Logf("unexpected message: %s", err.Error())
+		t.Fail()
+	}
+
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Log("expected ErrFileExists")
+		t.Fail()
+	}
+
+	if errors.Is(err, adapter.ErrFileNotFound) {
+		t.Log("unexpected ErrFileNotFound")
+		t.Fail()
+	}
+
+	var e *adapter.Error
+	if !errors.As(err, &e) || e != cause {
+		t.Log("expected the adapter error")
+		t.Fail()
+	}
+
+	var m *MultiError
+	if !errors.As(err, &m) {
+		t.Log("expected a MultiError")
+		t.FailNow()
+	}
+
+	if len(m.Failed()) != 1 || m.Failed()[0].Name != "replica" {
+		t.Logf("unexpected failed results: %v", m.Failed())
+		t.Fail()
+	}
+
+	if len(m.Succeeded()) != 1 || m.Succeeded()[0].Name != "primary" {
+		t.Logf("unexpected succeeded results: %v", m.Succeeded())
+		t.Fail()
+	}
+}
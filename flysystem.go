@@ -1,119 +1,723 @@
 package flysystem
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/edwin-luijten/go_flysystem/adapter"
 	"golang.org/x/sync/errgroup"
+	"io"
 	"sync"
 )
 
 // Flysystem ...
 type Flysystem struct {
 	sync.Mutex
-	wg       *sync.WaitGroup
-	adapters []adapter.Adapter
+	wg            *sync.WaitGroup
+	adapters      []adapter.ContextAdapter
+	names         []string
+	transactional bool
+	readOnly      bool
+	readStrategy  ReadStrategy
+	writeQuorum   int
+	readQuorum    int
+	lagging       map[int]Result
+}
+
+// Option configures a Flysystem
+type Option func(f *Flysystem)
+
+// Transactional undoes the changes of Write, WriteStream, Update, Put, Rename, Copy, Delete, CreateDir
+// and SetVisibility on every adapter that succeeded when another adapter failed.
+// Files are read into memory before they are overwritten or deleted so they can be restored,
+// DeleteDir is not undone
+func Transactional() Option {
+	return func(f *Flysystem) {
+		f.transactional = true
+	}
+}
+
+// ReadOnly makes every change fail with adapter.ErrReadOnly before it reaches any adapter
+func ReadOnly() Option {
+	return func(f *Flysystem) {
+		f.readOnly = true
+	}
 }
 
 // New creates a new instance with given adapters
 func New(adapters ...adapter.Adapter) *Flysystem {
-	return &Flysystem{
-		adapters: adapters,
-		wg:       &sync.WaitGroup{},
+	return NewWithOptions(adapters)
+}
+
+// NewWithOptions creates a new instance with given adapters and options
+func NewWithOptions(adapters []adapter.Adapter, options ...Option) *Flysystem {
+	f := &Flysystem{
+		adapters:     make([]adapter.ContextAdapter, len(adapters)),
+		names:        make([]string, len(adapters)),
+		wg:           &sync.WaitGroup{},
+		readStrategy: ReadFallback,
+	}
+
+	for i, a := range adapters {
+		f.adapters[i] = adapter.WithContext(a)
+		f.names[i] = fmt.Sprintf("%T", a)
 	}
+
+	for _, option := range options {
+		option(f)
+	}
+
+	return f
 }
 
-// Write a new file
+// Write a new file, it fails without writing anything if the file exists on any adapter
 func (f *Flysystem) Write(path string, contents []byte) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.Write(path, contents)
+	return f.WriteContext(context.Background(), path, contents)
+}
+
+// WriteContext writes a new file
+func (f *Flysystem) WriteContext(ctx context.Context, path string, contents []byte) error {
+	if err := f.assertWritable("write", path); err != nil {
+		return err
+	}
+
+	if err := f.assertAbsent(ctx, "write", path); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		return func(ctx context.Context) error {
+			return a.DeleteContext(ctx, path)
+		}, a.WriteContext(ctx, path, contents)
 	})
 }
 
-// Update a file
+// Update a file, it fails without writing anything if the file is missing on any adapter
 func (f *Flysystem) Update(path string, contents []byte) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.Update(path, contents)
+	return f.UpdateContext(context.Background(), path, contents)
+}
+
+// UpdateContext updates a file
+func (f *Flysystem) UpdateContext(ctx context.Context, path string, contents []byte) error {
+	if err := f.assertWritable("update", path); err != nil {
+		return err
+	}
+
+	if err := f.assertPresent(ctx, "update", path); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		s, err := f.snapshot(ctx, a, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.restore(a, path), a.UpdateContext(ctx, path, contents)
+	})
+}
+
+// Put writes a file, creating or overwriting it
+func (f *Flysystem) Put(path string, contents []byte) error {
+	return f.PutContext(context.Background(), path, contents)
+}
+
+// PutContext writes a file, creating or overwriting it
+func (f *Flysystem) PutContext(ctx context.Context, path string, contents []byte) error {
+	if err := f.assertWritable("put", path); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		s, err := f.snapshot(ctx, a, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.restore(a, path), a.PutContext(ctx, path, contents)
 	})
 }
 
 // Read a file
 func (f *Flysystem) Read(path string) ([]byte, error) {
-	var g errgroup.Group
-	contents := make([][]byte, len(f.adapters))
+	return f.ReadContext(context.Background(), path)
+}
+
+// ReadContext reads a file
+func (f *Flysystem) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	if f.readQuorum > 1 {
+		return f.readQuorumContents(ctx, path)
+	}
+
+	contents, err := f.read(ctx, func(ctx context.Context, a adapter.ContextAdapter) (interface{}, error) {
+		return a.ReadContext(ctx, path)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return contents.([]byte), nil
+}
+
+// WriteStream writes a new file from a stream, it fails without writing anything if the file exists on any adapter.
+// The source is read once and teed to every adapter without buffering the whole payload
+func (f *Flysystem) WriteStream(path string, contents io.Reader) error {
+	return f.WriteStreamContext(context.Background(), path, contents)
+}
+
+// WriteStreamContext writes a new file from a stream
+func (f *Flysystem) WriteStreamContext(ctx context.Context, path string, contents io.Reader) error {
+	if err := f.assertWritable("write", path); err != nil {
+		return err
+	}
+
+	if err := f.assertAbsent(ctx, "write", path); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	g, ctx := f.group(ctx)
+	errs := make([]error, len(f.adapters))
+	readers := make([]*io.PipeReader, len(f.adapters))
+	tee := &teeWriter{
+		writers: make([]*io.PipeWriter, len(f.adapters)),
+		failed:  make([]bool, len(f.adapters)),
+	}
 
 	for i, a := range f.adapters {
 		i, a := i, a
+		r, w := io.Pipe()
+		readers[i] = r
+		tee.writers[i] = w
+
 		g.Go(func() error {
-			bytes, err := a.Read(path)
+			errs[i] = a.WriteStreamContext(ctx, path, r)
 
-			if err == nil {
-				contents[i] = bytes
-			}
+			// Unblock the tee if the adapter stopped reading early
+			r.CloseWithError(errs[i])
 
-			return err
+			return errs[i]
 		})
 	}
 
-	if err := g.Wait(); err != nil {
+	// Unblock the tee if an adapter hangs while the context is cancelled
+	go func() {
+		<-ctx.Done()
+
+		for _, r := range readers {
+			r.CloseWithError(ctx.Err())
+		}
+	}()
+
+	_, err := io.Copy(tee, contents)
+
+	for _, w := range tee.writers {
+		w.CloseWithError(err)
+	}
+
+	g.Wait()
+
+	undos := make([]undoFunc, len(f.adapters))
+
+	for i, a := range f.adapters {
+		a := a
+		undos[i] = func(ctx context.Context) error {
+			return a.DeleteContext(ctx, path)
+		}
+	}
+
+	if wErr := f.settle(errs, undos); wErr != nil {
+		return wErr
+	}
+
+	return err
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (f *Flysystem) ReadStream(path string) (io.ReadCloser, error) {
+	return f.ReadStreamContext(context.Background(), path)
+}
+
+// ReadStreamContext opens a file for reading, the caller must close it
+func (f *Flysystem) ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	stream, err := f.read(ctx, func(ctx context.Context, a adapter.ContextAdapter) (interface{}, error) {
+		return a.ReadStreamContext(ctx, path)
+	})
+	if err != nil {
 		return nil, err
 	}
 
-	return contents[0], nil
+	return stream.(io.ReadCloser), nil
 }
 
 // Rename a file
 func (f *Flysystem) Rename(path string, newPath string) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.Rename(path, newPath)
+	return f.RenameContext(context.Background(), path, newPath)
+}
+
+// RenameContext renames a file
+func (f *Flysystem) RenameContext(ctx context.Context, path string, newPath string) error {
+	if err := f.assertWritable("rename", path); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		s, err := f.snapshot(ctx, a, newPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return func(ctx context.Context) error {
+			if err := a.RenameContext(ctx, newPath, path); err != nil {
+				return err
+			}
+
+			if !s.exists {
+				return nil
+			}
+
+			return s.restore(a, newPath)(ctx)
+		}, a.RenameContext(ctx, path, newPath)
 	})
 }
 
 // Copy a file
 func (f *Flysystem) Copy(path string, newPath string) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.Copy(path, newPath)
+	return f.CopyContext(context.Background(), path, newPath)
+}
+
+// CopyContext copies a file
+func (f *Flysystem) CopyContext(ctx context.Context, path string, newPath string) error {
+	if err := f.assertWritable("copy", path); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		s, err := f.snapshot(ctx, a, newPath)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.restore(a, newPath), a.CopyContext(ctx, path, newPath)
 	})
 }
 
 // Delete a file
 func (f *Flysystem) Delete(path string) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.Delete(path)
+	return f.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext deletes a file
+func (f *Flysystem) DeleteContext(ctx context.Context, path string) error {
+	if err := f.assertWritable("delete", path); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		s, err := f.snapshot(ctx, a, path)
+		if err != nil {
+			return nil, err
+		}
+
+		return s.restore(a, path), a.DeleteContext(ctx, path)
 	})
 }
 
 // CreateDir creates a directory
 func (f *Flysystem) CreateDir(dir string) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.CreateDir(dir)
+	return f.CreateDirContext(context.Background(), dir)
+}
+
+// CreateDirContext creates a directory
+func (f *Flysystem) CreateDirContext(ctx context.Context, dir string) error {
+	if err := f.assertWritable("mkdir", dir); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		return func(ctx context.Context) error {
+			return a.DeleteDirContext(ctx, dir)
+		}, a.CreateDirContext(ctx, dir)
 	})
 }
 
 // DeleteDir deletes a directory
 func (f *Flysystem) DeleteDir(dir string) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.DeleteDir(dir)
+	return f.DeleteDirContext(context.Background(), dir)
+}
+
+// DeleteDirContext deletes a directory
+func (f *Flysystem) DeleteDirContext(ctx context.Context, dir string) error {
+	if err := f.assertWritable("rmdir", dir); err != nil {
+		return err
+	}
+
+	return f.runSync(ctx, func(ctx context.Context, a adapter.ContextAdapter) error {
+		return a.DeleteDirContext(ctx, dir)
 	})
 }
 
 // SetVisibility sets a file or directory to public or private
 func (f *Flysystem) SetVisibility(path string, visibility string) error {
-	return f.runSync(func(a adapter.Adapter) error {
-		return a.SetVisibility(path, visibility)
+	return f.SetVisibilityContext(context.Background(), path, visibility)
+}
+
+// SetVisibilityContext sets a file or directory to public or private
+func (f *Flysystem) SetVisibilityContext(ctx context.Context, path string, visibility string) error {
+	if err := f.assertWritable("chmod", path); err != nil {
+		return err
+	}
+
+	return f.runTransaction(ctx, func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error) {
+		var previous adapter.FileAttributes
+
+		if f.transactional {
+			attributes, err := a.StatContext(ctx, path)
+			if err != nil {
+				return nil, err
+			}
+
+			previous = attributes
+		}
+
+		return func(ctx context.Context) error {
+			return a.SetVisibilityContext(ctx, path, previous.Visibility)
+		}, a.SetVisibilityContext(ctx, path, visibility)
 	})
 }
 
-func (f *Flysystem) runSync(action func(a adapter.Adapter) error) error {
-	var g errgroup.Group
+// Has checks if a file or directory exists on every adapter
+func (f *Flysystem) Has(path string) (bool, error) {
+	return f.HasContext(context.Background(), path)
+}
 
-	for _, a := range f.adapters {
-		a := a
+// HasContext checks if a file or directory exists on every adapter
+func (f *Flysystem) HasContext(ctx context.Context, path string) (bool, error) {
+	return f.exists(ctx, func(ctx context.Context, a adapter.ContextAdapter) (bool, error) {
+		return a.HasContext(ctx, path)
+	})
+}
 
-		g.Go(func() error {
-			err := action(a)
+// FileExists checks if a file exists on every adapter
+func (f *Flysystem) FileExists(path string) (bool, error) {
+	return f.FileExistsContext(context.Background(), path)
+}
+
+// FileExistsContext checks if a file exists on every adapter
+func (f *Flysystem) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	return f.exists(ctx, func(ctx context.Context, a adapter.ContextAdapter) (bool, error) {
+		return a.FileExistsContext(ctx, path)
+	})
+}
+
+// DirectoryExists checks if a directory exists on every adapter
+func (f *Flysystem) DirectoryExists(dir string) (bool, error) {
+	return f.DirectoryExistsContext(context.Background(), dir)
+}
 
+// DirectoryExistsContext checks if a directory exists on every adapter
+func (f *Flysystem) DirectoryExistsContext(ctx context.Context, dir string) (bool, error) {
+	return f.exists(ctx, func(ctx context.Context, a adapter.ContextAdapter) (bool, error) {
+		return a.DirectoryExistsContext(ctx, dir)
+	})
+}
+
+// Stat returns the attributes of a file or directory
+func (f *Flysystem) Stat(path string) (adapter.FileAttributes, error) {
+	return f.StatContext(context.Background(), path)
+}
+
+// StatContext returns the attributes of a file or directory
+func (f *Flysystem) StatContext(ctx context.Context, path string) (adapter.FileAttributes, error) {
+	attributes, err := f.read(ctx, func(ctx context.Context, a adapter.ContextAdapter) (interface{}, error) {
+		return a.StatContext(ctx, path)
+	})
+	if err != nil {
+		return adapter.FileAttributes{}, err
+	}
+
+	return attributes.(adapter.FileAttributes), nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories
+func (f *Flysystem) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	return f.ListContentsContext(context.Background(), dir, deep)
+}
+
+// ListContentsContext lists the contents of a directory
+func (f *Flysystem) ListContentsContext(ctx context.Context, dir string, deep bool) (adapter.DirectoryListing, error) {
+	listing, err := f.read(ctx, func(ctx context.Context, a adapter.ContextAdapter) (interface{}, error) {
+		return a.ListContentsContext(ctx, dir, deep)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return listing.(adapter.DirectoryListing), nil
+}
+
+// read serves a read through the read strategy.
+// Only the first successful result is kept, results of adapters that lost a race are closed
+func (f *Flysystem) read(ctx context.Context, action func(ctx context.Context, a adapter.ContextAdapter) (interface{}, error)) (interface{}, error) {
+	var mu sync.Mutex
+	var result interface{}
+	claimed := false
+
+	_, errs := f.readStrategy.Read(ctx, f.adapters, func(ctx context.Context, i int, a adapter.ContextAdapter) error {
+		r, err := action(ctx, a)
+		if err != nil {
 			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		if claimed {
+			if c, ok := r.(io.Closer); ok {
+				c.Close()
+			}
+
+			return errLostRace
+		}
+
+		claimed = true
+		result = r
+
+		// A stream or listing is read from after the read returned, its context ends when it is closed
+		switch v := r.(type) {
+		case io.ReadCloser:
+			result = &releasingReadCloser{ReadCloser: v, release: keepReading(ctx)}
+		case adapter.DirectoryListing:
+			result = &releasingListing{DirectoryListing: v, release: keepReading(ctx)}
+		}
+
+		return nil
+	})
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if !claimed {
+		return nil, f.collectAttempted(errs)
+	}
+
+	return result, nil
+}
+
+// releasingReadCloser ends the context of the read that opened it when it is closed
+type releasingReadCloser struct {
+	io.ReadCloser
+	release context.CancelFunc
+}
+
+// Close closes the stream and ends its context
+func (r *releasingReadCloser) Close() error {
+	defer r.release()
+
+	return r.ReadCloser.Close()
+}
+
+// releasingListing ends the context of the read that listed it when it is closed
+type releasingListing struct {
+	adapter.DirectoryListing
+	release context.CancelFunc
+}
+
+// Close closes the listing and ends its context
+func (l *releasingListing) Close() error {
+	defer l.release()
+
+	return l.DirectoryListing.Close()
+}
+
+// assertWritable fails with adapter.ErrReadOnly when the instance is read-only
+func (f *Flysystem) assertWritable(op string, path string) error {
+	if f.readOnly {
+		return &adapter.Error{Op: op, Path: path, Kind: adapter.ErrReadOnly}
+	}
+
+	return nil
+}
+
+// assertAbsent fails with adapter.ErrFileExists when path exists on so many adapters the write can't succeed
+func (f *Flysystem) assertAbsent(ctx context.Context, op string, path string) error {
+	present, answered, err := f.count(ctx, func(ctx context.Context, a adapter.ContextAdapter) (bool, error) {
+		return a.HasContext(ctx, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	if answered-present < f.required() {
+		return &adapter.Error{Op: op, Path: path, Kind: adapter.ErrFileExists}
+	}
+
+	return nil
+}
+
+// assertPresent fails with adapter.ErrFileNotFound when path is missing on so many adapters the write can't succeed
+func (f *Flysystem) assertPresent(ctx context.Context, op string, path string) error {
+	ok, err := f.exists(ctx, func(ctx context.Context, a adapter.ContextAdapter) (bool, error) {
+		return a.FileExistsContext(ctx, path)
+	})
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return &adapter.Error{Op: op, Path: path, Kind: adapter.ErrFileNotFound}
+	}
+
+	return nil
+}
+
+// exists reports whether check holds on the required number of adapters
+func (f *Flysystem) exists(ctx context.Context, check func(ctx context.Context, a adapter.ContextAdapter) (bool, error)) (bool, error) {
+	present, _, err := f.count(ctx, check)
+	if err != nil {
+		return false, err
+	}
+
+	return present >= f.required(), nil
+}
+
+// count runs check on every adapter and counts how many adapters answered and how many of them said yes.
+// It fails when fewer adapters than required answered
+func (f *Flysystem) count(ctx context.Context, check func(ctx context.Context, a adapter.ContextAdapter) (bool, error)) (int, int, error) {
+	results := make([]bool, len(f.adapters))
+
+	errs := f.fanOut(ctx, func(ctx context.Context, i int, a adapter.ContextAdapter) error {
+		ok, err := check(ctx, a)
+
+		if err == nil {
+			results[i] = ok
+		}
+
+		return err
+	})
+
+	present, answered := 0, 0
+
+	for i, ok := range results {
+		if errs[i] != nil {
+			continue
+		}
+
+		answered++
+
+		if ok {
+			present++
+		}
+	}
+
+	if answered < f.required() {
+		return 0, 0, f.collect(errs)
+	}
+
+	return present, answered, nil
+}
+
+func (f *Flysystem) runSync(ctx context.Context, action func(ctx context.Context, a adapter.ContextAdapter) error) error {
+	errs := f.fanOut(ctx, func(ctx context.Context, i int, a adapter.ContextAdapter) error {
+		return action(ctx, a)
+	})
+
+	return f.settle(errs, nil)
+}
+
+// fanOut runs action on every adapter concurrently and returns the error of every adapter
+func (f *Flysystem) fanOut(ctx context.Context, action func(ctx context.Context, i int, a adapter.ContextAdapter) error) []error {
+	g, ctx := f.group(ctx)
+	errs := make([]error, len(f.adapters))
+
+	for i, a := range f.adapters {
+		i, a := i, a
+
+		g.Go(func() error {
+			errs[i] = action(ctx, i, a)
+
+			return errs[i]
 		})
 	}
 
-	return g.Wait()
+	g.Wait()
+
+	return errs
+}
+
+// group returns an errgroup that cancels the other adapters once one fails,
+// unless the write quorum allows adapters to fail
+func (f *Flysystem) group(ctx context.Context) (*errgroup.Group, context.Context) {
+	if f.required() < len(f.adapters) {
+		return &errgroup.Group{}, ctx
+	}
+
+	return errgroup.WithContext(ctx)
+}
+
+// collectAttempted returns a MultiError listing only the adapters that have an error
+func (f *Flysystem) collectAttempted(errs []error) error {
+	var results []Result
+
+	for i, err := range errs {
+		if err != nil {
+			results = append(results, Result{Index: i, Name: f.names[i], Err: err})
+		}
+	}
+
+	return &MultiError{Results: results}
+}
+
+// collect returns a MultiError when any of the adapters failed
+func (f *Flysystem) collect(errs []error) error {
+	failed := false
+	results := make([]Result, len(errs))
+
+	for i, err := range errs {
+		results[i] = Result{Index: i, Name: f.names[i], Err: err}
+		failed = failed || err != nil
+	}
+
+	if !failed {
+		return nil
+	}
+
+	return &MultiError{Results: results}
+}
+
+var errNoWriters = errors.New("every adapter stopped reading the stream")
+
+var errLostRace = errors.New("another adapter served the read first")
+
+// teeWriter writes to every pipe, dropping the pipes of adapters that failed
+type teeWriter struct {
+	writers []*io.PipeWriter
+	failed  []bool
+}
+
+// Write writes p to every remaining pipe
+func (t *teeWriter) Write(p []byte) (int, error) {
+	alive := 0
+
+	for i, w := range t.writers {
+		if t.failed[i] {
+			continue
+		}
+
+		if _, err := w.Write(p); err != nil {
+			t.failed[i] = true
+
+			continue
+		}
+
+		alive++
+	}
+
+	if alive == 0 {
+		return 0, errNoWriters
+	}
+
+	return len(p), nil
 }
@@ -1,11 +1,15 @@
 package flysystem
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"github.com/edwin-luijten/go_flysystem/adapter"
-	"io/ioutil"
+	"io"
 	"os"
 	"testing"
+	"testing/iotest"
 )
 
 func setup(t *testing.T) {
@@ -64,6 +68,23 @@ func TestFlysystem_Write(t *testing.T) {
 		t.Fail()
 	}
 
+	err = a.Write("exists.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Write("exists.txt", []byte("hello"))
+	if !errors.Is(err, adapter.ErrFileExists) {
+		t.Logf("expected ErrFileExists, got %v", err)
+		t.Fail()
+	}
+
+	if _, err := os.Stat("./_testdata/sub2/exists.txt"); !os.IsNotExist(err) {
+		t.Log("expected exists.txt not to be written to the other adapter")
+		t.Fail()
+	}
+
 	os.Mkdir("./_testdata/sub1/err", 0644)
 	os.Mkdir("./_testdata/sub2/err", 0644)
 
@@ -104,7 +125,7 @@ func TestFlysystem_Update(t *testing.T) {
 		t.Fail()
 	}
 
-	bytes, err := ioutil.ReadFile("./_testdata/sub1/test.txt")
+	bytes, err := os.ReadFile("./_testdata/sub1/test.txt")
 	if err != nil {
 		t.Log(err)
 		t.Fail()
@@ -115,7 +136,7 @@ func TestFlysystem_Update(t *testing.T) {
 		t.Fail()
 	}
 
-	bytes, err = ioutil.ReadFile("./_testdata/sub2/test.txt")
+	bytes, err = os.ReadFile("./_testdata/sub2/test.txt")
 	if err != nil {
 		t.Log(err)
 		t.Fail()
@@ -125,6 +146,68 @@ func TestFlysystem_Update(t *testing.T) {
 		t.Log("files does not contain: hello")
 		t.Fail()
 	}
+
+	err = b.Write("missing.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("missing.txt", []byte("hello world"))
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	bytes, err = os.ReadFile("./_testdata/sub2/missing.txt")
+	if err != nil || string(bytes) != "hello" {
+		t.Log("expected missing.txt not to be updated on the other adapter")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_Put(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	err = a.Write("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Put("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"./_testdata/sub1/test.txt", "./_testdata/sub2/test.txt"} {
+		bytes, err := os.ReadFile(p)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		if string(bytes) != "hello" {
+			t.Log("files does not contain: hello")
+			t.Fail()
+		}
+	}
 }
 
 func TestFlysystem_Read(t *testing.T) {
@@ -435,3 +518,416 @@ func TestFlysystem_SetVisibility(t *testing.T) {
 		t.Log(fmt.Println("wrong permissions: expected %i, got %i", adapter.FilePrivate, info.Mode()))
 	}
 }
+
+func TestFlysystem_Has(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	ok, err := fs.Has("test.txt")
+	if err != nil || !ok {
+		t.Log("expected test.txt to exist")
+		t.Fail()
+	}
+
+	ok, err = fs.FileExists("test.txt")
+	if err != nil || !ok {
+		t.Log("expected test.txt to be a file")
+		t.Fail()
+	}
+
+	ok, err = fs.DirectoryExists("test.txt")
+	if err != nil || ok {
+		t.Log("expected test.txt not to be a directory")
+		t.Fail()
+	}
+
+	err = a.Delete("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	ok, err = fs.Has("test.txt")
+	if err != nil || ok {
+		t.Log("expected test.txt to be missing on one adapter")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_Stat(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if !attributes.IsFile() || attributes.Size != 5 {
+		t.Logf("unexpected attributes: %+v", attributes)
+		t.Fail()
+	}
+
+	_, err = fs.Stat("non-existing.txt")
+	if err == nil {
+		t.Log("expected an error: non existing file")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_ListContents(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	err = fs.Write("dir/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	listing, err := fs.ListContents("", true)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := adapter.Collect(listing)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if len(contents) != 2 {
+		t.Logf("expected 2 entries, got %d", len(contents))
+		t.Fail()
+	}
+}
+
+func TestFlysystem_WriteStream(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	payload := bytes.Repeat([]byte("hello"), 100000)
+
+	err = fs.WriteStream("test.txt", bytes.NewReader(payload))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"./_testdata/sub1/test.txt", "./_testdata/sub2/test.txt"} {
+		contents, err := os.ReadFile(p)
+		if err != nil {
+			t.Log(err)
+			t.Fail()
+		}
+
+		if !bytes.Equal(contents, payload) {
+			t.Logf("%s does not contain the payload", p)
+			t.Fail()
+		}
+	}
+
+	err = fs.WriteStream("broken.txt", iotest.ErrReader(errors.New("broken")))
+	if err == nil {
+		t.Log("expected an error: broken reader")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_ReadStream(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	stream, err := fs.ReadStream("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	defer stream.Close()
+
+	contents, err := io.ReadAll(stream)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if string(contents) != "hello" {
+		t.Log("files does not contain: hello")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_WriteContext(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	err = fs.WriteContext(context.Background(), "test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = fs.WriteContext(ctx, "cancelled.txt", []byte("hello"))
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	if _, err := os.Stat("./_testdata/sub1/cancelled.txt"); !os.IsNotExist(err) {
+		t.Log("expected cancelled.txt not to be written")
+		t.Fail()
+	}
+
+	_, err = fs.ReadContext(ctx, "test.txt")
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.WriteStreamContext(ctx, "cancelled.txt", bytes.NewReader([]byte("hello")))
+	if !errors.Is(err, context.Canceled) {
+		t.Logf("expected context.Canceled, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFlysystem_Errors(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	_, err = fs.Read("non-existing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = fs.Rename("non-existing.txt", "test.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.ListContents("non-existing", false)
+	if !errors.Is(err, adapter.ErrDirectoryNotFound) {
+		t.Logf("expected ErrDirectoryNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFlysystem_MultiError(t *testing.T) {
+	setup(t)
+	defer teardown(t)
+
+	a, err := adapter.NewLocal("./_testdata/sub1")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	b, err := adapter.NewLocal("./_testdata/sub2")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := New(b, a)
+
+	err = b.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Delete("test.txt")
+
+	var m *MultiError
+	if !errors.As(err, &m) {
+		t.Logf("expected a MultiError, got %v", err)
+		t.FailNow()
+	}
+
+	if len(m.Results) != 2 || m.Results[1].Name != "*adapter.Local" || !errors.Is(m.Results[1].Err, adapter.ErrFileNotFound) {
+		t.Logf("unexpected results: %v", m.Results)
+		t.Fail()
+	}
+
+	// The other adapter either finished or was cancelled once the first one failed
+	if m.Results[0].Err != nil && !errors.Is(m.Results[0].Err, context.Canceled) {
+		t.Logf("unexpected result: %v", m.Results[0])
+		t.Fail()
+	}
+
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestFlysystem_ReadOnly(t *testing.T) {
+	a := adapter.NewMemory()
+	b := adapter.NewMemory()
+
+	for _, fs := range []adapter.Adapter{a, b} {
+		if err := fs.Write("test.txt", []byte("hello")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	fs := NewWithOptions([]adapter.Adapter{a, b}, ReadOnly(), Transactional())
+
+	contents, err := fs.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("files does not contain: hello, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	errs := []error{
+		fs.Write("new.txt", []byte("hello")),
+		fs.Update("test.txt", []byte("hello")),
+		fs.Put("test.txt", []byte("hello")),
+		fs.WriteStream("new.txt", bytes.NewReader([]byte("hello"))),
+		fs.Rename("test.txt", "b.txt"),
+		fs.Copy("test.txt", "b.txt"),
+		fs.Delete("test.txt"),
+		fs.CreateDir("new"),
+		fs.DeleteDir("dir"),
+		fs.SetVisibility("test.txt", adapter.VisibilityPrivate),
+	}
+
+	for i, err := range errs {
+		var m *MultiError
+		if !errors.Is(err, adapter.ErrReadOnly) || errors.As(err, &m) {
+			t.Logf("#%d: expected a single ErrReadOnly, got %v", i, err)
+			t.Fail()
+		}
+	}
+
+	for _, fs := range []adapter.Adapter{a, b} {
+		if ok, _ := fs.Has("test.txt"); !ok {
+			t.Log("expected test.txt to be left alone")
+			t.Fail()
+		}
+	}
+}
@@ -0,0 +1,390 @@
+package flysystem
+
+import (
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// ioFS exposes an adapter as an fs.FS
+type ioFS struct {
+	adapter adapter.Adapter
+}
+
+// AsFS exposes an adapter, or a Flysystem, as a read-only fs.FS so it can be used with the standard library,
+// such as http.FS, template.ParseFS and fs.WalkDir. The returned fs.FS implements fs.ReadFileFS, fs.ReadDirFS and fs.StatFS.
+// Errors of the adapter match fs.ErrNotExist, fs.ErrExist and fs.ErrPermission, the Sys method of a
+// file info returns its adapter.FileAttributes
+func AsFS(a adapter.Adapter) fs.FS {
+	return &ioFS{adapter: a}
+}
+
+// Open opens a file or directory
+func (f *ioFS) Open(name string) (fs.File, error) {
+	info, err := f.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.IsDir() {
+		return &ioDir{fsys: f, name: name, info: info}, nil
+	}
+
+	return &ioFile{fsys: f, name: name, info: info}, nil
+}
+
+// ReadFile reads a file
+func (f *ioFS) ReadFile(name string) ([]byte, error) {
+	if !validName(name) {
+		return nil, &fs.PathError{Op: "readfile", Path: name, Err: fs.ErrInvalid}
+	}
+
+	contents, err := f.adapter.Read(name)
+	if err != nil {
+		return nil, pathError("readfile", name, err)
+	}
+
+	return contents, nil
+}
+
+// ReadDir reads a directory, the entries are sorted by name
+func (f *ioFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !validName(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+
+	listing, err := f.adapter.ListContents(dirPath(name), false)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	contents, err := adapter.Collect(listing)
+	if err != nil {
+		return nil, pathError("readdir", name, err)
+	}
+
+	entries := make([]fs.DirEntry, len(contents))
+	for i, attributes := range contents {
+		entries[i] = fileInfo{attributes}
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+
+	return entries, nil
+}
+
+// Stat returns the file info of a file or directory
+func (f *ioFS) Stat(name string) (fs.FileInfo, error) {
+	return f.stat("stat", name)
+}
+
+func (f *ioFS) stat(op string, name string) (fs.FileInfo, error) {
+	if !validName(name) {
+		return nil, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+
+	// Not every adapter can stat its root
+	if name == "." {
+		return fileInfo{adapter.FileAttributes{Type: adapter.TypeDir, Visibility: adapter.VisibilityPublic}}, nil
+	}
+
+	attributes, err := f.adapter.Stat(name)
+	if err != nil {
+		return nil, pathError(op, name, err)
+	}
+
+	return fileInfo{attributes}, nil
+}
+
+// ioFile is a file opened through AsFS, the contents are streamed from the adapter when it is first read
+type ioFile struct {
+	fsys   *ioFS
+	name   string
+	info   fs.FileInfo
+	r      io.ReadCloser
+	pos    int64
+	offset int64
+	closed bool
+}
+
+// Stat returns the file info
+func (f *ioFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+// Read reads from the current offset
+func (f *ioFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "read", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	if err := f.seekStream(); err != nil {
+		return 0, pathError("read", f.name, err)
+	}
+
+	n, err := f.r.Read(p)
+	f.pos += int64(n)
+	f.offset = f.pos
+
+	return n, err
+}
+
+// Seek sets the offset of the next Read. Streams that can't seek are reopened when seeking backwards
+func (f *ioFile) Seek(offset int64, whence int) (int64, error) {
+	if f.closed {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.info.Size()
+	case io.SeekStart:
+	default:
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	if offset < 0 {
+		return 0, &fs.PathError{Op: "seek", Path: f.name, Err: fs.ErrInvalid}
+	}
+
+	f.offset = offset
+
+	return offset, nil
+}
+
+// Close closes the stream
+func (f *ioFile) Close() error {
+	if f.closed {
+		return &fs.PathError{Op: "close", Path: f.name, Err: fs.ErrClosed}
+	}
+
+	f.closed = true
+
+	if f.r == nil {
+		return nil
+	}
+
+	return f.r.Close()
+}
+
+// seekStream opens the stream or moves it to the offset set by Seek
+func (f *ioFile) seekStream() error {
+	if f.r != nil && f.pos == f.offset {
+		return nil
+	}
+
+	if s, ok := f.r.(io.Seeker); ok {
+		pos, err := s.Seek(f.offset, io.SeekStart)
+		f.pos = pos
+
+		return err
+	}
+
+	if f.r == nil || f.offset < f.pos {
+		if f.r != nil {
+			f.r.Close()
+		}
+
+		r, err := f.fsys.adapter.ReadStream(f.name)
+		if err != nil {
+			f.r = nil
+
+			return err
+		}
+
+		f.r = r
+		f.pos = 0
+	}
+
+	n, err := io.CopyN(io.Discard, f.r, f.offset-f.pos)
+	f.pos += n
+
+	// Seeking past the end is allowed, the next Read reports io.EOF
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+
+	return err
+}
+
+// ioDir is a directory opened through AsFS, it is listed when ReadDir is first called
+type ioDir struct {
+	fsys    *ioFS
+	name    string
+	info    fs.FileInfo
+	entries []fs.DirEntry
+	listed  bool
+}
+
+// Stat returns the file info
+func (d *ioDir) Stat() (fs.FileInfo, error) {
+	return d.info, nil
+}
+
+// Read fails, a directory has no contents
+func (d *ioDir) Read(p []byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.name, Err: errors.New("is a directory")}
+}
+
+// Close does nothing
+func (d *ioDir) Close() error {
+	return nil
+}
+
+// ReadDir returns the next n entries, or all remaining entries when n <= 0
+func (d *ioDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if !d.listed {
+		entries, err := d.fsys.ReadDir(d.name)
+		if err != nil {
+			return nil, err
+		}
+
+		d.entries = entries
+		d.listed = true
+	}
+
+	if n <= 0 {
+		entries := d.entries
+		d.entries = nil
+
+		return entries, nil
+	}
+
+	if len(d.entries) == 0 {
+		return nil, io.EOF
+	}
+
+	if n > len(d.entries) {
+		n = len(d.entries)
+	}
+
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+
+	return entries, nil
+}
+
+// fileInfo describes a file or directory by its attributes
+type fileInfo struct {
+	attributes adapter.FileAttributes
+}
+
+// Name returns the base name
+func (i fileInfo) Name() string {
+	if i.attributes.Path == "" {
+		return "."
+	}
+
+	return path.Base(i.attributes.Path)
+}
+
+// Size returns the size in bytes
+func (i fileInfo) Size() int64 {
+	return i.attributes.Size
+}
+
+// Mode returns the file mode, the permissions follow the visibility
+func (i fileInfo) Mode() fs.FileMode {
+	private := i.attributes.Visibility == adapter.VisibilityPrivate
+
+	switch {
+	case i.attributes.IsDir() && private:
+		return fs.ModeDir | 0700
+	case i.attributes.IsDir():
+		return fs.ModeDir | 0755
+	case private:
+		return 0600
+	}
+
+	return 0644
+}
+
+// ModTime returns the time the file was last modified
+func (i fileInfo) ModTime() time.Time {
+	return i.attributes.LastModified
+}
+
+// IsDir reports whether the info describes a directory
+func (i fileInfo) IsDir() bool {
+	return i.attributes.IsDir()
+}
+
+// Sys returns the adapter.FileAttributes
+func (i fileInfo) Sys() interface{} {
+	return i.attributes
+}
+
+// Type returns the type bits of the file mode, it makes fileInfo a fs.DirEntry as well
+func (i fileInfo) Type() fs.FileMode {
+	return i.Mode().Type()
+}
+
+// Info returns the info itself
+func (i fileInfo) Info() (fs.FileInfo, error) {
+	return i, nil
+}
+
+// fsError is an adapter error that also matches the fs error of its kind
+type fsError struct {
+	err  error
+	kind error
+}
+
+// Error returns the error message
+func (e *fsError) Error() string {
+	return e.err.Error()
+}
+
+// Unwrap returns the adapter error
+func (e *fsError) Unwrap() error {
+	return e.err
+}
+
+// Is reports whether the target is the fs error of the kind
+func (e *fsError) Is(target error) bool {
+	return target == e.kind
+}
+
+// pathError wraps an adapter error in an fs.PathError that matches fs.ErrNotExist, fs.ErrExist or fs.ErrPermission
+func pathError(op string, name string, err error) error {
+	var kind error
+
+	switch {
+	case errors.Is(err, adapter.ErrFileNotFound), errors.Is(err, adapter.ErrDirectoryNotFound):
+		kind = fs.ErrNotExist
+	case errors.Is(err, adapter.ErrFileExists):
+		kind = fs.ErrExist
+	case errors.Is(err, adapter.ErrPermissionDenied):
+		kind = fs.ErrPermission
+	}
+
+	if kind != nil {
+		err = &fsError{err: err, kind: kind}
+	}
+
+	return &fs.PathError{Op: op, Path: name, Err: err}
+}
+
+// validName reports whether name is valid for fs.FS,
+// backslashes are rejected as well since adapters treat them as path separators
+func validName(name string) bool {
+	return fs.ValidPath(name) && !strings.Contains(name, `\`)
+}
+
+// dirPath turns a name as accepted by fs.FS into a directory path, the root is ""
+func dirPath(name string) string {
+	if name == "." {
+		return ""
+	}
+
+	return name
+}
@@ -0,0 +1,183 @@
+package flysystem
+
+import (
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/fstest"
+)
+
+// onlyReader hides the io.Seeker of a stream
+type onlyReader struct {
+	adapter.Adapter
+}
+
+func (a onlyReader) ReadStream(path string) (io.ReadCloser, error) {
+	r, err := a.Adapter.ReadStream(path)
+	if err != nil {
+		return nil, err
+	}
+
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, r}, nil
+}
+
+func newFSAdapter(t *testing.T) adapter.Adapter {
+	a := adapter.NewMemory()
+
+	files := map[string]string{
+		"index.html":        "<h1>hello</h1>",
+		"docs/readme.txt":   "hello world",
+		"docs/sub/deep.txt": "deep",
+	}
+
+	for p, contents := range files {
+		if err := a.Write(p, []byte(contents)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := a.CreateDir("empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	return a
+}
+
+func TestAsFS(t *testing.T) {
+	a := newFSAdapter(t)
+
+	err := fstest.TestFS(AsFS(a), "index.html", "docs/readme.txt", "docs/sub/deep.txt", "empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fstest.TestFS(AsFS(onlyReader{a}), "index.html", "docs/readme.txt", "docs/sub/deep.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fstest.TestFS(AsFS(New(a)), "index.html", "docs/readme.txt", "docs/sub/deep.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+}
+
+func TestAsFS_Errors(t *testing.T) {
+	fsys := AsFS(newFSAdapter(t))
+
+	_, err := fs.ReadFile(fsys, "missing.txt")
+	if !errors.Is(err, fs.ErrNotExist) || !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected fs.ErrNotExist and adapter.ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.Stat(fsys, "docs/missing")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Logf("expected fs.ErrNotExist, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.ReadDir(fsys, "index.html")
+	if !errors.Is(err, fs.ErrNotExist) {
+		t.Logf("expected fs.ErrNotExist, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fsys.Open("/index.html")
+	if !errors.Is(err, fs.ErrInvalid) {
+		t.Logf("expected fs.ErrInvalid, got %v", err)
+		t.Fail()
+	}
+
+	info, err := fs.Stat(fsys, "docs/readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if attributes, ok := info.Sys().(adapter.FileAttributes); !ok || attributes.Path != "docs/readme.txt" {
+		t.Logf("expected Sys to return the attributes, got %#v", info.Sys())
+		t.Fail()
+	}
+}
+
+func TestAsFS_HTTP(t *testing.T) {
+	a := newFSAdapter(t)
+
+	server := httptest.NewServer(http.FileServer(http.FS(AsFS(onlyReader{a}))))
+	defer server.Close()
+
+	res, err := http.Get(server.URL + "/docs/readme.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK || string(body) != "hello world" {
+		t.Logf("unexpected response: %d %q", res.StatusCode, body)
+		t.Fail()
+	}
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL+"/docs/readme.txt", nil)
+	req.Header.Set("Range", "bytes=6-")
+
+	res, err = http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent || string(body) != "world" {
+		t.Logf("unexpected range response: %d %q", res.StatusCode, body)
+		t.Fail()
+	}
+
+	res, err = http.Get(server.URL + "/missing.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusNotFound {
+		t.Logf("expected 404, got %d", res.StatusCode)
+		t.Fail()
+	}
+
+	res, err = http.Get(server.URL + "/docs/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ = io.ReadAll(res.Body)
+	res.Body.Close()
+
+	if !strings.Contains(string(body), "readme.txt") || !strings.Contains(string(body), "sub/") {
+		t.Logf("expected a directory listing, got %q", body)
+		t.Fail()
+	}
+}
+
+func TestAsFS_RoundTrip(t *testing.T) {
+	fsys := AsFS(adapter.NewFromFS(AsFS(newFSAdapter(t))))
+
+	err := fstest.TestFS(fsys, "index.html", "docs/readme.txt", "docs/sub/deep.txt", "empty")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+}
@@ -2,4 +2,8 @@ module github.com/edwin-luijten/go_flysystem
 
 go 1.16
 
-require golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
+require (
+	github.com/klauspost/compress v1.15.9
+	golang.org/x/crypto v0.0.0-20210421170649-83a5a9bb288b
+	golang.org/x/sync v0.1.0
+)
@@ -0,0 +1,728 @@
+package object
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/edwin-luijten/go_flysystem/adapter"
+)
+
+var (
+	// ErrUnknownVisibility is returned for a visibility other than public or private
+	ErrUnknownVisibility = errors.New("unknown visibility")
+
+	// ErrIncompleteDeletion is returned by a store when some of the objects weren't deleted
+	ErrIncompleteDeletion = errors.New("not every object was deleted")
+
+	// ErrMoveIntoItself is returned when a directory is renamed into itself
+	ErrMoveIntoItself = errors.New("can't move a directory into itself")
+
+	errDeleteRoot = errors.New("can't delete the root directory")
+)
+
+// Store is the part of an object store's API the object adapters are built on, keys include the prefix
+type Store interface {
+	// Head returns the attributes of an object
+	Head(ctx context.Context, key string) (Object, error)
+	// Get opens an object for reading
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Put stores an object, an exclusive put fails when the object already exists.
+	// An empty visibility leaves the access to the store's defaults
+	Put(ctx context.Context, key string, contents io.Reader, mimeType string, visibility string, exclusive bool) error
+	// Copy copies an object on the server
+	Copy(ctx context.Context, source string, destination string, visibility string) error
+	// Delete deletes objects, missing objects are skipped
+	Delete(ctx context.Context, keys []string) error
+	// List pages through the objects whose key starts with prefix. A delimited listing stops at slashes
+	// and returns the common prefixes instead, limit caps the size of a page when it isn't zero
+	List(ctx context.Context, prefix string, delimited bool, limit int) Pages
+	// Visibility returns the visibility of an object
+	Visibility(ctx context.Context, key string) (string, error)
+	// SetVisibility changes the visibility of an object
+	SetVisibility(ctx context.Context, key string, visibility string) error
+	// Kind maps an error returned by the store to an error kind, nil when it has none
+	Kind(err error) error
+}
+
+// Pages pages through a listing
+type Pages interface {
+	More() bool
+	Next() (Page, error)
+}
+
+// Page is a page of a listing
+type Page struct {
+	Objects  []Object
+	Prefixes []string
+}
+
+// Object describes a stored object
+type Object struct {
+	Key          string
+	Size         int64
+	LastModified time.Time
+	MimeType     string
+}
+
+// Adapter implements the adapters on top of an object store.
+// Directories don't exist in object stores, they are emulated with empty marker objects whose key ends with a slash
+// and by the keys of the files in them
+type Adapter struct {
+	store      Store
+	prefix     string
+	visibility string
+}
+
+// New creates an adapter on top of store, keys are prefixed with prefix.
+// Files are written with visibility, an empty visibility leaves the access to the store's defaults
+func New(store Store, prefix string, visibility string) *Adapter {
+	return &Adapter{store: store, prefix: prefix, visibility: visibility}
+}
+
+// Write a new file, it fails if the file already exists
+func (a *Adapter) Write(path string, contents []byte) error {
+	return a.WriteContext(context.Background(), path, contents)
+}
+
+// Update a file, it fails if the file does not exist
+func (a *Adapter) Update(path string, contents []byte) error {
+	return a.UpdateContext(context.Background(), path, contents)
+}
+
+// Put writes a file, creating or overwriting it
+func (a *Adapter) Put(path string, contents []byte) error {
+	return a.PutContext(context.Background(), path, contents)
+}
+
+// Read a file
+func (a *Adapter) Read(path string) ([]byte, error) {
+	return a.ReadContext(context.Background(), path)
+}
+
+// WriteStream writes a new file from a stream, it fails if the file already exists
+func (a *Adapter) WriteStream(path string, contents io.Reader) error {
+	return a.WriteStreamContext(context.Background(), path, contents)
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (a *Adapter) ReadStream(path string) (io.ReadCloser, error) {
+	return a.ReadStreamContext(context.Background(), path)
+}
+
+// Rename a file or directory
+func (a *Adapter) Rename(path string, newPath string) error {
+	return a.RenameContext(context.Background(), path, newPath)
+}
+
+// Copy a file
+func (a *Adapter) Copy(path string, newPath string) error {
+	return a.CopyContext(context.Background(), path, newPath)
+}
+
+// Delete a file
+func (a *Adapter) Delete(path string) error {
+	return a.DeleteContext(context.Background(), path)
+}
+
+// CreateDir creates a directory
+func (a *Adapter) CreateDir(dir string) error {
+	return a.CreateDirContext(context.Background(), dir)
+}
+
+// DeleteDir deletes a directory and its contents
+func (a *Adapter) DeleteDir(dir string) error {
+	return a.DeleteDirContext(context.Background(), dir)
+}
+
+// SetVisibility sets a file or directory to public or private
+func (a *Adapter) SetVisibility(path string, visibility string) error {
+	return a.SetVisibilityContext(context.Background(), path, visibility)
+}
+
+// Has checks if a file or directory exists
+func (a *Adapter) Has(path string) (bool, error) {
+	return a.HasContext(context.Background(), path)
+}
+
+// FileExists checks if a file exists
+func (a *Adapter) FileExists(path string) (bool, error) {
+	return a.FileExistsContext(context.Background(), path)
+}
+
+// DirectoryExists checks if a directory exists
+func (a *Adapter) DirectoryExists(dir string) (bool, error) {
+	return a.DirectoryExistsContext(context.Background(), dir)
+}
+
+// Stat returns the attributes of a file or directory
+func (a *Adapter) Stat(path string) (adapter.FileAttributes, error) {
+	return a.StatContext(context.Background(), path)
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories
+func (a *Adapter) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	return a.ListContentsContext(context.Background(), dir, deep)
+}
+
+// WriteContext writes a new file
+func (a *Adapter) WriteContext(ctx context.Context, path string, contents []byte) error {
+	return a.WriteStreamContext(ctx, path, bytes.NewReader(contents))
+}
+
+// UpdateContext updates a file, it keeps the visibility of the file
+func (a *Adapter) UpdateContext(ctx context.Context, path string, contents []byte) error {
+	ok, err := a.FileExistsContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("update", path, adapter.ErrFileNotFound)
+	}
+
+	visibility, err := a.keepVisibility(ctx, a.key(path))
+	if err != nil {
+		return a.error("update", path, adapter.ErrFileNotFound, err)
+	}
+
+	return a.error("update", path, adapter.ErrFileNotFound, a.upload(ctx, path, bytes.NewReader(contents), visibility, false))
+}
+
+// PutContext writes a file, an existing file keeps its visibility
+func (a *Adapter) PutContext(ctx context.Context, path string, contents []byte) error {
+	visibility, err := a.keepVisibility(ctx, a.key(path))
+	if err != nil {
+		return a.error("put", path, adapter.ErrFileNotFound, err)
+	}
+
+	return a.error("put", path, adapter.ErrFileNotFound, a.upload(ctx, path, bytes.NewReader(contents), visibility, false))
+}
+
+// ReadContext reads a file
+func (a *Adapter) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	r, err := a.ReadStreamContext(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+
+	contents, err := io.ReadAll(r)
+	if err != nil {
+		return nil, a.error("read", path, adapter.ErrFileNotFound, err)
+	}
+
+	return contents, nil
+}
+
+// WriteStreamContext writes a new file from a stream, large streams are uploaded in parts
+func (a *Adapter) WriteStreamContext(ctx context.Context, path string, contents io.Reader) error {
+	ok, err := a.FileExistsContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	// Checked up front to avoid uploading a file that is going to be rejected,
+	// the upload itself is conditional to catch concurrent writers
+	if ok {
+		return adapter.WrapError("write", path, adapter.ErrFileExists)
+	}
+
+	return a.error("write", path, adapter.ErrFileNotFound, a.upload(ctx, path, contents, a.visibility, true))
+}
+
+// ReadStreamContext opens a file for reading
+func (a *Adapter) ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	r, err := a.store.Get(ctx, a.key(path))
+	if err != nil {
+		return nil, a.error("read", path, adapter.ErrFileNotFound, err)
+	}
+
+	return r, nil
+}
+
+// RenameContext renames a file or directory, object stores can't rename so the objects are copied and then deleted
+func (a *Adapter) RenameContext(ctx context.Context, path string, newPath string) error {
+	ok, err := a.FileExistsContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		if err := a.copyObject(ctx, a.key(path), a.key(newPath)); err != nil {
+			return a.error("rename", path, adapter.ErrFileNotFound, err)
+		}
+
+		return a.error("rename", path, adapter.ErrFileNotFound, a.store.Delete(ctx, []string{a.key(path)}))
+	}
+
+	ok, err = a.DirectoryExistsContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	if !ok {
+		return adapter.WrapError("rename", path, adapter.ErrFileNotFound)
+	}
+
+	source := a.dirKey(path)
+	destination := a.dirKey(newPath)
+
+	if strings.HasPrefix(destination, source) {
+		return adapter.WrapError("rename", newPath, ErrMoveIntoItself)
+	}
+
+	err = a.walk(ctx, source, func(objects []Object) error {
+		for _, object := range objects {
+			if err := a.copyObject(ctx, object.Key, destination+strings.TrimPrefix(object.Key, source)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return a.error("rename", path, adapter.ErrFileNotFound, err)
+	}
+
+	return a.error("rename", path, adapter.ErrFileNotFound, a.deletePrefix(ctx, source))
+}
+
+// CopyContext copies a file on the server, the copy gets the visibility of the file
+func (a *Adapter) CopyContext(ctx context.Context, path string, newPath string) error {
+	return a.error("copy", path, adapter.ErrFileNotFound, a.copyObject(ctx, a.key(path), a.key(newPath)))
+}
+
+// DeleteContext deletes a file
+func (a *Adapter) DeleteContext(ctx context.Context, path string) error {
+	ok, err := a.FileExistsContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	// Object stores don't complain about deleting a missing object
+	if !ok {
+		return adapter.WrapError("delete", path, adapter.ErrFileNotFound)
+	}
+
+	return a.error("delete", path, adapter.ErrFileNotFound, a.store.Delete(ctx, []string{a.key(path)}))
+}
+
+// CreateDirContext creates a directory marker
+func (a *Adapter) CreateDirContext(ctx context.Context, dir string) error {
+	ok, err := a.DirectoryExistsContext(ctx, dir)
+	if err != nil {
+		return err
+	}
+
+	if ok {
+		return adapter.WrapDirError("mkdir", dir, adapter.ErrFileExists)
+	}
+
+	err = a.store.Put(ctx, a.dirKey(dir), bytes.NewReader(nil), "", a.visibility, false)
+
+	return a.error("mkdir", dir, adapter.ErrDirectoryNotFound, err)
+}
+
+// DeleteDirContext deletes a directory and its contents.
+// The root is refused, deleting it would empty the bucket or everything under the prefix
+func (a *Adapter) DeleteDirContext(ctx context.Context, dir string) error {
+	if adapter.NormalizePath(dir) == "" {
+		return &adapter.Error{Op: "rmdir", Path: dir, Kind: adapter.ErrPermissionDenied, Err: errDeleteRoot}
+	}
+
+	return a.error("rmdir", dir, adapter.ErrDirectoryNotFound, a.deletePrefix(ctx, a.dirKey(dir)))
+}
+
+// SetVisibilityContext sets the visibility of a file or directory marker.
+// Directories without a marker have nothing to set
+func (a *Adapter) SetVisibilityContext(ctx context.Context, path string, visibility string) error {
+	if visibility != adapter.VisibilityPublic && visibility != adapter.VisibilityPrivate {
+		return adapter.WrapError("chmod", path, ErrUnknownVisibility)
+	}
+
+	ok, err := a.FileExistsContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	key := a.key(path)
+
+	if !ok {
+		ok, err = a.DirectoryExistsContext(ctx, path)
+		if err != nil {
+			return err
+		}
+
+		if !ok {
+			return adapter.WrapError("chmod", path, adapter.ErrFileNotFound)
+		}
+
+		key = a.dirKey(path)
+
+		ok, err = a.exists(ctx, key)
+		if err != nil || !ok {
+			return a.error("chmod", path, adapter.ErrFileNotFound, err)
+		}
+	}
+
+	return a.error("chmod", path, adapter.ErrFileNotFound, a.store.SetVisibility(ctx, key, visibility))
+}
+
+// HasContext checks if a file or directory exists
+func (a *Adapter) HasContext(ctx context.Context, path string) (bool, error) {
+	ok, err := a.FileExistsContext(ctx, path)
+	if err != nil || ok {
+		return ok, err
+	}
+
+	return a.DirectoryExistsContext(ctx, path)
+}
+
+// FileExistsContext checks if a file exists
+func (a *Adapter) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	ok, err := a.exists(ctx, a.key(path))
+
+	return ok, a.error("stat", path, adapter.ErrFileNotFound, err)
+}
+
+// DirectoryExistsContext checks if a directory marker or a file in the directory exists
+func (a *Adapter) DirectoryExistsContext(ctx context.Context, dir string) (bool, error) {
+	if adapter.NormalizePath(dir) == "" {
+		return true, nil
+	}
+
+	page, err := a.store.List(ctx, a.dirKey(dir), false, 1).Next()
+	if err != nil {
+		return false, a.error("stat", dir, adapter.ErrDirectoryNotFound, err)
+	}
+
+	return len(page.Objects) > 0, nil
+}
+
+// StatContext returns the attributes of a file or directory.
+// Visibility is only reported when the adapter is configured with one
+func (a *Adapter) StatContext(ctx context.Context, path string) (adapter.FileAttributes, error) {
+	p := adapter.NormalizePath(path)
+
+	object, err := a.store.Head(ctx, a.key(path))
+	if a.store.Kind(err) == adapter.ErrFileNotFound {
+		ok, err := a.DirectoryExistsContext(ctx, path)
+		if err != nil {
+			return adapter.FileAttributes{}, err
+		}
+
+		if !ok {
+			return adapter.FileAttributes{}, adapter.WrapError("stat", path, adapter.ErrFileNotFound)
+		}
+
+		return adapter.FileAttributes{Path: p, Type: adapter.TypeDir}, nil
+	}
+
+	if err != nil {
+		return adapter.FileAttributes{}, a.error("stat", path, adapter.ErrFileNotFound, err)
+	}
+
+	attributes := adapter.FileAttributes{
+		Path:         p,
+		Type:         adapter.TypeFile,
+		Size:         object.Size,
+		LastModified: object.LastModified,
+		MimeType:     object.MimeType,
+	}
+
+	if attributes.MimeType == "" {
+		attributes.MimeType = adapter.DetectMimeType(p, nil)
+	}
+
+	if a.visibility != "" {
+		attributes.Visibility, err = a.store.Visibility(ctx, a.key(path))
+		if err != nil {
+			return adapter.FileAttributes{}, a.error("stat", path, adapter.ErrFileNotFound, err)
+		}
+	}
+
+	return attributes, nil
+}
+
+// ListContentsContext lists the contents of a directory, the listing is fetched a page at a time.
+// Listed entries don't have a visibility, looking it up takes a request per file
+func (a *Adapter) ListContentsContext(ctx context.Context, dir string, deep bool) (adapter.DirectoryListing, error) {
+	p := adapter.NormalizePath(dir)
+	prefix := a.dirKey(dir)
+
+	l := &listing{
+		adapter: a,
+		pages:   a.store.List(ctx, prefix, !deep, 0),
+		dir:     p,
+		prefix:  prefix,
+		deep:    deep,
+		seen:    map[string]bool{},
+	}
+
+	// The first page tells whether the directory exists
+	l.fetch()
+
+	if l.err != nil {
+		return nil, l.err
+	}
+
+	if !l.found && p != "" {
+		return nil, adapter.WrapDirError("list", dir, adapter.ErrDirectoryNotFound)
+	}
+
+	return l, nil
+}
+
+// key returns the object key of a file
+func (a *Adapter) key(p string) string {
+	return path.Join(a.prefix, adapter.NormalizePath(p))
+}
+
+// dirKey returns the key prefix shared by the objects in a directory
+func (a *Adapter) dirKey(dir string) string {
+	if key := a.key(dir); key != "" {
+		return key + "/"
+	}
+
+	return ""
+}
+
+// path returns the path of an object key
+func (a *Adapter) path(key string) string {
+	return strings.Trim(strings.TrimPrefix(key, a.prefix), "/")
+}
+
+// exists checks if an object exists
+func (a *Adapter) exists(ctx context.Context, key string) (bool, error) {
+	_, err := a.store.Head(ctx, key)
+	if a.store.Kind(err) == adapter.ErrFileNotFound {
+		return false, nil
+	}
+
+	return err == nil, err
+}
+
+// upload stores a file, its mime type is detected from the extension or the first bytes
+func (a *Adapter) upload(ctx context.Context, path string, contents io.Reader, visibility string, exclusive bool) error {
+	r := bufio.NewReaderSize(contents, 512)
+
+	head, err := r.Peek(512)
+	if err != nil && err != io.EOF {
+		return err
+	}
+
+	return a.store.Put(ctx, a.key(path), r, adapter.DetectMimeType(path, head), visibility, exclusive)
+}
+
+// copyObject copies an object on the server, the copy gets the visibility of the source
+func (a *Adapter) copyObject(ctx context.Context, source string, destination string) error {
+	visibility, err := a.keepVisibility(ctx, source)
+	if err != nil {
+		return err
+	}
+
+	return a.store.Copy(ctx, source, destination, visibility)
+}
+
+// deletePrefix deletes every object whose key starts with prefix
+func (a *Adapter) deletePrefix(ctx context.Context, prefix string) error {
+	return a.walk(ctx, prefix, func(objects []Object) error {
+		keys := make([]string, len(objects))
+		for i, object := range objects {
+			keys[i] = object.Key
+		}
+
+		return a.store.Delete(ctx, keys)
+	})
+}
+
+// walk calls fn with every page of objects whose key starts with prefix
+func (a *Adapter) walk(ctx context.Context, prefix string, fn func(objects []Object) error) error {
+	pages := a.store.List(ctx, prefix, false, 0)
+
+	for pages.More() {
+		page, err := pages.Next()
+		if err != nil {
+			return err
+		}
+
+		if err := fn(page.Objects); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keepVisibility returns the visibility for writing to key, an existing object keeps its visibility
+func (a *Adapter) keepVisibility(ctx context.Context, key string) (string, error) {
+	if a.visibility == "" {
+		return "", nil
+	}
+
+	visibility, err := a.store.Visibility(ctx, key)
+	if a.store.Kind(err) == adapter.ErrFileNotFound {
+		return a.visibility, nil
+	}
+
+	return visibility, err
+}
+
+// error wraps an error returned by the store, mapping it to an error kind
+func (a *Adapter) error(op string, path string, notFound error, err error) error {
+	return NewError(a.store, op, path, notFound, err)
+}
+
+// NewError wraps an error returned by store, mapping it to an error kind
+func NewError(store Store, op string, path string, notFound error, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	switch kind := store.Kind(err); kind {
+	case nil:
+		return adapter.NewError(op, path, notFound, err)
+	case adapter.ErrFileNotFound:
+		return &adapter.Error{Op: op, Path: path, Kind: notFound, Err: err}
+	default:
+		return &adapter.Error{Op: op, Path: path, Kind: kind, Err: err}
+	}
+}
+
+// listing pages through the objects under a prefix
+type listing struct {
+	adapter    *Adapter
+	pages      Pages
+	dir        string
+	prefix     string
+	deep       bool
+	found      bool
+	seen       map[string]bool
+	batch      []adapter.FileAttributes
+	attributes adapter.FileAttributes
+	err        error
+}
+
+// Next advances to the next entry
+func (l *listing) Next() bool {
+	for len(l.batch) == 0 {
+		if l.err != nil || l.pages == nil || !l.pages.More() {
+			return false
+		}
+
+		l.fetch()
+	}
+
+	l.attributes = l.batch[0]
+	l.batch = l.batch[1:]
+
+	return true
+}
+
+// Attributes returns the attributes of the current entry
+func (l *listing) Attributes() adapter.FileAttributes {
+	return l.attributes
+}
+
+// Err returns the error that stopped the listing
+func (l *listing) Err() error {
+	return l.err
+}
+
+// Close stops the listing
+func (l *listing) Close() error {
+	l.pages = nil
+	l.batch = nil
+
+	return nil
+}
+
+// fetch turns the next page of objects into entries
+func (l *listing) fetch() {
+	page, err := l.pages.Next()
+	if err != nil {
+		l.err = l.adapter.error("list", l.dir, adapter.ErrDirectoryNotFound, err)
+
+		return
+	}
+
+	if len(page.Objects) > 0 || len(page.Prefixes) > 0 {
+		l.found = true
+	}
+
+	var batch []adapter.FileAttributes
+
+	for _, prefix := range page.Prefixes {
+		batch = l.addDir(batch, l.adapter.path(prefix))
+	}
+
+	for _, object := range page.Objects {
+		// The marker of the listed directory itself
+		if object.Key == l.prefix {
+			continue
+		}
+
+		p := l.adapter.path(object.Key)
+
+		if l.deep {
+			batch = l.addParents(batch, p)
+		}
+
+		if strings.HasSuffix(object.Key, "/") {
+			batch = l.addDir(batch, p)
+
+			continue
+		}
+
+		batch = append(batch, adapter.FileAttributes{
+			Path:         p,
+			Type:         adapter.TypeFile,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+			MimeType:     adapter.DetectMimeType(p, nil),
+		})
+	}
+
+	// Directories and files are returned separately when listing a single level
+	if !l.deep {
+		sort.Slice(batch, func(i, j int) bool {
+			return batch[i].Path < batch[j].Path
+		})
+	}
+
+	l.batch = append(l.batch, batch...)
+}
+
+// addDir adds a directory that wasn't listed yet
+func (l *listing) addDir(batch []adapter.FileAttributes, p string) []adapter.FileAttributes {
+	if l.seen[p] {
+		return batch
+	}
+
+	l.seen[p] = true
+
+	return append(batch, adapter.FileAttributes{Path: p, Type: adapter.TypeDir})
+}
+
+// addParents adds the directories between the listed directory and p, they may only exist as part of a key
+func (l *listing) addParents(batch []adapter.FileAttributes, p string) []adapter.FileAttributes {
+	var parents []string
+
+	for dir := path.Dir(p); dir != "." && dir != l.dir; dir = path.Dir(dir) {
+		parents = append(parents, dir)
+	}
+
+	for i := len(parents) - 1; i >= 0; i-- {
+		batch = l.addDir(batch, parents[i])
+	}
+
+	return batch
+}
@@ -0,0 +1,542 @@
+package flysystem
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrMountNotFound is returned when a path has no mount prefix or nothing is mounted under its prefix
+var ErrMountNotFound = errors.New("mount not found")
+
+// ErrMountExists is returned when something is already mounted under a name
+var ErrMountExists = errors.New("mount already exists")
+
+// mountSeparator separates the mount name from the path, as in "uploads://reports/2024.csv"
+const mountSeparator = "://"
+
+// MountManager routes every operation to the adapter or Flysystem mounted under the prefix of its path,
+// "uploads://reports/2024.csv" is the file reports/2024.csv of the filesystem mounted as uploads.
+// Copy and Rename between mounts stream the contents from one to the other
+type MountManager struct {
+	lock   sync.RWMutex
+	mounts map[string]adapter.ContextAdapter
+}
+
+// NewMountManager creates a new instance without mounts
+func NewMountManager() *MountManager {
+	return &MountManager{
+		mounts: map[string]adapter.ContextAdapter{},
+	}
+}
+
+// Mount makes a filesystem available under name, it fails with ErrMountExists when the name is taken
+func (m *MountManager) Mount(name string, a adapter.Adapter) error {
+	if name == "" || strings.ContainsAny(name, ":/") {
+		return fmt.Errorf("invalid mount name %q", name)
+	}
+
+	m.lock.Lock()
+
+	defer m.lock.Unlock()
+
+	if _, ok := m.mounts[name]; ok {
+		return &adapter.Error{Op: "mount", Path: name, Kind: ErrMountExists}
+	}
+
+	m.mounts[name] = adapter.WithContext(a)
+
+	return nil
+}
+
+// Unmount removes the filesystem mounted under name
+func (m *MountManager) Unmount(name string) {
+	m.lock.Lock()
+
+	defer m.lock.Unlock()
+
+	delete(m.mounts, name)
+}
+
+// Mounts returns the names of the mounted filesystems, sorted
+func (m *MountManager) Mounts() []string {
+	m.lock.RLock()
+
+	defer m.lock.RUnlock()
+
+	names := make([]string, 0, len(m.mounts))
+	for name := range m.mounts {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	return names
+}
+
+// Write a new file
+func (m *MountManager) Write(path string, contents []byte) error {
+	return m.WriteContext(context.Background(), path, contents)
+}
+
+// WriteContext writes a new file
+func (m *MountManager) WriteContext(ctx context.Context, path string, contents []byte) error {
+	a, p, err := m.resolve("write", path)
+	if err != nil {
+		return err
+	}
+
+	return a.WriteContext(ctx, p, contents)
+}
+
+// Update a file
+func (m *MountManager) Update(path string, contents []byte) error {
+	return m.UpdateContext(context.Background(), path, contents)
+}
+
+// UpdateContext updates a file
+func (m *MountManager) UpdateContext(ctx context.Context, path string, contents []byte) error {
+	a, p, err := m.resolve("update", path)
+	if err != nil {
+		return err
+	}
+
+	return a.UpdateContext(ctx, p, contents)
+}
+
+// Put creates or overwrites a file
+func (m *MountManager) Put(path string, contents []byte) error {
+	return m.PutContext(context.Background(), path, contents)
+}
+
+// PutContext creates or overwrites a file
+func (m *MountManager) PutContext(ctx context.Context, path string, contents []byte) error {
+	a, p, err := m.resolve("put", path)
+	if err != nil {
+		return err
+	}
+
+	return a.PutContext(ctx, p, contents)
+}
+
+// Read a file
+func (m *MountManager) Read(path string) ([]byte, error) {
+	return m.ReadContext(context.Background(), path)
+}
+
+// ReadContext reads a file
+func (m *MountManager) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	a, p, err := m.resolve("read", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.ReadContext(ctx, p)
+}
+
+// WriteStream writes a new file from a stream
+func (m *MountManager) WriteStream(path string, contents io.Reader) error {
+	return m.WriteStreamContext(context.Background(), path, contents)
+}
+
+// WriteStreamContext writes a new file from a stream
+func (m *MountManager) WriteStreamContext(ctx context.Context, path string, contents io.Reader) error {
+	a, p, err := m.resolve("write", path)
+	if err != nil {
+		return err
+	}
+
+	return a.WriteStreamContext(ctx, p, contents)
+}
+
+// ReadStream opens a file for reading, the caller must close it
+func (m *MountManager) ReadStream(path string) (io.ReadCloser, error) {
+	return m.ReadStreamContext(context.Background(), path)
+}
+
+// ReadStreamContext opens a file for reading, the caller must close it
+func (m *MountManager) ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	a, p, err := m.resolve("read", path)
+	if err != nil {
+		return nil, err
+	}
+
+	return a.ReadStreamContext(ctx, p)
+}
+
+// Rename a file or directory. Between mounts the contents are copied and then deleted from the source,
+// when deleting fails the copy is left in place
+func (m *MountManager) Rename(path string, newPath string) error {
+	return m.RenameContext(context.Background(), path, newPath)
+}
+
+// RenameContext renames a file or directory
+func (m *MountManager) RenameContext(ctx context.Context, path string, newPath string) error {
+	from, p, err := m.resolve("rename", path)
+	if err != nil {
+		return err
+	}
+
+	to, destination, err := m.resolve("rename", newPath)
+	if err != nil {
+		return err
+	}
+
+	if mountName(path) == mountName(newPath) {
+		return from.RenameContext(ctx, p, destination)
+	}
+
+	attributes, err := from.StatContext(ctx, p)
+	if err != nil {
+		return err
+	}
+
+	if !attributes.IsDir() {
+		if err := m.copyAcross(ctx, from, p, to, destination); err != nil {
+			return err
+		}
+
+		return from.DeleteContext(ctx, p)
+	}
+
+	if err := m.copyDirAcross(ctx, from, p, to, destination); err != nil {
+		return err
+	}
+
+	return from.DeleteDirContext(ctx, p)
+}
+
+// Copy a file. Between mounts the contents are streamed, the copy gets the default visibility of the destination
+func (m *MountManager) Copy(path string, newPath string) error {
+	return m.CopyContext(context.Background(), path, newPath)
+}
+
+// CopyContext copies a file
+func (m *MountManager) CopyContext(ctx context.Context, path string, newPath string) error {
+	from, p, err := m.resolve("copy", path)
+	if err != nil {
+		return err
+	}
+
+	to, destination, err := m.resolve("copy", newPath)
+	if err != nil {
+		return err
+	}
+
+	if mountName(path) == mountName(newPath) {
+		return from.CopyContext(ctx, p, destination)
+	}
+
+	return m.copyAcross(ctx, from, p, to, destination)
+}
+
+// Delete a file
+func (m *MountManager) Delete(path string) error {
+	return m.DeleteContext(context.Background(), path)
+}
+
+// DeleteContext deletes a file
+func (m *MountManager) DeleteContext(ctx context.Context, path string) error {
+	a, p, err := m.resolve("delete", path)
+	if err != nil {
+		return err
+	}
+
+	return a.DeleteContext(ctx, p)
+}
+
+// CreateDir creates a directory
+func (m *MountManager) CreateDir(dir string) error {
+	return m.CreateDirContext(context.Background(), dir)
+}
+
+// CreateDirContext creates a directory
+func (m *MountManager) CreateDirContext(ctx context.Context, dir string) error {
+	a, p, err := m.resolve("mkdir", dir)
+	if err != nil {
+		return err
+	}
+
+	return a.CreateDirContext(ctx, p)
+}
+
+// DeleteDir deletes a directory
+func (m *MountManager) DeleteDir(dir string) error {
+	return m.DeleteDirContext(context.Background(), dir)
+}
+
+// DeleteDirContext deletes a directory
+func (m *MountManager) DeleteDirContext(ctx context.Context, dir string) error {
+	a, p, err := m.resolve("rmdir", dir)
+	if err != nil {
+		return err
+	}
+
+	return a.DeleteDirContext(ctx, p)
+}
+
+// SetVisibility sets a file or directory to public or private
+func (m *MountManager) SetVisibility(path string, visibility string) error {
+	return m.SetVisibilityContext(context.Background(), path, visibility)
+}
+
+// SetVisibilityContext sets a file or directory to public or private
+func (m *MountManager) SetVisibilityContext(ctx context.Context, path string, visibility string) error {
+	a, p, err := m.resolve("chmod", path)
+	if err != nil {
+		return err
+	}
+
+	return a.SetVisibilityContext(ctx, p, visibility)
+}
+
+// Has checks if a file or directory exists
+func (m *MountManager) Has(path string) (bool, error) {
+	return m.HasContext(context.Background(), path)
+}
+
+// HasContext checks if a file or directory exists
+func (m *MountManager) HasContext(ctx context.Context, path string) (bool, error) {
+	a, p, err := m.resolve("stat", path)
+	if err != nil {
+		return false, err
+	}
+
+	return a.HasContext(ctx, p)
+}
+
+// FileExists checks if a file exists
+func (m *MountManager) FileExists(path string) (bool, error) {
+	return m.FileExistsContext(context.Background(), path)
+}
+
+// FileExistsContext checks if a file exists
+func (m *MountManager) FileExistsContext(ctx context.Context, path string) (bool, error) {
+	a, p, err := m.resolve("stat", path)
+	if err != nil {
+		return false, err
+	}
+
+	return a.FileExistsContext(ctx, p)
+}
+
+// DirectoryExists checks if a directory exists
+func (m *MountManager) DirectoryExists(dir string) (bool, error) {
+	return m.DirectoryExistsContext(context.Background(), dir)
+}
+
+// DirectoryExistsContext checks if a directory exists
+func (m *MountManager) DirectoryExistsContext(ctx context.Context, dir string) (bool, error) {
+	a, p, err := m.resolve("stat", dir)
+	if err != nil {
+		return false, err
+	}
+
+	return a.DirectoryExistsContext(ctx, p)
+}
+
+// Stat returns the attributes of a file or directory, the path includes the mount prefix
+func (m *MountManager) Stat(path string) (adapter.FileAttributes, error) {
+	return m.StatContext(context.Background(), path)
+}
+
+// StatContext returns the attributes of a file or directory
+func (m *MountManager) StatContext(ctx context.Context, path string) (adapter.FileAttributes, error) {
+	a, p, err := m.resolve("stat", path)
+	if err != nil {
+		return adapter.FileAttributes{}, err
+	}
+
+	attributes, err := a.StatContext(ctx, p)
+	if err != nil {
+		return adapter.FileAttributes{}, err
+	}
+
+	attributes.Path = mountName(path) + mountSeparator + attributes.Path
+
+	return attributes, nil
+}
+
+// ListContents lists the contents of a directory, deep also lists the contents of subdirectories.
+// The paths include the mount prefix
+func (m *MountManager) ListContents(dir string, deep bool) (adapter.DirectoryListing, error) {
+	return m.ListContentsContext(context.Background(), dir, deep)
+}
+
+// ListContentsContext lists the contents of a directory
+func (m *MountManager) ListContentsContext(ctx context.Context, dir string, deep bool) (adapter.DirectoryListing, error) {
+	a, p, err := m.resolve("list", dir)
+	if err != nil {
+		return nil, err
+	}
+
+	listing, err := a.ListContentsContext(ctx, p, deep)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mountListing{DirectoryListing: listing, prefix: mountName(dir) + mountSeparator}, nil
+}
+
+// resolve returns the filesystem mounted under the prefix of path and the path within it
+func (m *MountManager) resolve(op string, path string) (adapter.ContextAdapter, string, error) {
+	i := strings.Index(path, mountSeparator)
+	if i < 0 {
+		return nil, "", &adapter.Error{Op: op, Path: path, Kind: ErrMountNotFound}
+	}
+
+	name, p := path[:i], path[i+len(mountSeparator):]
+
+	m.lock.RLock()
+	a, ok := m.mounts[name]
+	m.lock.RUnlock()
+
+	if !ok {
+		return nil, "", &adapter.Error{Op: op, Path: path, Kind: ErrMountNotFound}
+	}
+
+	return a, p, nil
+}
+
+// copyAcross streams a file from one mount to another. An existing file at the destination is only replaced
+// once the new contents are stored: they are written next to it first and then moved over it
+func (m *MountManager) copyAcross(ctx context.Context, from adapter.ContextAdapter, path string, to adapter.ContextAdapter, newPath string) error {
+	r, err := from.ReadStreamContext(ctx, path)
+	if err != nil {
+		return err
+	}
+
+	defer r.Close()
+
+	exists, err := to.FileExistsContext(ctx, newPath)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return to.WriteStreamContext(ctx, newPath, r)
+	}
+
+	tmp, err := tempPath(newPath)
+	if err != nil {
+		return err
+	}
+
+	err = to.WriteStreamContext(ctx, tmp, r)
+	if err != nil {
+		// Not every adapter removes a partially written file
+		to.DeleteContext(context.Background(), tmp)
+
+		return err
+	}
+
+	backup, err := tempPath(newPath)
+	if err != nil {
+		to.DeleteContext(context.Background(), tmp)
+
+		return err
+	}
+
+	// The new contents are stored, finish replacing the file even when the context is cancelled.
+	// The old file is moved aside rather than deleted, so it can be put back if the new one can't take its place
+	err = to.RenameContext(context.Background(), newPath, backup)
+	if err != nil {
+		to.DeleteContext(context.Background(), tmp)
+
+		return err
+	}
+
+	err = to.RenameContext(context.Background(), tmp, newPath)
+	if err != nil {
+		to.RenameContext(context.Background(), backup, newPath)
+		to.DeleteContext(context.Background(), tmp)
+
+		return err
+	}
+
+	return to.DeleteContext(context.Background(), backup)
+}
+
+// copyDirAcross copies a directory and everything in it from one mount to another
+func (m *MountManager) copyDirAcross(ctx context.Context, from adapter.ContextAdapter, dir string, to adapter.ContextAdapter, newDir string) error {
+	listing, err := from.ListContentsContext(ctx, dir, true)
+	if err != nil {
+		return err
+	}
+
+	defer listing.Close()
+
+	if err := m.ensureDir(ctx, to, newDir); err != nil {
+		return err
+	}
+
+	prefix := strings.Trim(dir, "/") + "/"
+	if prefix == "/" {
+		prefix = ""
+	}
+
+	for listing.Next() {
+		attributes := listing.Attributes()
+		destination := path.Join(newDir, strings.TrimPrefix(attributes.Path, prefix))
+
+		if attributes.IsDir() {
+			err = m.ensureDir(ctx, to, destination)
+		} else {
+			err = m.copyAcross(ctx, from, attributes.Path, to, destination)
+		}
+
+		if err != nil {
+			return err
+		}
+	}
+
+	return listing.Err()
+}
+
+// ensureDir creates a directory unless it exists
+func (m *MountManager) ensureDir(ctx context.Context, a adapter.ContextAdapter, dir string) error {
+	exists, err := a.DirectoryExistsContext(ctx, dir)
+	if err != nil || exists {
+		return err
+	}
+
+	return a.CreateDirContext(ctx, dir)
+}
+
+// tempPath returns a hidden path next to p that a file can be written to before it replaces p
+func tempPath(p string) (string, error) {
+	suffix := make([]byte, 8)
+
+	_, err := rand.Read(suffix)
+	if err != nil {
+		return "", err
+	}
+
+	return path.Join(path.Dir(p), fmt.Sprintf(".%s.%x.tmp", path.Base(p), suffix)), nil
+}
+
+// mountName returns the mount prefix of a path that was resolved
+func mountName(path string) string {
+	return strings.SplitN(path, mountSeparator, 2)[0]
+}
+
+// mountListing adds the mount prefix to the paths of a listing
+type mountListing struct {
+	adapter.DirectoryListing
+	prefix string
+}
+
+// Attributes returns the attributes of the current entry
+func (l *mountListing) Attributes() adapter.FileAttributes {
+	attributes := l.DirectoryListing.Attributes()
+	attributes.Path = l.prefix + attributes.Path
+
+	return attributes
+}
@@ -0,0 +1,399 @@
+package flysystem
+
+import (
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+// failingReader streams the start of a file and then fails
+type failingReader struct {
+	adapter.Adapter
+}
+
+func (a failingReader) ReadStream(path string) (io.ReadCloser, error) {
+	return io.NopCloser(io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(errBroken))), nil
+}
+
+// failingRename fails the first rename onto target
+type failingRename struct {
+	adapter.Adapter
+	target string
+	failed bool
+}
+
+func (a *failingRename) Rename(path string, newPath string) error {
+	if newPath == a.target && !a.failed {
+		a.failed = true
+
+		return errBroken
+	}
+
+	return a.Adapter.Rename(path, newPath)
+}
+
+func newMountManager(t *testing.T) (*MountManager, adapter.Adapter, adapter.Adapter) {
+	uploads := adapter.NewMemory()
+	cache := adapter.NewMemory()
+
+	m := NewMountManager()
+
+	if err := m.Mount("uploads", uploads); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Mount("cache", New(cache)); err != nil {
+		t.Fatal(err)
+	}
+
+	return m, uploads, cache
+}
+
+func TestMountManager_Mount(t *testing.T) {
+	m, uploads, _ := newMountManager(t)
+
+	err := m.Mount("uploads", adapter.NewMemory())
+	if !errors.Is(err, ErrMountExists) {
+		t.Logf("expected ErrMountExists, got %v", err)
+		t.Fail()
+	}
+
+	for _, name := range []string{"", "a:b", "a/b"} {
+		if err := m.Mount(name, adapter.NewMemory()); err == nil {
+			t.Logf("expected mounting %q to fail", name)
+			t.Fail()
+		}
+	}
+
+	if strings.Join(m.Mounts(), ",") != "cache,uploads" {
+		t.Logf("unexpected mounts: %v", m.Mounts())
+		t.Fail()
+	}
+
+	err = m.Write("uploads://sub/test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := uploads.Read("sub/test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the file on the uploads mount, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	attributes, err := m.Stat("uploads://sub/test.txt")
+	if err != nil || attributes.Path != "uploads://sub/test.txt" || attributes.Size != 5 {
+		t.Logf("unexpected attributes: %+v %v", attributes, err)
+		t.Fail()
+	}
+
+	_, err = m.Read("sub/test.txt")
+	if !errors.Is(err, ErrMountNotFound) {
+		t.Logf("expected ErrMountNotFound without a prefix, got %v", err)
+		t.Fail()
+	}
+
+	_, err = m.Read("backup://sub/test.txt")
+	if !errors.Is(err, ErrMountNotFound) {
+		t.Logf("expected ErrMountNotFound, got %v", err)
+		t.Fail()
+	}
+
+	_, err = m.Read("cache://sub/test.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	m.Unmount("uploads")
+
+	_, err = m.Read("uploads://sub/test.txt")
+	if !errors.Is(err, ErrMountNotFound) {
+		t.Logf("expected ErrMountNotFound after unmounting, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestMountManager_ListContents(t *testing.T) {
+	m, _, _ := newMountManager(t)
+
+	for _, p := range []string{"uploads://a.txt", "uploads://dir/b.txt", "cache://c.txt"} {
+		if err := m.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	listing, err := m.ListContents("uploads://", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err := adapter.Collect(listing)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var paths []string
+	for _, attributes := range contents {
+		paths = append(paths, attributes.Path)
+	}
+
+	expected := []string{"uploads://a.txt", "uploads://dir", "uploads://dir/b.txt"}
+	if strings.Join(paths, ",") != strings.Join(expected, ",") {
+		t.Logf("expected %v, got %v", expected, paths)
+		t.Fail()
+	}
+
+	for _, p := range paths {
+		if ok, err := m.Has(p); !ok || err != nil {
+			t.Logf("expected the listed path %s to resolve, got %v", p, err)
+			t.Fail()
+		}
+	}
+}
+
+func TestMountManager_Copy(t *testing.T) {
+	m, uploads, cache := newMountManager(t)
+
+	if err := m.Mount("streams", onlyReader{adapter.NewMemory()}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Write("uploads://a.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Copy("uploads://a.txt", "uploads://b.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = m.Copy("uploads://a.txt", "cache://sub/a.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := cache.Read("sub/a.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the file to be copied, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	if err := m.Put("uploads://a.txt", []byte("hello again")); err != nil {
+		t.Fatal(err)
+	}
+
+	err = m.Copy("uploads://a.txt", "cache://sub/a.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err = cache.Read("sub/a.txt")
+	if err != nil || string(contents) != "hello again" {
+		t.Logf("expected the copy to be replaced, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	err = m.Copy("cache://sub/a.txt", "streams://a.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := uploads.FileExists("a.txt"); !ok {
+		t.Log("expected the source to be kept")
+		t.Fail()
+	}
+
+	err = m.Copy("uploads://missing.txt", "cache://missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	err = m.Copy("uploads://a.txt", "backup://a.txt")
+	if !errors.Is(err, ErrMountNotFound) {
+		t.Logf("expected ErrMountNotFound, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestMountManager_CopyFailingReader(t *testing.T) {
+	m, _, cache := newMountManager(t)
+
+	broken := adapter.NewMemory()
+
+	if err := broken.Write("a.txt", []byte("hello again")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := m.Mount("broken", failingReader{broken}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := cache.Write("sub/a.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, op := range []func(path string, newPath string) error{m.Copy, m.Rename} {
+		err := op("broken://a.txt", "cache://sub/a.txt")
+		if !errors.Is(err, errBroken) {
+			t.Logf("expected errBroken, got %v", err)
+			t.Fail()
+		}
+
+		contents, err := cache.Read("sub/a.txt")
+		if err != nil || string(contents) != "hello" {
+			t.Logf("expected the destination to be kept, got %q %v", contents, err)
+			t.Fail()
+		}
+
+		listing, err := cache.ListContents("sub", false)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		listed, err := adapter.Collect(listing)
+		if err != nil || len(listed) != 1 || listed[0].Path != "sub/a.txt" {
+			t.Logf("expected only sub/a.txt to be left, got %v %v", listed, err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := broken.FileExists("a.txt"); !ok {
+		t.Log("expected the source of the failed rename to be kept")
+		t.Fail()
+	}
+}
+
+func TestMountManager_CopyFailingRename(t *testing.T) {
+	m, uploads, _ := newMountManager(t)
+
+	backup := adapter.NewMemory()
+
+	if err := m.Mount("backup", &failingRename{Adapter: backup, target: "sub/a.txt"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := uploads.Write("a.txt", []byte("hello again")); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := backup.Write("sub/a.txt", []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Copy("uploads://a.txt", "backup://sub/a.txt")
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken, got %v", err)
+		t.Fail()
+	}
+
+	contents, err := backup.Read("sub/a.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the destination to be restored, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	listing, err := backup.ListContents("sub", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	listed, err := adapter.Collect(listing)
+	if err != nil || len(listed) != 1 || listed[0].Path != "sub/a.txt" {
+		t.Logf("expected only sub/a.txt to be left, got %v %v", listed, err)
+		t.Fail()
+	}
+
+	// The next copy replaces the file
+	err = m.Copy("uploads://a.txt", "backup://sub/a.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contents, err = backup.Read("sub/a.txt")
+	if err != nil || string(contents) != "hello again" {
+		t.Logf("expected the destination to be replaced, got %q %v", contents, err)
+		t.Fail()
+	}
+}
+
+func TestMountManager_Rename(t *testing.T) {
+	m, uploads, cache := newMountManager(t)
+
+	for _, p := range []string{"uploads://a.txt", "uploads://dir/b.txt", "uploads://dir/sub/c.txt"} {
+		if err := m.Write(p, []byte(p)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := m.CreateDir("uploads://dir/empty"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := m.Rename("uploads://a.txt", "cache://moved/a.txt")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	contents, err := cache.Read("moved/a.txt")
+	if err != nil || string(contents) != "uploads://a.txt" {
+		t.Logf("expected the file to be moved, got %q %v", contents, err)
+		t.Fail()
+	}
+
+	if ok, _ := uploads.Has("a.txt"); ok {
+		t.Log("expected the source to be deleted")
+		t.Fail()
+	}
+
+	err = m.Rename("uploads://dir", "cache://moved/dir")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	for _, p := range []string{"dir/b.txt", "dir/sub/c.txt"} {
+		contents, err := cache.Read("moved/" + p)
+		if err != nil || string(contents) != "uploads://"+p {
+			t.Logf("expected %s to be moved, got %q %v", p, contents, err)
+			t.Fail()
+		}
+	}
+
+	if ok, _ := cache.DirectoryExists("moved/dir/empty"); !ok {
+		t.Log("expected the empty directory to be moved")
+		t.Fail()
+	}
+
+	if ok, _ := uploads.Has("dir"); ok {
+		t.Log("expected the source directory to be deleted")
+		t.Fail()
+	}
+
+	err = m.Rename("cache://moved", "cache://renamed")
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	if ok, _ := cache.FileExists("renamed/dir/sub/c.txt"); !ok {
+		t.Log("expected the directory to be renamed within the mount")
+		t.Fail()
+	}
+
+	err = m.Rename("uploads://missing.txt", "cache://missing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+}
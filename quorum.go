@@ -0,0 +1,146 @@
+package flysystem
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"sort"
+)
+
+// ErrInconsistentRead is returned when fewer adapters than the read quorum returned the same contents
+var ErrInconsistentRead = errors.New("adapters returned different contents")
+
+// WriteQuorum makes changes succeed once n adapters confirmed them.
+// The adapters that failed are lagging, their errors are available through Lagging instead of failing the change.
+// Has, FileExists and DirectoryExists report true when the path exists on at least n adapters
+func WriteQuorum(n int) Option {
+	return func(f *Flysystem) {
+		f.writeQuorum = n
+	}
+}
+
+// ReadQuorum makes Read compare the contents of the adapters, it succeeds once n adapters returned the same contents.
+// A quorum larger than the number of adapters requires all of them to agree
+func ReadQuorum(n int) Option {
+	return func(f *Flysystem) {
+		f.readQuorum = n
+	}
+}
+
+// Lagging returns the latest error of every adapter that missed a change while the write quorum was met
+func (f *Flysystem) Lagging() []Result {
+	f.Lock()
+	defer f.Unlock()
+
+	lagging := make([]Result, 0, len(f.lagging))
+
+	for _, r := range f.lagging {
+		lagging = append(lagging, r)
+	}
+
+	sort.Slice(lagging, func(i, j int) bool {
+		return lagging[i].Index < lagging[j].Index
+	})
+
+	return lagging
+}
+
+// required returns the number of adapters that have to succeed for a change to succeed
+func (f *Flysystem) required() int {
+	if f.writeQuorum > 0 && f.writeQuorum < len(f.adapters) {
+		return f.writeQuorum
+	}
+
+	return len(f.adapters)
+}
+
+// readRequired returns the number of adapters that have to return the same contents for a read to succeed
+func (f *Flysystem) readRequired() int {
+	if f.readQuorum < len(f.adapters) {
+		return f.readQuorum
+	}
+
+	return len(f.adapters)
+}
+
+// lag records the failed adapters of a change that met the write quorum,
+// adapters that succeeded have caught up and are no longer lagging
+func (f *Flysystem) lag(m *MultiError) {
+	f.Lock()
+	defer f.Unlock()
+
+	if f.lagging == nil {
+		f.lagging = map[int]Result{}
+	}
+
+	for _, r := range m.Results {
+		if r.Err == nil {
+			delete(f.lagging, r.Index)
+
+			continue
+		}
+
+		f.lagging[r.Index] = r
+	}
+}
+
+// caughtUp clears the lagging adapters once a change succeeded on every adapter
+func (f *Flysystem) caughtUp() {
+	f.Lock()
+	defer f.Unlock()
+
+	f.lagging = nil
+}
+
+// readQuorumContents reads from every adapter and returns the contents once the read quorum agrees on them
+func (f *Flysystem) readQuorumContents(ctx context.Context, path string) ([]byte, error) {
+	type outcome struct {
+		i        int
+		contents []byte
+		err      error
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	outcomes := make(chan outcome, len(f.adapters))
+
+	for i, a := range f.adapters {
+		i, a := i, a
+
+		go func() {
+			contents, err := a.ReadContext(ctx, path)
+			outcomes <- outcome{i: i, contents: contents, err: err}
+		}()
+	}
+
+	errs := make([]error, len(f.adapters))
+	votes := map[[sha256.Size]byte]int{}
+	succeeded := 0
+	required := f.readRequired()
+
+	for range f.adapters {
+		o := <-outcomes
+		if o.err != nil {
+			errs[o.i] = o.err
+
+			continue
+		}
+
+		succeeded++
+
+		sum := sha256.Sum256(o.contents)
+		votes[sum]++
+
+		if votes[sum] >= required {
+			return o.contents, nil
+		}
+	}
+
+	if succeeded < required {
+		return nil, f.collectAttempted(errs)
+	}
+
+	return nil, &adapter.Error{Op: "read", Path: path, Kind: ErrInconsistentRead}
+}
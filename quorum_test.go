@@ -0,0 +1,127 @@
+package flysystem
+
+import (
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"testing"
+)
+
+func TestWriteQuorum(t *testing.T) {
+	a, b, c := adapter.NewMemory(), adapter.NewMemory(), adapter.NewMemory()
+
+	released := make(chan struct{})
+	close(released)
+
+	broken := &brokenAdapter{Adapter: c, wait: released}
+
+	fs := NewWithOptions([]adapter.Adapter{a, b, broken}, WriteQuorum(2))
+
+	err := fs.Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	lagging := fs.Lagging()
+	if len(lagging) != 1 || lagging[0].Index != 2 || !errors.Is(lagging[0].Err, errBroken) {
+		t.Logf("expected the broken adapter to be lagging, got %v", lagging)
+		t.Fail()
+	}
+
+	ok, err := fs.Has("test.txt")
+	if err != nil || !ok {
+		t.Log("expected test.txt to exist on the quorum")
+		t.Fail()
+	}
+
+	fs = NewWithOptions([]adapter.Adapter{a, b, c}, WriteQuorum(2))
+
+	// c missed the write, the update still meets the quorum
+	err = fs.Update("test.txt", []byte("hello world"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	lagging = fs.Lagging()
+	if len(lagging) != 1 || lagging[0].Index != 2 || !errors.Is(lagging[0].Err, adapter.ErrFileNotFound) {
+		t.Logf("expected the third adapter to be lagging, got %v", lagging)
+		t.Fail()
+	}
+
+	// c catches up once a change succeeds on it
+	err = fs.Put("test.txt", []byte("hello again"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	lagging = fs.Lagging()
+	if len(lagging) != 0 {
+		t.Logf("expected no lagging adapters, got %v", lagging)
+		t.Fail()
+	}
+
+	fs = NewWithOptions([]adapter.Adapter{a, broken, broken}, WriteQuorum(2))
+
+	err = fs.Put("test.txt", []byte("hello"))
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken, got %v", err)
+		t.Fail()
+	}
+
+	fs = New(a, b, broken)
+
+	err = fs.Put("test.txt", []byte("hello"))
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken without a quorum, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestReadQuorum(t *testing.T) {
+	a, b, c := adapter.NewMemory(), adapter.NewMemory(), adapter.NewMemory()
+
+	err := New(a, b).Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = c.Write("test.txt", []byte("stale"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	fs := NewWithOptions([]adapter.Adapter{c, a, b}, ReadQuorum(2))
+
+	contents, err := fs.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the contents agreed on by the quorum, got %v", err)
+		t.Fail()
+	}
+
+	fs = NewWithOptions([]adapter.Adapter{c, a}, ReadQuorum(2))
+
+	_, err = fs.Read("test.txt")
+	if !errors.Is(err, ErrInconsistentRead) {
+		t.Logf("expected ErrInconsistentRead, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("non-existing.txt")
+	if !errors.Is(err, adapter.ErrFileNotFound) {
+		t.Logf("expected ErrFileNotFound, got %v", err)
+		t.Fail()
+	}
+
+	// A quorum larger than the number of adapters requires all of them to agree
+	fs = NewWithOptions([]adapter.Adapter{a, b}, ReadQuorum(3))
+
+	contents, err = fs.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the contents agreed on by every adapter, got %v", err)
+		t.Fail()
+	}
+}
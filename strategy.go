@@ -0,0 +1,139 @@
+package flysystem
+
+import (
+	"context"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+)
+
+// ReadFunc reads from a single adapter
+type ReadFunc func(ctx context.Context, i int, a adapter.ContextAdapter) error
+
+// ReadStrategy decides which adapters serve a read.
+// Read returns the index of the adapter that served the read,
+// or -1 and the errors of the adapters it attempted when none of them succeeded
+type ReadStrategy interface {
+	Read(ctx context.Context, adapters []adapter.ContextAdapter, read ReadFunc) (int, []error)
+}
+
+// ReadPrimary only reads from the first adapter
+var ReadPrimary ReadStrategy = primary{}
+
+// ReadFallback reads from the adapters in order until one succeeds
+var ReadFallback ReadStrategy = fallback{}
+
+// ReadFastest reads from every adapter at once, the first one to succeed wins and the others are cancelled
+var ReadFastest ReadStrategy = fastest{}
+
+// WithReadStrategy sets the strategy used by Read, ReadStream, Stat and ListContents, it defaults to ReadFallback
+func WithReadStrategy(strategy ReadStrategy) Option {
+	return func(f *Flysystem) {
+		f.readStrategy = strategy
+	}
+}
+
+type primary struct{}
+
+// Read reads from the first adapter
+func (primary) Read(ctx context.Context, adapters []adapter.ContextAdapter, read ReadFunc) (int, []error) {
+	errs := make([]error, len(adapters))
+
+	if len(adapters) == 0 {
+		return -1, errs
+	}
+
+	if errs[0] = read(ctx, 0, adapters[0]); errs[0] != nil {
+		return -1, errs
+	}
+
+	return 0, nil
+}
+
+type fallback struct{}
+
+// Read reads from the adapters in order until one succeeds
+func (fallback) Read(ctx context.Context, adapters []adapter.ContextAdapter, read ReadFunc) (int, []error) {
+	errs := make([]error, len(adapters))
+
+	for i, a := range adapters {
+		if errs[i] = read(ctx, i, a); errs[i] == nil {
+			return i, nil
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return -1, errs
+}
+
+type fastest struct{}
+
+// Read reads from every adapter at once and returns as soon as one succeeds
+func (fastest) Read(ctx context.Context, adapters []adapter.ContextAdapter, read ReadFunc) (int, []error) {
+	type outcome struct {
+		i   int
+		err error
+	}
+
+	errs := make([]error, len(adapters))
+	outcomes := make(chan outcome, len(adapters))
+	cancels := make([]context.CancelFunc, len(adapters))
+	releases := make([]*release, len(adapters))
+
+	for i, a := range adapters {
+		i, a := i, a
+
+		// Every read gets its own context so the winner is not cancelled along with the others
+		readCtx, cancel := context.WithCancel(ctx)
+		cancels[i] = cancel
+		releases[i] = &release{cancel: cancel}
+		readCtx = context.WithValue(readCtx, releaseKey{}, releases[i])
+
+		go func() {
+			outcomes <- outcome{i: i, err: read(readCtx, i, a)}
+		}()
+	}
+
+	for range adapters {
+		o := <-outcomes
+		if o.err == nil {
+			for i, cancel := range cancels {
+				if i != o.i || !releases[i].kept {
+					cancel()
+				}
+			}
+
+			return o.i, nil
+		}
+
+		errs[o.i] = o.err
+	}
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+
+	return -1, errs
+}
+
+// releaseKey is the context key of the release of a read
+type releaseKey struct{}
+
+// release ends the context of a read, unless the reader kept it to read from a stream
+type release struct {
+	cancel context.CancelFunc
+	kept   bool
+}
+
+// keepReading keeps the context of a read alive after the read returned, the returned function ends it.
+// A stream or listing that is read from after the read returned calls it once it is closed
+func keepReading(ctx context.Context) context.CancelFunc {
+	if r, ok := ctx.Value(releaseKey{}).(*release); ok {
+		r.kept = true
+
+		return r.cancel
+	}
+
+	return func() {}
+}
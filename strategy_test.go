@@ -0,0 +1,204 @@
+package flysystem
+
+import (
+	"context"
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"io"
+	"testing"
+	"time"
+)
+
+// unreadableAdapter fails every read
+type unreadableAdapter struct {
+	adapter.Adapter
+}
+
+func (a *unreadableAdapter) Read(path string) ([]byte, error) {
+	return nil, errBroken
+}
+
+func (a *unreadableAdapter) ReadStream(path string) (io.ReadCloser, error) {
+	return nil, errBroken
+}
+
+func (a *unreadableAdapter) Stat(path string) (adapter.FileAttributes, error) {
+	return adapter.FileAttributes{}, errBroken
+}
+
+// contextRecorder records the context of the last read
+type contextRecorder struct {
+	adapter.ContextAdapter
+	ctx context.Context
+}
+
+func (a *contextRecorder) ReadContext(ctx context.Context, path string) ([]byte, error) {
+	a.ctx = ctx
+
+	return a.ContextAdapter.ReadContext(ctx, path)
+}
+
+func (a *contextRecorder) ReadStreamContext(ctx context.Context, path string) (io.ReadCloser, error) {
+	a.ctx = ctx
+
+	return a.ContextAdapter.ReadStreamContext(ctx, path)
+}
+
+// slowAdapter delays every read
+type slowAdapter struct {
+	adapter.Adapter
+	delay time.Duration
+}
+
+func (a *slowAdapter) Read(path string) ([]byte, error) {
+	time.Sleep(a.delay)
+
+	return a.Adapter.Read(path)
+}
+
+func (a *slowAdapter) ReadStream(path string) (io.ReadCloser, error) {
+	time.Sleep(a.delay)
+
+	return a.Adapter.ReadStream(path)
+}
+
+func newStrategyPair(t *testing.T) (adapter.Adapter, adapter.Adapter) {
+	a := adapter.NewMemory()
+	b := adapter.NewMemory()
+
+	err := New(a, b).Write("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	return a, b
+}
+
+func TestReadFallback(t *testing.T) {
+	a, b := newStrategyPair(t)
+	fs := NewWithOptions([]adapter.Adapter{&unreadableAdapter{Adapter: a}, b}, WithReadStrategy(ReadFallback))
+
+	contents, err := fs.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the second adapter to serve the read, got %v", err)
+		t.Fail()
+	}
+
+	attributes, err := fs.Stat("test.txt")
+	if err != nil || attributes.Size != 5 {
+		t.Logf("expected the second adapter to serve the stat, got %v", err)
+		t.Fail()
+	}
+
+	_, err = fs.Read("non-existing.txt")
+
+	var m *MultiError
+	if !errors.As(err, &m) || len(m.Results) != 2 {
+		t.Logf("expected both adapters to fail, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestReadPrimary(t *testing.T) {
+	a, b := newStrategyPair(t)
+	fs := NewWithOptions([]adapter.Adapter{&unreadableAdapter{Adapter: a}, b}, WithReadStrategy(ReadPrimary))
+
+	_, err := fs.Read("test.txt")
+
+	var m *MultiError
+	if !errors.As(err, &m) || len(m.Results) != 1 || m.Results[0].Index != 0 || !errors.Is(err, errBroken) {
+		t.Logf("expected only the first adapter to be read, got %v", err)
+		t.Fail()
+	}
+
+	fs = NewWithOptions([]adapter.Adapter{a, &unreadableAdapter{Adapter: b}}, WithReadStrategy(ReadPrimary))
+
+	contents, err := fs.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the first adapter to serve the read, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestReadFastest(t *testing.T) {
+	a, b := newStrategyPair(t)
+	delay := 500 * time.Millisecond
+	fs := NewWithOptions([]adapter.Adapter{&slowAdapter{Adapter: a, delay: delay}, b}, WithReadStrategy(ReadFastest))
+
+	start := time.Now()
+
+	contents, err := fs.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the second adapter to serve the read, got %v", err)
+		t.Fail()
+	}
+
+	if time.Since(start) >= delay {
+		t.Log("expected the read not to wait for the slow adapter")
+		t.Fail()
+	}
+
+	stream, err := fs.ReadStream("test.txt")
+	if err != nil {
+		t.Log(err)
+		t.FailNow()
+	}
+
+	contents, err = io.ReadAll(stream)
+	if err != nil || string(contents) != "hello" {
+		t.Logf("unexpected stream contents, got %v", err)
+		t.Fail()
+	}
+
+	stream.Close()
+
+	fs = NewWithOptions([]adapter.Adapter{&unreadableAdapter{Adapter: a}, &unreadableAdapter{Adapter: b}}, WithReadStrategy(ReadFastest))
+
+	_, err = fs.Read("test.txt")
+
+	var m *MultiError
+	if !errors.As(err, &m) || len(m.Results) != 2 {
+		t.Logf("expected both adapters to fail, got %v", err)
+		t.Fail()
+	}
+}
+
+func TestReadFastest_Release(t *testing.T) {
+	a, b := newStrategyPair(t)
+	recorder := &contextRecorder{ContextAdapter: adapter.WithContext(b)}
+	fs := NewWithOptions([]adapter.Adapter{&unreadableAdapter{Adapter: a}, recorder}, WithReadStrategy(ReadFastest))
+
+	_, err := fs.Read("test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.ctx.Err() == nil {
+		t.Log("expected the context of the read to end once it returned")
+		t.Fail()
+	}
+
+	stream, err := fs.ReadStream("test.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if recorder.ctx.Err() != nil {
+		t.Log("expected the context of the stream to stay alive until it is closed")
+		t.Fail()
+	}
+
+	contents, err := io.ReadAll(stream)
+	if err != nil || string(contents) != "hello" {
+		t.Logf("unexpected stream contents, got %v", err)
+		t.Fail()
+	}
+
+	stream.Close()
+
+	if recorder.ctx.Err() == nil {
+		t.Log("expected the context of the stream to end once it was closed")
+		t.Fail()
+	}
+}
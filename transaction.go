@@ -0,0 +1,121 @@
+package flysystem
+
+import (
+	"context"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"sync"
+)
+
+// undoFunc reverts a change made on a single adapter
+type undoFunc func(ctx context.Context) error
+
+// snapshot is the state of a file before it was changed
+type snapshot struct {
+	exists     bool
+	contents   []byte
+	visibility string
+}
+
+// snapshot records the state of a file on an adapter, it records nothing when transactions are disabled
+func (f *Flysystem) snapshot(ctx context.Context, a adapter.ContextAdapter, path string) (snapshot, error) {
+	if !f.transactional {
+		return snapshot{}, nil
+	}
+
+	ok, err := a.FileExistsContext(ctx, path)
+	if err != nil || !ok {
+		return snapshot{}, err
+	}
+
+	contents, err := a.ReadContext(ctx, path)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	attributes, err := a.StatContext(ctx, path)
+	if err != nil {
+		return snapshot{}, err
+	}
+
+	return snapshot{exists: true, contents: contents, visibility: attributes.Visibility}, nil
+}
+
+// restore returns an undoFunc that puts the file back into its recorded state
+func (s snapshot) restore(a adapter.ContextAdapter, path string) undoFunc {
+	return func(ctx context.Context) error {
+		if !s.exists {
+			return a.DeleteContext(ctx, path)
+		}
+
+		if err := a.PutContext(ctx, path, s.contents); err != nil {
+			return err
+		}
+
+		if s.visibility == "" {
+			return nil
+		}
+
+		return a.SetVisibilityContext(ctx, path, s.visibility)
+	}
+}
+
+// runTransaction runs action on every adapter, when transactions are enabled
+// the changes on the adapters that succeeded are undone if any adapter failed
+func (f *Flysystem) runTransaction(ctx context.Context, action func(ctx context.Context, a adapter.ContextAdapter) (undoFunc, error)) error {
+	undos := make([]undoFunc, len(f.adapters))
+
+	errs := f.fanOut(ctx, func(ctx context.Context, i int, a adapter.ContextAdapter) error {
+		var err error
+		undos[i], err = action(ctx, a)
+
+		return err
+	})
+
+	return f.settle(errs, undos)
+}
+
+// settle collects the results, a write that met the quorum succeeds and records the failed adapters as lagging.
+// Otherwise the adapters that succeeded are rolled back
+func (f *Flysystem) settle(errs []error, undos []undoFunc) error {
+	err := f.collect(errs)
+	if err == nil {
+		f.caughtUp()
+
+		return nil
+	}
+
+	m := err.(*MultiError)
+
+	if len(m.Succeeded()) >= f.required() {
+		f.lag(m)
+
+		return nil
+	}
+
+	if !f.transactional || undos == nil {
+		return m
+	}
+
+	var wg sync.WaitGroup
+
+	for i := range m.Results {
+		if errs[i] != nil || undos[i] == nil {
+			continue
+		}
+
+		i := i
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			// Roll back even when the context of the operation was cancelled
+			m.Results[i].RollbackErr = undos[i](context.Background())
+			m.Results[i].RolledBack = m.Results[i].RollbackErr == nil
+		}()
+	}
+
+	wg.Wait()
+
+	return m
+}
@@ -0,0 +1,211 @@
+package flysystem
+
+import (
+	"errors"
+	"github.com/edwin-luijten/go_flysystem/adapter"
+	"sync"
+	"testing"
+)
+
+var errBroken = errors.New("broken")
+
+// signalAdapter closes done after the first change was made
+type signalAdapter struct {
+	adapter.Adapter
+	done chan struct{}
+	once sync.Once
+}
+
+func (a *signalAdapter) signal() {
+	a.once.Do(func() {
+		close(a.done)
+	})
+}
+
+func (a *signalAdapter) Write(path string, contents []byte) error {
+	defer a.signal()
+
+	return a.Adapter.Write(path, contents)
+}
+
+func (a *signalAdapter) Update(path string, contents []byte) error {
+	defer a.signal()
+
+	return a.Adapter.Update(path, contents)
+}
+
+func (a *signalAdapter) Rename(path string, newPath string) error {
+	defer a.signal()
+
+	return a.Adapter.Rename(path, newPath)
+}
+
+func (a *signalAdapter) Delete(path string) error {
+	defer a.signal()
+
+	return a.Adapter.Delete(path)
+}
+
+// brokenAdapter fails every change once the other adapter made it
+type brokenAdapter struct {
+	adapter.Adapter
+	wait chan struct{}
+}
+
+func (a *brokenAdapter) Write(path string, contents []byte) error {
+	<-a.wait
+
+	return errBroken
+}
+
+func (a *brokenAdapter) Update(path string, contents []byte) error {
+	<-a.wait
+
+	return errBroken
+}
+
+func (a *brokenAdapter) Put(path string, contents []byte) error {
+	<-a.wait
+
+	return errBroken
+}
+
+func (a *brokenAdapter) Rename(path string, newPath string) error {
+	<-a.wait
+
+	return errBroken
+}
+
+func (a *brokenAdapter) Delete(path string) error {
+	<-a.wait
+
+	return errBroken
+}
+
+func newBrokenPair(options ...Option) (adapter.Adapter, adapter.Adapter, *Flysystem) {
+	a := adapter.NewMemory()
+	b := adapter.NewMemory()
+
+	done := make(chan struct{})
+
+	return a, b, NewWithOptions([]adapter.Adapter{
+		&signalAdapter{Adapter: a, done: done},
+		&brokenAdapter{Adapter: b, wait: done},
+	}, options...)
+}
+
+func TestFlysystem_TransactionalWrite(t *testing.T) {
+	a, _, fs := newBrokenPair(Transactional())
+
+	err := fs.Write("test.txt", []byte("hello"))
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken, got %v", err)
+		t.Fail()
+	}
+
+	var m *MultiError
+	if !errors.As(err, &m) || !m.Results[0].RolledBack {
+		t.Logf("expected the first adapter to be rolled back, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := a.Has("test.txt"); ok {
+		t.Log("expected test.txt to be removed")
+		t.Fail()
+	}
+
+	a, _, fs = newBrokenPair()
+
+	err = fs.Write("test.txt", []byte("hello"))
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := a.Has("test.txt"); !ok {
+		t.Log("expected test.txt to be left behind without transactions")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_TransactionalUpdate(t *testing.T) {
+	a, b, fs := newBrokenPair(Transactional())
+
+	err := New(a, b).Put("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = a.SetVisibility("test.txt", adapter.VisibilityPrivate)
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Update("test.txt", []byte("hello world"))
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken, got %v", err)
+		t.Fail()
+	}
+
+	contents, err := a.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected the previous contents to be restored, got '%s'", contents)
+		t.Fail()
+	}
+
+	attributes, err := a.Stat("test.txt")
+	if err != nil || attributes.Visibility != adapter.VisibilityPrivate {
+		t.Log("expected the previous visibility to be restored")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_TransactionalRename(t *testing.T) {
+	a, b, fs := newBrokenPair(Transactional())
+
+	err := New(a, b).Put("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Rename("test.txt", "test_renamed.txt")
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken, got %v", err)
+		t.Fail()
+	}
+
+	if ok, _ := a.Has("test.txt"); !ok {
+		t.Log("expected test.txt to be renamed back")
+		t.Fail()
+	}
+
+	if ok, _ := a.Has("test_renamed.txt"); ok {
+		t.Log("expected test_renamed.txt to be gone")
+		t.Fail()
+	}
+}
+
+func TestFlysystem_TransactionalDelete(t *testing.T) {
+	a, b, fs := newBrokenPair(Transactional())
+
+	err := New(a, b).Put("test.txt", []byte("hello"))
+	if err != nil {
+		t.Log(err)
+		t.Fail()
+	}
+
+	err = fs.Delete("test.txt")
+	if !errors.Is(err, errBroken) {
+		t.Logf("expected errBroken, got %v", err)
+		t.Fail()
+	}
+
+	contents, err := a.Read("test.txt")
+	if err != nil || string(contents) != "hello" {
+		t.Logf("expected test.txt to be restored, got '%s'", contents)
+		t.Fail()
+	}
+}